@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/exhaustivereturns"
+)
+
+func main() {
+	cli.Standalone(exhaustivereturns.Command)
+}
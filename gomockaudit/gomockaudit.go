@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/mockaudit"
+)
+
+func main() {
+	cli.Standalone(mockaudit.Command)
+}
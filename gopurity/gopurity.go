@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/purity"
+)
+
+func main() {
+	cli.Standalone(purity.Command)
+}
@@ -1,7 +0,0 @@
-// +build !linux
-
-package main
-
-func color(s string) string {
-	return s
-}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/shadowvar"
+)
+
+func main() {
+	cli.Standalone(shadowvar.Command)
+}
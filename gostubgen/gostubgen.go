@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/stubgen"
+)
+
+func main() {
+	cli.Standalone(stubgen.Command)
+}
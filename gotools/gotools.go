@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/buildinfoaudit"
+	"github.com/ericchiang/gotools/internal/cmd/concurrencymap"
+	"github.com/ericchiang/gotools/internal/cmd/derivecallersofinterface"
+	"github.com/ericchiang/gotools/internal/cmd/entry"
+	"github.com/ericchiang/gotools/internal/cmd/exhaustivereturns"
+	"github.com/ericchiang/gotools/internal/cmd/fieldinitcheck"
+	"github.com/ericchiang/gotools/internal/cmd/funcount"
+	"github.com/ericchiang/gotools/internal/cmd/genericsaudit"
+	"github.com/ericchiang/gotools/internal/cmd/mockaudit"
+	"github.com/ericchiang/gotools/internal/cmd/moved"
+	"github.com/ericchiang/gotools/internal/cmd/purity"
+	"github.com/ericchiang/gotools/internal/cmd/renamebatch"
+	"github.com/ericchiang/gotools/internal/cmd/search"
+	"github.com/ericchiang/gotools/internal/cmd/shadowvar"
+	"github.com/ericchiang/gotools/internal/cmd/stubgen"
+	"github.com/ericchiang/gotools/internal/cmd/typegraph"
+	"github.com/ericchiang/gotools/internal/cmd/unsafeaudit"
+)
+
+// commands are the tools which can be run as subcommands. Tools are added by
+// moving their code into a package in internal/cmd which exports a Command,
+// keeping their standalone binary as a wrapper.
+var commands = []*cli.Command{
+	search.Command,
+	funcount.Command,
+	renamebatch.Command,
+	typegraph.Command,
+	mockaudit.Command,
+	fieldinitcheck.Command,
+	entry.Command,
+	shadowvar.Command,
+	buildinfoaudit.Command,
+	derivecallersofinterface.Command,
+	exhaustivereturns.Command,
+	unsafeaudit.Command,
+	concurrencymap.Command,
+	stubgen.Command,
+	genericsaudit.Command,
+	purity.Command,
+	moved.Command,
+}
+
+func main() {
+	cli.Main("gotools", commands)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	names := make(map[string]bool)
+	for _, c := range commands {
+		if names[c.Name] {
+			t.Errorf("%s: registered more than once", c.Name)
+		}
+		names[c.Name] = true
+		if !strings.HasPrefix(c.Help, "usage: "+c.Tool+" ") {
+			t.Errorf("%s: expected help beginning with the usage of %s", c.Name, c.Tool)
+		}
+	}
+}
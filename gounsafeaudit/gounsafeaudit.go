@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/cmd/unsafeaudit"
+)
+
+func main() {
+	cli.Standalone(unsafeaudit.Command)
+}
@@ -0,0 +1,102 @@
+// Package cli runs the repo's tools, either as standalone binaries or as
+// subcommands of the gotools binary.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// Command is a tool which can be run as a subcommand of gotools.
+type Command struct {
+	// Name is the subcommand, such as "search".
+	Name string
+	// Tool is the name of the standalone binary, such as "gosearch".
+	Tool string
+	// Summary is a one line description printed by "gotools help".
+	Summary string
+	// Help is the usage printed by "gotools help <command>".
+	Help string
+	// Run runs the tool with the provided arguments, not including the
+	// program or subcommand name.
+	Run func(args []string)
+}
+
+// subcommand is the name of the running subcommand, if any.
+var subcommand string
+
+// Standalone runs a command as its own binary.
+func Standalone(c *Command) {
+	c.Run(os.Args[1:])
+}
+
+// Main runs the command named by the first argument.
+func Main(name string, cmds []*Command) {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage(os.Stderr, name, cmds)
+		os.Exit(2)
+	}
+	if args[0] == "help" {
+		if len(args) == 1 {
+			usage(os.Stdout, name, cmds)
+			return
+		}
+		if c := lookup(cmds, args[1]); c != nil {
+			fmt.Fprint(os.Stdout, c.Help)
+			return
+		}
+		unknown(name, args[1], cmds)
+	}
+	if c := lookup(cmds, args[0]); c != nil {
+		subcommand = c.Name
+		c.Run(args[1:])
+		return
+	}
+	unknown(name, args[0], cmds)
+}
+
+// lookup returns the command with the name, or nil if there isn't one.
+func lookup(cmds []*Command, name string) *Command {
+	for _, c := range cmds {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// unknown reports a command which doesn't exist and exits.
+func unknown(name, cmd string, cmds []*Command) {
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", name, cmd)
+	usage(os.Stderr, name, cmds)
+	os.Exit(2)
+}
+
+func usage(w io.Writer, name string, cmds []*Command) {
+	fmt.Fprintf(w, "usage: %s <command> [flags] [arguments]\n\n", name)
+	fmt.Fprintf(w, "Each command accepts the same flags and arguments as the standalone\n")
+	fmt.Fprintf(w, "binary it replaces. Run \"%s help <command>\" for details.\n\n", name)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range cmds {
+		fmt.Fprintf(tw, "\t%s\t%s\t(%s)\n", c.Name, c.Summary, c.Tool)
+	}
+	tw.Flush()
+}
+
+// Self returns the command line which runs the current tool again: the
+// executable, followed by the subcommand if the tool is running as one.
+func Self() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if subcommand == "" {
+		return []string{exe}, nil
+	}
+	return []string{exe, subcommand}, nil
+}
@@ -0,0 +1,322 @@
+// Package buildinfoaudit implements gobuildinfo-audit, which lists the build
+// settings and fields code reads through runtime/debug.ReadBuildInfo.
+package buildinfoaudit
+
+import (
+	"debug/buildinfo"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"runtime/debug"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gobuildinfo-audit [flags] <list of packages>
+
+gobuildinfo-audit lists the calls to runtime/debug.ReadBuildInfo in the
+provided packages and the build information the code depends on: build
+settings compared against BuildSetting.Key, such as "vcs.revision", and
+fields of BuildInfo, such as Main.Version.
+
+Reads of settings the go tool never records, such as misspelled keys, are
+reported as problems. If a binary is provided, reads of settings and fields
+which aren't set in the binary are reported as well.
+
+Flags:
+
+	-binary file
+		Compare the build information the code reads against that
+		embedded in a binary built from it.
+
+	-json	Print each result as a JSON object.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gobuildinfo-audit exits with status 1 if any problems are found.
+`
+
+// Command runs gobuildinfo-audit as the buildinfo-audit subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "buildinfo-audit",
+	Tool:    "gobuildinfo-audit",
+	Summary: "list the build information code reads with ReadBuildInfo",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gobuildinfo-audit with the provided arguments.
+func Run(args []string) {
+	binary := ""
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gobuildinfo-audit", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.StringVar(&binary, "binary", "", "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	var built *debug.BuildInfo
+	if binary != "" {
+		var err error
+		if built, err = buildinfo.ReadFile(binary); err != nil {
+			fatal(err)
+		}
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	var diags []render.Diagnostic
+	problems := 0
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		for _, r := range findReads(prog.Fset, info) {
+			r.check(binary, built)
+			if r.Reason != "" {
+				problems++
+			}
+			diags = append(diags, r)
+		}
+	}
+	if err := render.PrintProblems(os.Stdout, jsonOutput, diags); err != nil {
+		fatal(err)
+	}
+	if problems != 0 {
+		os.Exit(1)
+	}
+}
+
+// Kinds of reads of build information.
+const (
+	kindCall    = "call"
+	kindSetting = "setting"
+	kindField   = "field"
+)
+
+// knownSettings are the build setting keys recorded by the go tool. See
+// the documentation of runtime/debug.BuildSetting.
+var knownSettings = map[string]bool{
+	"-buildmode": true, "-compiler": true, "-asmflags": true, "-gcflags": true,
+	"-ldflags": true, "-tags": true, "-trimpath": true, "-race": true,
+	"-msan": true, "-asan": true, "-pgo": true,
+	"CGO_ENABLED": true, "CGO_CFLAGS": true, "CGO_CPPFLAGS": true,
+	"CGO_CXXFLAGS": true, "CGO_LDFLAGS": true,
+	"GOOS": true, "GOARCH": true, "GO386": true, "GOAMD64": true,
+	"GOARM": true, "GOARM64": true, "GOMIPS": true, "GOMIPS64": true,
+	"GOPPC64": true, "GORISCV64": true, "GOWASM": true,
+	"GOEXPERIMENT": true, "GOFIPS140": true, "DefaultGODEBUG": true,
+	"vcs": true, "vcs.revision": true, "vcs.time": true, "vcs.modified": true,
+}
+
+// read is a call to ReadBuildInfo, or a read of a build setting or field of
+// the returned build information.
+type read struct {
+	render.Problem
+	Kind string
+	// Name is the setting key, or the path of the field such as
+	// Main.Version.
+	Name string `json:",omitempty"`
+	// Reason describes why the read is a problem, if it is.
+	Reason string `json:",omitempty"`
+}
+
+// check determines if the read is of information which won't be set, either
+// by any build or by the provided binary.
+func (r *read) check(binary string, built *debug.BuildInfo) {
+	switch r.Kind {
+	case kindCall:
+		r.Message = "calls debug.ReadBuildInfo"
+		return
+	case kindSetting:
+		r.Message = fmt.Sprintf("reads build setting %q", r.Name)
+		if !knownSettings[r.Name] {
+			r.Reason = "not a build setting recorded by the go tool"
+		} else if built != nil && !hasSetting(built, r.Name) {
+			r.Reason = "not set in " + binary
+		}
+	case kindField:
+		r.Message = "reads build info field " + r.Name
+		if built != nil && !hasField(built, r.Name) {
+			r.Reason = "not set in " + binary
+		}
+	}
+	if r.Reason != "" {
+		r.Message += ": " + r.Reason
+	}
+}
+
+func hasSetting(bi *debug.BuildInfo, key string) bool {
+	for _, s := range bi.Settings {
+		if s.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasField(bi *debug.BuildInfo, name string) bool {
+	switch name {
+	case "GoVersion":
+		return bi.GoVersion != ""
+	case "Path":
+		return bi.Path != ""
+	case "Main.Path":
+		return bi.Main.Path != ""
+	case "Main.Version":
+		return bi.Main.Version != "" && bi.Main.Version != "(devel)"
+	case "Main.Sum":
+		return bi.Main.Sum != ""
+	case "Deps":
+		return len(bi.Deps) != 0
+	}
+	return true
+}
+
+// findReads returns the reads of build information within the package.
+func findReads(fset *token.FileSet, info *loader.PackageInfo) []*read {
+	var reads []*read
+	add := func(pos token.Pos, kind, name string) {
+		p := fset.Position(pos)
+		reads = append(reads, &read{
+			Problem: render.Problem{Filename: p.Filename, Line: p.Line, Column: p.Column},
+			Kind:    kind,
+			Name:    name,
+		})
+	}
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if sel, ok := ast.Unparen(n.Fun).(*ast.SelectorExpr); ok {
+					if f, ok := info.Uses[sel.Sel].(*types.Func); ok && f.FullName() == "runtime/debug.ReadBuildInfo" {
+						add(n.Pos(), kindCall, "")
+					}
+				}
+			case *ast.BinaryExpr:
+				if n.Op != token.EQL && n.Op != token.NEQ {
+					return true
+				}
+				if isSettingKey(info, n.X) {
+					if key, ok := stringConst(info, n.Y); ok {
+						add(n.Y.Pos(), kindSetting, key)
+					}
+				} else if isSettingKey(info, n.Y) {
+					if key, ok := stringConst(info, n.X); ok {
+						add(n.X.Pos(), kindSetting, key)
+					}
+				}
+			case *ast.SwitchStmt:
+				if n.Tag == nil || !isSettingKey(info, n.Tag) {
+					return true
+				}
+				for _, stmt := range n.Body.List {
+					for _, e := range stmt.(*ast.CaseClause).List {
+						if key, ok := stringConst(info, e); ok {
+							add(e.Pos(), kindSetting, key)
+						}
+					}
+				}
+			case *ast.SelectorExpr:
+				if name := buildInfoField(info, n); name != "" {
+					add(n.Sel.Pos(), kindField, name)
+				}
+			}
+			return true
+		})
+	}
+	return reads
+}
+
+// isSettingKey reports if the expression selects the Key field of a
+// debug.BuildSetting.
+func isSettingKey(info *loader.PackageInfo, e ast.Expr) bool {
+	sel, ok := ast.Unparen(e).(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Key" {
+		return false
+	}
+	s, ok := info.Selections[sel]
+	return ok && s.Kind() == types.FieldVal && isDebugType(s.Recv(), "BuildSetting")
+}
+
+// buildInfoField returns the path of the BuildInfo field the expression
+// selects, such as GoVersion or Main.Version, or an empty string if it
+// selects something else. Settings are reported by their keys instead.
+func buildInfoField(info *loader.PackageInfo, sel *ast.SelectorExpr) string {
+	s, ok := info.Selections[sel]
+	if !ok || s.Kind() != types.FieldVal {
+		return ""
+	}
+	switch {
+	case isDebugType(s.Recv(), "BuildInfo"):
+		switch sel.Sel.Name {
+		case "Settings", "Main":
+			// Reported by key, or as the selected field of Main.
+			return ""
+		}
+		return sel.Sel.Name
+	case isDebugType(s.Recv(), "Module"):
+		x, ok := ast.Unparen(sel.X).(*ast.SelectorExpr)
+		if !ok || x.Sel.Name != "Main" {
+			return ""
+		}
+		if xs, ok := info.Selections[x]; ok && isDebugType(xs.Recv(), "BuildInfo") {
+			return "Main." + sel.Sel.Name
+		}
+	}
+	return ""
+}
+
+// isDebugType reports if t, or the type it points to, is the named type of
+// the runtime/debug package.
+func isDebugType(t types.Type, name string) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == "runtime/debug" && named.Obj().Name() == name
+}
+
+func stringConst(info *loader.PackageInfo, e ast.Expr) (string, bool) {
+	tv, ok := info.Types[e]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
@@ -0,0 +1,87 @@
+package buildinfoaudit
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindReads(t *testing.T) {
+	src := `package p
+
+import "runtime/debug"
+
+func version() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	rev := ""
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			rev = s.Value
+		}
+		switch s.Key {
+		case "vcs.modified", "vcs.dirty":
+		}
+	}
+	return bi.Main.Version + bi.GoVersion + rev
+}
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := findReads(prog.Fset, prog.Created[0])
+
+	// Without a binary, only keys the go tool never records are problems.
+	var got []string
+	for _, r := range reads {
+		r.check("", nil)
+		got = append(got, fmt.Sprintf("%d:%d: %s: %s", r.Line, r.Column, r.Kind, r.Message))
+	}
+	want := []string{
+		"6:12: call: calls debug.ReadBuildInfo",
+		"12:15: setting: reads build setting \"vcs.revision\"",
+		"16:8: setting: reads build setting \"vcs.modified\"",
+		"16:24: setting: reads build setting \"vcs.dirty\": not a build setting recorded by the go tool",
+		"19:17: field: reads build info field Main.Version",
+		"19:30: field: reads build info field GoVersion",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reads:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// A binary built outside of version control without a module version.
+	built := &debug.BuildInfo{
+		GoVersion: "go1.22.0",
+		Main:      debug.Module{Path: "example.com/p", Version: "(devel)"},
+		Settings:  []debug.BuildSetting{{Key: "vcs.modified", Value: "false"}},
+	}
+	var reasons []string
+	for _, r := range reads {
+		r.Reason, r.Message = "", ""
+		r.check("p.bin", built)
+		if r.Reason != "" {
+			reasons = append(reasons, r.Name+": "+r.Reason)
+		}
+	}
+	wantReasons := []string{
+		"vcs.revision: not set in p.bin",
+		"vcs.dirty: not a build setting recorded by the go tool",
+		"Main.Version: not set in p.bin",
+	}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("expected problems %q, got %q", wantReasons, reasons)
+	}
+}
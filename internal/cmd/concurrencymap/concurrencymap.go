@@ -0,0 +1,266 @@
+// Package concurrencymap implements goconcurrency-map, which inventories the
+// goroutines, channel operations and sync primitives of packages.
+package concurrencymap
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: goconcurrency-map [flags] <list of packages>
+
+goconcurrency-map counts the concurrency constructs in each of the provided
+packages and prints a table of the totals. Constructs are:
+
+	go	go statements.
+	make	Channels created by make.
+	send	Channel sends, including in select cases.
+	recv	Channel receives, including in select cases and range loops.
+	close	Calls to close.
+	select	select statements.
+	sync	Calls to methods of package sync, such as Mutex.Lock.
+	atomic	Calls to functions and methods of package sync/atomic.
+
+Flags:
+
+	-l	After the totals, list the location of each construct.
+
+	-json	Print a JSON object for each package with Package, Totals and
+		Uses fields. Totals maps each construct to its count, and Uses
+		lists the location of each with Filename, Line, Column, Kind and
+		Detail fields.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+`
+
+// Command runs goconcurrency-map as the concurrency-map subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "concurrency-map",
+	Tool:    "goconcurrency-map",
+	Summary: "count goroutines, channel operations and sync primitives",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs goconcurrency-map with the provided arguments.
+func Run(args []string) {
+	list := false
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("goconcurrency-map", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&list, "l", false, "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	var inventories []*inventory
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		inventories = append(inventories, findUses(prog.Fset, info))
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, inv := range inventories {
+			if err := enc.Encode(inv); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+	if err := printTotals(os.Stdout, inventories); err != nil {
+		fatal(err)
+	}
+	if list {
+		var diags []render.Diagnostic
+		for _, inv := range inventories {
+			for _, u := range inv.Uses {
+				diags = append(diags, u)
+			}
+		}
+		if err := render.PrintProblems(os.Stdout, false, diags); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// Kinds of constructs, in the order they're printed.
+var kinds = []string{"go", "make", "send", "recv", "close", "select", "sync", "atomic"}
+
+// inventory is the concurrency constructs of a package.
+type inventory struct {
+	Package string
+	Totals  map[string]int
+	Uses    []*use
+}
+
+// use is the location of a construct.
+type use struct {
+	render.Problem
+	Kind string
+	// Detail describes the construct, such as the type of a channel or
+	// the method called.
+	Detail string `json:",omitempty"`
+}
+
+// findUses returns the constructs within a package.
+func findUses(fset *token.FileSet, info *loader.PackageInfo) *inventory {
+	inv := &inventory{Package: info.Pkg.Path(), Totals: make(map[string]int), Uses: []*use{}}
+	for _, kind := range kinds {
+		inv.Totals[kind] = 0
+	}
+	add := func(n ast.Node, kind, detail string) {
+		p := fset.Position(n.Pos())
+		inv.Totals[kind]++
+		inv.Uses = append(inv.Uses, &use{
+			Problem: render.Problem{Filename: p.Filename, Line: p.Line, Column: p.Column, Message: kind + ": " + detail},
+			Kind:    kind,
+			Detail:  detail,
+		})
+	}
+	typeString := func(t types.Type) string {
+		return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+	}
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.GoStmt:
+				add(n, "go", types.ExprString(n.Call.Fun))
+			case *ast.SendStmt:
+				add(n, "send", types.ExprString(n.Chan))
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					add(n, "recv", types.ExprString(n.X))
+				}
+			case *ast.RangeStmt:
+				if t := info.TypeOf(n.X); t != nil {
+					if _, ok := t.Underlying().(*types.Chan); ok {
+						add(n, "recv", types.ExprString(n.X))
+					}
+				}
+			case *ast.SelectStmt:
+				add(n, "select", fmt.Sprintf("%d cases", len(n.Body.List)))
+			case *ast.CallExpr:
+				if b, ok := calledBuiltin(info, n); ok {
+					switch {
+					case b == "make" && len(n.Args) > 0 && isChan(info.TypeOf(n.Args[0])):
+						add(n, "make", typeString(info.TypeOf(n.Args[0])))
+					case b == "close" && len(n.Args) == 1:
+						add(n, "close", types.ExprString(n.Args[0]))
+					}
+					return true
+				}
+				sel, ok := ast.Unparen(n.Fun).(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				f, ok := info.Uses[sel.Sel].(*types.Func)
+				if !ok || f.Pkg() == nil {
+					return true
+				}
+				switch f.Pkg().Path() {
+				case "sync":
+					add(n, "sync", funcName(f))
+				case "sync/atomic":
+					add(n, "atomic", funcName(f))
+				}
+			}
+			return true
+		})
+	}
+	return inv
+}
+
+// calledBuiltin returns the name of the builtin function a call calls.
+func calledBuiltin(info *loader.PackageInfo, call *ast.CallExpr) (string, bool) {
+	ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	b, ok := info.Uses[ident].(*types.Builtin)
+	if !ok {
+		return "", false
+	}
+	return b.Name(), true
+}
+
+func isChan(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+// funcName returns the name of a function or method without its package,
+// such as "Mutex.Lock" or "AddInt64".
+func funcName(f *types.Func) string {
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return f.Name()
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		return named.Obj().Name() + "." + f.Name()
+	}
+	return f.Name()
+}
+
+// printTotals prints a table of the number of each construct in each
+// package.
+func printTotals(w io.Writer, inventories []*inventory) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "PACKAGE\t%s\n", strings.ToUpper(strings.Join(kinds, "\t")))
+	for _, inv := range inventories {
+		fmt.Fprint(tw, inv.Package)
+		for _, kind := range kinds {
+			fmt.Fprintf(tw, "\t%d", inv.Totals[kind])
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,87 @@
+package concurrencymap
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindUses(t *testing.T) {
+	src := `package p
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type counter struct {
+	mu sync.Mutex
+	n  atomic.Int64
+}
+
+func run(c *counter, done chan struct{}) {
+	results := make(chan int, 1)
+	go func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		results <- 1
+	}()
+	select {
+	case n := <-results:
+		c.n.Add(int64(n))
+	case <-done:
+	}
+	close(results)
+	for range results {
+	}
+	_ = make([]int, 1)
+}
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := findUses(prog.Fset, prog.Created[0])
+
+	var got []string
+	for _, u := range inv.Uses {
+		got = append(got, fmt.Sprintf("%d:%d: %s", u.Line, u.Column, u.Message))
+	}
+	want := []string{
+		"14:13: make: chan int",
+		"15:2: go: (func() literal)",
+		"16:3: sync: Mutex.Lock",
+		"17:9: sync: Mutex.Unlock",
+		"18:3: send: results",
+		"20:2: select: 2 cases",
+		"21:12: recv: results",
+		"22:3: atomic: Int64.Add",
+		"23:7: recv: done",
+		"25:2: close: results",
+		"26:2: recv: results",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected uses:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := printTotals(&buf, []*inventory{inv}); err != nil {
+		t.Fatal(err)
+	}
+	wantTable := `PACKAGE  GO  MAKE  SEND  RECV  CLOSE  SELECT  SYNC  ATOMIC
+p        1   1     1     3     1      1       2     1
+`
+	if buf.String() != wantTable {
+		t.Errorf("expected table:\n%s\ngot:\n%s", wantTable, buf.String())
+	}
+}
@@ -0,0 +1,287 @@
+// Package derivecallersofinterface implements goderive-callersofinterface,
+// which lists the interfaces a concrete method can be called through and the
+// call sites dispatching through each of them.
+package derivecallersofinterface
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: goderive-callersofinterface [flags] <method expression> [packages]
+
+goderive-callersofinterface lists the interfaces a concrete method can be
+called through, and the call sites within the provided packages which
+dispatch through each of them. That is, the calls which may be affected by a
+change to the method's behavior without naming it.
+
+The expression names a method of a type using the syntax of gosearch.
+
+	goderive-callersofinterface 'bytes.Buffer.Write' ./...
+
+An interface is listed if it's declared in any loaded package, has a method
+of the same name, and is implemented by the type or a pointer to it. Call
+sites through interface literals, such as interface{ Write([]byte) (int,
+error) }, are listed too. Method values and method expressions are reported
+as call sites. If no packages are provided, every package of the module
+containing the current directory is searched.
+
+Flags:
+
+	-json	Print each interface as a JSON object.
+
+	-t	Load and search *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+`
+
+// Command runs goderive-callersofinterface as the derive-callersofinterface subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "derive-callersofinterface",
+	Tool:    "goderive-callersofinterface",
+	Summary: "list the calls dispatching to a method through interfaces",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs goderive-callersofinterface with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("goderive-callersofinterface", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) == 0 || args[0] == "" {
+		fatal(help)
+	}
+	target, err := resolve.Parse(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	if target.Kind != "" {
+		fatal("expression must name a method, not " + target.Kind + ":")
+	}
+	patterns := args[1:]
+	if len(patterns) == 0 {
+		root, _, err := load.FindModule(".")
+		if err != nil {
+			fatal(err)
+		}
+		pattern, err := load.ModulePattern(root)
+		if err != nil {
+			fatal(err)
+		}
+		patterns = []string{pattern}
+	}
+
+	pkgs, err := load.GoList{}.List(patterns...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(append([]string{target.Pkg}, pkgs...)...)
+	if err != nil {
+		fatal(err)
+	}
+	obj, err := target.Lookup(prog.Imported[target.Pkg])
+	if err != nil {
+		fatal(err)
+	}
+	recv, ok := concreteReceiver(obj)
+	if !ok {
+		fatal(args[0] + " is not a method of a concrete type")
+	}
+
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		if info := prog.Imported[pkg]; len(info.Errors) == 0 {
+			searched = append(searched, info)
+		}
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	ifaces := findInterfaces(prog, recv, obj.Name())
+	if err := findCalls(prog.Fset, searched, recv, obj.Name(), ifaces); err != nil {
+		fatal(err)
+	}
+	if err := printInterfaces(ifaces, jsonOutput); err != nil {
+		fatal(err)
+	}
+}
+
+// concreteReceiver returns the named, non-generic receiver type of a method.
+func concreteReceiver(obj types.Object) (*types.Named, bool) {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return nil, false
+	}
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil, false
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || types.IsInterface(named) || named.TypeParams().Len() != 0 {
+		return nil, false
+	}
+	return named, true
+}
+
+// iface is an interface the method can be called through.
+type iface struct {
+	// Interface is the name of the interface type.
+	Interface string
+	// ID is the stable identifier of a named interface.
+	ID    string `json:",omitempty"`
+	Calls []*call
+}
+
+// call is a selection of the method through an interface.
+type call struct {
+	Filename string
+	Line     int
+	Column   int
+	Text     string
+}
+
+// implements reports if the type or a pointer to it implements an interface
+// with a method of the provided name.
+func implements(recv *types.Named, t types.Type, name string) bool {
+	it, ok := t.Underlying().(*types.Interface)
+	if !ok || !it.IsMethodSet() {
+		return false
+	}
+	for i := 0; i < it.NumMethods(); i++ {
+		if it.Method(i).Name() == name {
+			return types.Implements(recv, it) || types.Implements(types.NewPointer(recv), it)
+		}
+	}
+	return false
+}
+
+// findInterfaces returns the named interfaces declared in the program which
+// the receiver satisfies and which have a method of the provided name.
+func findInterfaces(prog *loader.Program, recv *types.Named, name string) map[string]*iface {
+	ifaces := make(map[string]*iface)
+	for pkg, info := range prog.AllPackages {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		scope := pkg.Scope()
+		for _, n := range scope.Names() {
+			tn, ok := scope.Lookup(n).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() != 0 || !implements(recv, named, name) {
+				continue
+			}
+			s := types.TypeString(named, nil)
+			ifaces[s] = &iface{Interface: s, ID: resolve.ID(tn)}
+		}
+	}
+	return ifaces
+}
+
+// findCalls adds the selections of the method through an interface within
+// the packages, adding any interfaces which weren't declared at package
+// level, such as interface literals.
+func findCalls(fset *token.FileSet, pkgs []*loader.PackageInfo, recv *types.Named, name string, ifaces map[string]*iface) error {
+	var idents []*ast.Ident
+	recvs := make(map[*ast.Ident]types.Type)
+	for _, info := range pkgs {
+		for sel, s := range info.Selections {
+			if sel.Sel.Name != name || s.Kind() == types.FieldVal {
+				continue
+			}
+			t := s.Recv()
+			if _, ok := t.(*types.TypeParam); ok || !implements(recv, t, name) {
+				continue
+			}
+			idents = append(idents, sel.Sel)
+			recvs[sel.Sel] = t
+		}
+	}
+	sort.Sort(match.ByPos(idents))
+
+	cwd, _ := os.Getwd()
+	reader := new(match.Reader)
+	for _, ident := range idents {
+		m, err := reader.Read(fset, ident)
+		if err != nil {
+			return err
+		}
+		t := recvs[ident]
+		s := types.TypeString(t, nil)
+		i, ok := ifaces[s]
+		if !ok {
+			i = &iface{Interface: s}
+			ifaces[s] = i
+		}
+		filename := m.Filename
+		if rel, err := filepath.Rel(cwd, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			filename = rel
+		}
+		i.Calls = append(i.Calls, &call{filename, m.Line, m.Column, strings.TrimSpace(m.Text)})
+	}
+	return nil
+}
+
+// printInterfaces prints the interfaces with the most call sites first,
+// followed by each of the call sites.
+func printInterfaces(ifaces map[string]*iface, asJSON bool) error {
+	var list []*iface
+	for _, i := range ifaces {
+		list = append(list, i)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if len(list[a].Calls) != len(list[b].Calls) {
+			return len(list[a].Calls) > len(list[b].Calls)
+		}
+		return list[a].Interface < list[b].Interface
+	})
+	enc := json.NewEncoder(os.Stdout)
+	for _, i := range list {
+		if asJSON {
+			if err := enc.Encode(i); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%s (%d call sites)\n", i.Interface, len(i.Calls))
+		for _, c := range i.Calls {
+			fmt.Printf("\t%s:%d:%d: %s\n", c.Filename, c.Line, c.Column, c.Text)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package derivecallersofinterface
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindCalls(t *testing.T) {
+	src := `package p
+
+import "io"
+
+type Buffer struct{}
+
+func (b *Buffer) Write(p []byte) (int, error) { return len(p), nil }
+
+type Writer interface{ Write([]byte) (int, error) }
+
+type WriteFlusher interface {
+	Writer
+	Flush() error
+}
+
+func use(w io.Writer, pw Writer, wf WriteFlusher, b *Buffer) {
+	w.Write(nil)
+	pw.Write(nil)
+	f := pw.Write
+	_ = f
+	wf.Write(nil)
+	b.Write(nil)
+	interface{ Write([]byte) (int, error) }(b).Write(nil)
+}
+`
+	// Call sites are read from disk.
+	dir, err := ioutil.TempDir("", "goderive-callersofinterface")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := loader.Config{}
+	f, err := conf.ParseFile(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := resolve.Parse("p.Buffer.Write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := target.Lookup(prog.Created[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv, ok := concreteReceiver(obj)
+	if !ok {
+		t.Fatalf("%s has no concrete receiver", obj)
+	}
+	ifaces := findInterfaces(prog, recv, obj.Name())
+	if err := findCalls(prog.Fset, prog.Created, recv, obj.Name(), ifaces); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, i := range ifaces {
+		s := i.Interface
+		if i.ID != "" {
+			s += " (" + i.ID + ")"
+		}
+		for _, c := range i.Calls {
+			s += fmt.Sprintf(" %s:%d: %s;", filepath.Base(c.Filename), c.Line, c.Text)
+		}
+		got = append(got, s)
+	}
+	sort.Strings(got)
+	want := []string{
+		"interface{Write([]byte) (int, error)} p.go:23: interface{ Write([]byte) (int, error) }(b).Write(nil);",
+		"io.Writer (io Writer) p.go:17: w.Write(nil);",
+		"p.Writer (p Writer) p.go:18: pw.Write(nil); p.go:19: f := pw.Write;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected interfaces:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
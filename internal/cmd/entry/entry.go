@@ -0,0 +1,351 @@
+// Package entry implements goentry, which lists the entry points of a set of
+// packages: functions invoked by the runtime, the go tool or other programs
+// rather than called from Go code.
+package entry
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: goentry [flags] <list of packages>
+
+goentry lists the entry points of the provided packages: functions which are
+invoked by the runtime, the go tool, or other programs rather than called
+from Go code. These are the roots other tools, such as dead code and call
+graph analyses, should start from.
+
+Each entry point has one of the following kinds.
+
+	main		The main function of a command.
+	init		A package init function.
+	plugin		An exported function or variable of package main, which
+			may be looked up when built with -buildmode=plugin.
+	cgo		A function exported to C by an //export comment.
+	test		A test, benchmark, example, fuzz target or TestMain.
+	http		A handler registered with net/http.
+	rpc		A receiver registered with net/rpc.
+
+Flags:
+
+	-json	Print each entry point as a JSON object with the fields Kind,
+		Symbol, ID, Filename, Line and Column, and Detail for the pattern
+		of HTTP handlers. Symbols are formatted as by go/types, for
+		example "(*net/http.Server).Serve". IDs are stable identifiers
+		of functions, the package path and objectpath such as
+		"net/http Server.M15", shared with the repo's other tools.
+
+	-t	Load *_test.go files and list test entry points.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+`
+
+// Command runs goentry as the entry subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "entry",
+	Tool:    "goentry",
+	Summary: "list the entry points of packages",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs goentry with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	conf := load.Config{Comments: true}
+	fs := flag.NewFlagSet("goentry", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+	var entries []entry
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		entries = append(entries, findEntries(prog.Fset, info)...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if jsonOutput {
+			if err := enc.Encode(e); err != nil {
+				fatal(err)
+			}
+			continue
+		}
+		fmt.Printf("%s:%d:%d: %s\t%s", e.Filename, e.Line, e.Column, e.Kind, e.Symbol)
+		if e.Detail != "" {
+			fmt.Printf("\t%s", e.Detail)
+		}
+		fmt.Println()
+	}
+}
+
+// Kinds of entry points.
+const (
+	kindMain   = "main"
+	kindInit   = "init"
+	kindPlugin = "plugin"
+	kindCgo    = "cgo"
+	kindTest   = "test"
+	kindHTTP   = "http"
+	kindRPC    = "rpc"
+)
+
+// entry is an entry point. The JSON encoding is the format shared with other
+// tools which consume roots.
+type entry struct {
+	Kind     string
+	Symbol   string
+	ID       string `json:",omitempty"`
+	Filename string
+	Line     int
+	Column   int
+	Detail   string `json:",omitempty"`
+}
+
+// newEntry returns an entry point at pos. obj is the entry point's function
+// or variable, if any.
+func newEntry(fset *token.FileSet, pos token.Pos, kind, symbol string, obj types.Object) entry {
+	p := fset.Position(pos)
+	e := entry{Kind: kind, Symbol: symbol, Filename: p.Filename, Line: p.Line, Column: p.Column}
+	if obj != nil {
+		e.ID = resolve.ID(obj)
+	}
+	return e
+}
+
+// findEntries returns the entry points declared or registered within the
+// package.
+func findEntries(fset *token.FileSet, info *loader.PackageInfo) []entry {
+	var entries []entry
+	isMain := info.Pkg.Name() == "main"
+	for _, file := range info.Files {
+		isTest := strings.HasSuffix(fset.Position(file.Package).Filename, "_test.go")
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if kind := funcKind(decl, isMain, isTest); kind != "" {
+					entries = append(entries, newEntry(fset, decl.Name.Pos(), kind, symbol(info.Defs[decl.Name]), info.Defs[decl.Name]))
+				}
+			case *ast.GenDecl:
+				if !isMain || isTest || decl.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						if name.IsExported() {
+							entries = append(entries, newEntry(fset, name.Pos(), kindPlugin, symbol(info.Defs[name]), info.Defs[name]))
+						}
+					}
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if e, ok := registration(fset, info, call); ok {
+					entries = append(entries, e)
+				}
+			}
+			return true
+		})
+	}
+	return entries
+}
+
+// funcKind returns the kind of entry point a function declaration is, or an
+// empty string if it isn't one.
+func funcKind(decl *ast.FuncDecl, isMain, isTest bool) string {
+	name := decl.Name.Name
+	if decl.Recv == nil {
+		switch {
+		case name == "init":
+			return kindInit
+		case name == "main" && isMain:
+			return kindMain
+		case isTest && isTestFunc(name):
+			return kindTest
+		}
+	}
+	if decl.Doc != nil {
+		for _, c := range decl.Doc.List {
+			if c.Text == "//export "+name {
+				return kindCgo
+			}
+		}
+	}
+	if isMain && !isTest && decl.Recv == nil && decl.Name.IsExported() {
+		return kindPlugin
+	}
+	return ""
+}
+
+// isTestFunc reports if the name is one the go tool runs from a test file.
+func isTestFunc(name string) bool {
+	if name == "TestMain" {
+		return true
+	}
+	for _, prefix := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if rest == "" || prefix == "Example" && rest[0] == '_' {
+			return true
+		}
+		r, _ := utf8.DecodeRuneInString(rest)
+		return !unicode.IsLower(r)
+	}
+	return false
+}
+
+// registrations maps functions and methods which register handlers to the
+// kind of entry point and the index of the registered argument.
+var registrations = map[string]struct {
+	kind string
+	arg  int
+}{
+	"net/http.Handle":                 {kindHTTP, 1},
+	"net/http.HandleFunc":             {kindHTTP, 1},
+	"(*net/http.ServeMux).Handle":     {kindHTTP, 1},
+	"(*net/http.ServeMux).HandleFunc": {kindHTTP, 1},
+	"net/rpc.Register":                {kindRPC, 0},
+	"net/rpc.RegisterName":            {kindRPC, 1},
+	"(*net/rpc.Server).Register":      {kindRPC, 0},
+	"(*net/rpc.Server).RegisterName":  {kindRPC, 1},
+	"net/http.ListenAndServe":         {kindHTTP, 1},
+	"net/http.ListenAndServeTLS":      {kindHTTP, 3},
+	"net/http.Serve":                  {kindHTTP, 1},
+	"net/http.ServeTLS":               {kindHTTP, 1},
+}
+
+// registration returns the entry point registered by a call.
+func registration(fset *token.FileSet, info *loader.PackageInfo, call *ast.CallExpr) (entry, bool) {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return entry{}, false
+	}
+	f, ok := info.Uses[id].(*types.Func)
+	if !ok {
+		return entry{}, false
+	}
+	r, ok := registrations[f.FullName()]
+	if !ok || r.arg >= len(call.Args) {
+		return entry{}, false
+	}
+	arg := call.Args[r.arg]
+	if isNil(info, arg) {
+		// Serving the default ServeMux registers nothing new.
+		return entry{}, false
+	}
+	sym, obj := handlerSymbol(info, arg)
+	e := newEntry(fset, arg.Pos(), r.kind, sym, obj)
+	if (r.kind == kindHTTP && strings.HasPrefix(f.Name(), "Handle")) || (r.kind == kindRPC && r.arg == 1) {
+		// The pattern or name the handler is registered under.
+		if tv, ok := info.Types[call.Args[0]]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+			e.Detail = constant.StringVal(tv.Value)
+		}
+	}
+	return e, true
+}
+
+func isNil(info *loader.PackageInfo, e ast.Expr) bool {
+	tv, ok := info.Types[e]
+	return ok && tv.IsNil()
+}
+
+// handlerSymbol names the function or type of a registered handler, and
+// returns the function if the handler is one.
+func handlerSymbol(info *loader.PackageInfo, e ast.Expr) (string, types.Object) {
+	e = ast.Unparen(e)
+	var id *ast.Ident
+	switch x := e.(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	case *ast.CallExpr:
+		// Conversions such as http.HandlerFunc(f).
+		if tv, ok := info.Types[x.Fun]; ok && tv.IsType() && len(x.Args) == 1 {
+			return handlerSymbol(info, x.Args[0])
+		}
+	}
+	if id != nil {
+		if f, ok := info.Uses[id].(*types.Func); ok {
+			return symbol(f), f
+		}
+	}
+	if _, ok := e.(*ast.FuncLit); ok {
+		return "func literal", nil
+	}
+	if t := info.TypeOf(e); t != nil {
+		return types.TypeString(t, nil), nil
+	}
+	return types.ExprString(e), nil
+}
+
+func symbol(obj types.Object) string {
+	if obj == nil {
+		return ""
+	}
+	if f, ok := obj.(*types.Func); ok {
+		return f.FullName()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
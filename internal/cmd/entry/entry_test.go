@@ -0,0 +1,129 @@
+package entry
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindEntries(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+import (
+	"net/http"
+	"net/rpc"
+)
+
+var Version = "dev"
+
+var debug bool
+
+func init() {}
+
+func main() {
+	http.HandleFunc("/health", health)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.HandlerFunc(api))
+	rpc.Register(new(Arith))
+	http.ListenAndServe(":8080", nil)
+}
+
+func health(w http.ResponseWriter, r *http.Request) {}
+
+func api(w http.ResponseWriter, r *http.Request) {}
+
+type Arith struct{}
+
+//export Add
+func Add(a, b int) int { return a + b }
+
+func Plugin() {}
+
+func helper() {}
+`,
+		"main_test.go": `package main
+
+import "testing"
+
+func TestMain(m *testing.M) {}
+
+func TestAdd(t *testing.T) {}
+
+func Testing() {}
+
+func BenchmarkAdd(b *testing.B) {}
+
+func ExampleAdd_negative() {}
+
+func FuzzAdd(f *testing.F) {}
+`,
+	}
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	var parsed []*ast.File
+	for _, name := range []string{"main.go", "main_test.go"} {
+		f, err := conf.ParseFile(name, files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, f)
+	}
+	conf.CreateFromFiles("example.com/cmd", parsed...)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range findEntries(prog.Fset, prog.Created[0]) {
+		s := fmt.Sprintf("%s:%d: %s %s", e.Filename, e.Line, e.Kind, e.Symbol)
+		if e.Detail != "" {
+			s += " " + e.Detail
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"main.go:8: plugin example.com/cmd.Version",
+		"main.go:12: init example.com/cmd.init",
+		"main.go:14: main example.com/cmd.main",
+		"main.go:29: cgo example.com/cmd.Add",
+		"main.go:31: plugin example.com/cmd.Plugin",
+		"main.go:15: http example.com/cmd.health /health",
+		"main.go:17: http example.com/cmd.api /api/",
+		"main.go:18: rpc *example.com/cmd.Arith",
+		"main_test.go:5: test example.com/cmd.TestMain",
+		"main_test.go:7: test example.com/cmd.TestAdd",
+		"main_test.go:11: test example.com/cmd.BenchmarkAdd",
+		"main_test.go:13: test example.com/cmd.ExampleAdd_negative",
+		"main_test.go:15: test example.com/cmd.FuzzAdd",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected entries:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestIsTestFunc(t *testing.T) {
+	tests := map[string]bool{
+		"Test":            true,
+		"TestParse":       true,
+		"Test_parse":      true,
+		"Testing":         false,
+		"Example":         true,
+		"Example_suffix":  true,
+		"Examples":        false,
+		"BenchmarkÜber":   true,
+		"Benchmarkparse":  false,
+		"TestMain":        true,
+		"helperTestParse": false,
+	}
+	for name, want := range tests {
+		if got := isTestFunc(name); got != want {
+			t.Errorf("isTestFunc(%q): expected %t, got %t", name, want, got)
+		}
+	}
+}
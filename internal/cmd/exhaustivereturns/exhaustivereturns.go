@@ -0,0 +1,360 @@
+// Package exhaustivereturns implements goexhaustive-returns, which finds
+// functions whose named results are returned before they're set, or which
+// defer modifications of a variable shadowing a named result.
+package exhaustivereturns
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: goexhaustive-returns [flags] <list of packages>
+
+goexhaustive-returns reports functions with named results which may return
+values other than intended:
+
+	zero	A bare return appears before any assignment to results which
+		are assigned elsewhere in the function, so those results are
+		returned as their zero values. For example, an early return
+		of a nil error from a function which sets err later.
+	defer	A deferred function literal meant to modify a named result
+		modifies another variable instead: it declares a variable with
+		the result's name, or assigns to a variable shadowing it.
+
+Assignments are considered in source order, so a result assigned within a
+loop after a bare return isn't considered set at that return.
+
+Flags:
+
+	-json	Print each problem as a JSON object.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+goexhaustive-returns exits with status 1 if any problems are found.
+`
+
+// Command runs goexhaustive-returns as the exhaustive-returns subcommand of
+// gotools.
+var Command = &cli.Command{
+	Name:    "exhaustive-returns",
+	Tool:    "goexhaustive-returns",
+	Summary: "find named results returned before they're set",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs goexhaustive-returns with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("goexhaustive-returns", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	var diags []render.Diagnostic
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		for _, p := range findProblems(prog.Fset, info) {
+			diags = append(diags, p)
+		}
+	}
+	if err := render.PrintProblems(os.Stdout, jsonOutput, diags); err != nil {
+		fatal(err)
+	}
+	if len(diags) != 0 {
+		os.Exit(1)
+	}
+}
+
+// Kinds of problems.
+const (
+	kindZero  = "zero"
+	kindDefer = "defer"
+)
+
+type problem struct {
+	render.Problem
+	Kind     string
+	Function string
+	// Results are the names of the affected results.
+	Results []string
+}
+
+// findProblems checks every function declared in the package.
+func findProblems(fset *token.FileSet, info *loader.PackageInfo) []*problem {
+	var problems []*problem
+	report := func(pos token.Pos, kind, fn string, results []string, format string, a ...interface{}) {
+		p := fset.Position(pos)
+		problems = append(problems, &problem{
+			Problem:  render.Problem{Filename: p.Filename, Line: p.Line, Column: p.Column, Message: fmt.Sprintf(format, a...)},
+			Kind:     kind,
+			Function: fn,
+			Results:  results,
+		})
+	}
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			var (
+				name string
+				sig  *types.Signature
+				body *ast.BlockStmt
+			)
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Body == nil {
+					return false
+				}
+				obj, ok := info.Defs[n.Name].(*types.Func)
+				if !ok {
+					return true
+				}
+				name, sig, body = n.Name.Name, obj.Type().(*types.Signature), n.Body
+			case *ast.FuncLit:
+				var ok bool
+				if sig, ok = info.Types[n].Type.(*types.Signature); !ok {
+					return true
+				}
+				name, body = "func literal", n.Body
+			default:
+				return true
+			}
+			results := namedResults(sig)
+			if len(results) == 0 {
+				return true
+			}
+			f := &function{info: info, fset: fset, name: name, results: results, body: body}
+			f.checkZero(report)
+			f.checkDefers(report)
+			return true
+		})
+	}
+	return problems
+}
+
+// namedResults returns the results of a signature which have names other
+// than the blank identifier.
+func namedResults(sig *types.Signature) []*types.Var {
+	var vars []*types.Var
+	for i := 0; i < sig.Results().Len(); i++ {
+		v := sig.Results().At(i)
+		if v.Name() != "" && v.Name() != "_" {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+type reportFunc func(pos token.Pos, kind, fn string, results []string, format string, a ...interface{})
+
+// function is a function with named results.
+type function struct {
+	info    *loader.PackageInfo
+	fset    *token.FileSet
+	name    string
+	results []*types.Var
+	body    *ast.BlockStmt
+}
+
+// inspectBody calls fn for every node in the function's body, excluding the
+// bodies of nested function literals unless nested is true.
+func (f *function) inspectBody(nested bool, fn func(ast.Node)) {
+	ast.Inspect(f.body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok && !nested {
+			return false
+		}
+		if n != nil {
+			fn(n)
+		}
+		return true
+	})
+}
+
+// assignments returns the positions at which each result is assigned,
+// including through assignments to its fields or elements, increments,
+// and taking its address.
+func (f *function) assignments() map[*types.Var][]token.Pos {
+	isResult := make(map[types.Object]*types.Var)
+	for _, v := range f.results {
+		isResult[v] = v
+	}
+	assigned := make(map[*types.Var][]token.Pos)
+	mark := func(e ast.Expr, pos token.Pos) {
+		// Assignments to fields, elements and dereferences modify the
+		// root variable.
+		for {
+			switch x := e.(type) {
+			case *ast.SelectorExpr:
+				e = x.X
+				continue
+			case *ast.IndexExpr:
+				e = x.X
+				continue
+			case *ast.StarExpr:
+				e = x.X
+				continue
+			case *ast.ParenExpr:
+				e = x.X
+				continue
+			}
+			break
+		}
+		id, ok := e.(*ast.Ident)
+		if !ok {
+			return
+		}
+		obj := f.info.Uses[id]
+		if obj == nil {
+			obj = f.info.Defs[id]
+		}
+		if v, ok := isResult[obj]; ok {
+			assigned[v] = append(assigned[v], pos)
+		}
+	}
+	f.inspectBody(true, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				mark(lhs, n.Pos())
+			}
+		case *ast.IncDecStmt:
+			mark(n.X, n.Pos())
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					mark(n.Key, n.Pos())
+				}
+				if n.Value != nil {
+					mark(n.Value, n.Pos())
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				mark(n.X, n.Pos())
+			}
+		}
+	})
+	return assigned
+}
+
+// checkZero reports bare returns which precede every assignment of results
+// that are assigned elsewhere.
+func (f *function) checkZero(report reportFunc) {
+	assigned := f.assignments()
+	f.inspectBody(false, func(n ast.Node) {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 0 {
+			return
+		}
+		var zero []string
+		var later token.Pos
+		for _, v := range f.results {
+			positions := assigned[v]
+			if len(positions) == 0 || positions[0] < ret.Pos() {
+				// Never assigned, so zero is the only value it's
+				// returned as, or assigned before the return.
+				continue
+			}
+			zero = append(zero, v.Name())
+			if later == token.NoPos || positions[0] < later {
+				later = positions[0]
+			}
+		}
+		if len(zero) == 0 {
+			return
+		}
+		msg := zero[0] + " at its zero value, but it's"
+		if len(zero) > 1 {
+			msg = strings.Join(zero, ", ") + " at their zero values, but they're"
+		}
+		report(ret.Pos(), kindZero, f.name, zero, "bare return in %s leaves %s first assigned at line %d", f.name, msg, f.fset.Position(later).Line)
+	})
+}
+
+// checkDefers reports deferred function literals which modify a variable
+// other than the named result they share a name with.
+func (f *function) checkDefers(report reportFunc) {
+	byName := make(map[string]*types.Var)
+	for _, v := range f.results {
+		byName[v.Name()] = v
+	}
+	f.inspectBody(false, func(n ast.Node) {
+		d, ok := n.(*ast.DeferStmt)
+		if !ok {
+			return
+		}
+		lit, ok := d.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			return
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok {
+				return true
+			}
+			for _, lhs := range assign.Lhs {
+				id, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				result, ok := byName[id.Name]
+				if !ok {
+					continue
+				}
+				if v, ok := f.info.Defs[id].(*types.Var); ok && v != result {
+					report(id.Pos(), kindDefer, f.name, []string{id.Name},
+						"deferred function in %s declares %s, shadowing the named result it may be meant to modify", f.name, id.Name)
+					continue
+				}
+				v, ok := f.info.Uses[id].(*types.Var)
+				if !ok || v == result || v.Pos() >= lit.Pos() || v.Pos() < f.body.Pos() {
+					// The result itself, or a variable declared
+					// within the deferred function.
+					continue
+				}
+				report(id.Pos(), kindDefer, f.name, []string{id.Name},
+					"deferred function in %s assigns to %s declared at line %d, which shadows the named result", f.name, id.Name, f.fset.Position(v.Pos()).Line)
+			}
+			return true
+		})
+	})
+}
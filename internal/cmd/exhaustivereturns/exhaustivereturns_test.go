@@ -0,0 +1,105 @@
+package exhaustivereturns
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+const src = `package p
+
+import "errors"
+
+func early(ok bool) (n int, err error) {
+	if !ok {
+		return
+	}
+	n = 1
+	err = errors.New("late")
+	return
+}
+
+func assigned() (err error) {
+	err = errors.New("set")
+	return
+}
+
+func deferred() (err error) {
+	defer func() {
+		err := recover()
+		_ = err
+	}()
+	if true {
+		err := errors.New("inner")
+		defer func() {
+			err = nil
+		}()
+		_ = err
+	}
+	return nil
+}
+`
+
+func findAll(t *testing.T) []render.Diagnostic {
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []render.Diagnostic
+	for _, p := range findProblems(prog.Fset, prog.Created[0]) {
+		diags = append(diags, p)
+	}
+	return diags
+}
+
+func TestFindProblems(t *testing.T) {
+	var buf bytes.Buffer
+	if err := render.PrintProblems(&buf, false, findAll(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"p.go:7:3: bare return in early leaves n, err at their zero values, but they're first assigned at line 9",
+		"p.go:21:3: deferred function in deferred declares err, shadowing the named result it may be meant to modify",
+		"p.go:27:4: deferred function in deferred assigns to err declared at line 25, which shadows the named result",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), buf.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := render.PrintProblems(&buf, true, findAll(t)); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	var got problem
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := problem{
+		Problem: render.Problem{
+			Filename: "p.go",
+			Line:     7,
+			Column:   3,
+			Message:  "bare return in early leaves n, err at their zero values, but they're first assigned at line 9",
+		},
+		Kind:     kindZero,
+		Function: "early",
+		Results:  []string{"n", "err"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
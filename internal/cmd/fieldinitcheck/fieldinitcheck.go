@@ -0,0 +1,370 @@
+// Package fieldinitcheck implements gofieldinit-check, which reports
+// constructions of structs leaving fields marked as required unset.
+package fieldinitcheck
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gofieldinit-check [flags] <list of packages>
+
+gofieldinit-check reports constructions of structs which leave required
+fields unset: composite literals omitting the fields, and zero values created
+by new(T) or var declarations.
+
+Fields are marked as required by a directive in their comment.
+
+	type Client struct {
+		Timeout time.Duration //gofieldinit:required
+	}
+
+Or by listing them in a file passed to -config, one per line.
+
+	github.com/org/repo/api.Client.Timeout
+
+Flags:
+
+	-config file
+		Read additional required fields from a file.
+
+	-json	Print each problem as a JSON object.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gofieldinit-check exits with status 1 if any problems are found.
+`
+
+// directive marks a struct field as required.
+const directive = "//gofieldinit:required"
+
+// Command runs gofieldinit-check as the fieldinit-check subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "fieldinit-check",
+	Tool:    "gofieldinit-check",
+	Summary: "report structs constructed without their required fields",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gofieldinit-check with the provided arguments.
+func Run(args []string) {
+	configFile := ""
+	jsonOutput := false
+	conf := load.Config{Comments: true}
+	fs := flag.NewFlagSet("gofieldinit-check", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.StringVar(&configFile, "config", "", "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	var configured []*resolve.Target
+	if configFile != "" {
+		var err error
+		if configured, err = readConfig(configFile); err != nil {
+			fatal(err)
+		}
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	toLoad := pkgs
+	for _, t := range configured {
+		toLoad = append(toLoad, t.Pkg)
+	}
+	prog, err := conf.Load(toLoad...)
+	if err != nil {
+		fatal(err)
+	}
+
+	required := make(map[*types.Var]bool)
+	for _, info := range prog.AllPackages {
+		markDirectives(info, required)
+	}
+	for _, t := range configured {
+		v, err := lookupField(prog, t)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %v", configFile, err))
+		}
+		required[v] = true
+	}
+
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		// Only the external test packages of the provided packages, not
+		// those of the packages loaded for the config file.
+		listed := make(map[string]bool, len(pkgs))
+		for _, pkg := range pkgs {
+			listed[pkg] = true
+		}
+		for _, info := range prog.Created {
+			if listed[strings.TrimSuffix(info.Pkg.Path(), "_test")] {
+				searched = append(searched, info)
+			}
+		}
+	}
+	var problems []problem
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		problems = append(problems, check(prog.Fset, info, required)...)
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, p := range problems {
+		if jsonOutput {
+			if err := enc.Encode(p); err != nil {
+				fatal(err)
+			}
+			continue
+		}
+		fmt.Printf("%s:%d:%d: %s\n", p.Filename, p.Line, p.Column, p.Message)
+	}
+	if len(problems) != 0 {
+		os.Exit(1)
+	}
+}
+
+// readConfig reads qualified field names, one per line, as targets. Blank
+// lines and lines starting with '#' are ignored.
+func readConfig(filename string) ([]*resolve.Target, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var fields []*resolve.Target
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Package paths may contain periods without being quoted, so
+		// the type and field are found from the end of the line.
+		i := strings.LastIndex(line, ".")
+		j := strings.LastIndex(line[:max(i, 0)], ".")
+		if j <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected package.Type.Field, got %q", filename, n, line)
+		}
+		fields = append(fields, &resolve.Target{
+			Pkg:    strings.Trim(line[:j], `"`),
+			Name:   line[j+1 : i],
+			Fields: []string{line[i+1:]},
+		})
+	}
+	return fields, s.Err()
+}
+
+// lookupField returns the struct field named by a target from the config file.
+func lookupField(prog *loader.Program, t *resolve.Target) (*types.Var, error) {
+	info := prog.Package(t.Pkg)
+	if info == nil {
+		return nil, fmt.Errorf("package %s not loaded", t.Pkg)
+	}
+	obj, err := t.Lookup(info)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := obj.(*types.Var)
+	if !ok || !v.IsField() {
+		return nil, fmt.Errorf("%s.%s.%s is not a struct field", t.Pkg, t.Name, t.Fields[0])
+	}
+	return v, nil
+}
+
+// markDirectives records fields whose comments contain the directive.
+func markDirectives(info *loader.PackageInfo, required map[*types.Var]bool) {
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields.List {
+				if !hasDirective(field.Doc) && !hasDirective(field.Comment) {
+					continue
+				}
+				for _, name := range field.Names {
+					if v, ok := info.Defs[name].(*types.Var); ok {
+						required[v] = true
+					}
+				}
+				if len(field.Names) == 0 {
+					// Embedded fields are defined by their type name.
+					if v, ok := info.Defs[embeddedIdent(field.Type)].(*types.Var); ok {
+						required[v] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// embeddedIdent returns the type name of an embedded field.
+func embeddedIdent(e ast.Expr) *ast.Ident {
+	for {
+		switch t := e.(type) {
+		case *ast.Ident:
+			return t
+		case *ast.StarExpr:
+			e = t.X
+		case *ast.SelectorExpr:
+			e = t.Sel
+		case *ast.IndexExpr:
+			e = t.X
+		case *ast.IndexListExpr:
+			e = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+func hasDirective(cg *ast.CommentGroup) bool {
+	if cg == nil {
+		return false
+	}
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// problem is a construction which leaves required fields unset.
+type problem struct {
+	Filename string
+	Line     int
+	Column   int
+	Type     string
+	Missing  []string
+	Message  string
+}
+
+// check finds constructions in the package which leave required fields
+// unset.
+func check(fset *token.FileSet, info *loader.PackageInfo, required map[*types.Var]bool) []problem {
+	var problems []problem
+	report := func(pos token.Pos, t types.Type, how string, missing []string) {
+		p := fset.Position(pos)
+		typ := types.TypeString(t, types.RelativeTo(info.Pkg))
+		problems = append(problems, problem{
+			Filename: p.Filename,
+			Line:     p.Line,
+			Column:   p.Column,
+			Type:     types.TypeString(t, nil),
+			Missing:  missing,
+			Message:  fmt.Sprintf("%s %s leaves required fields unset: %s", typ, how, strings.Join(missing, ", ")),
+		})
+	}
+
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				t := info.TypeOf(n)
+				if t == nil {
+					return true
+				}
+				if missing := missingFields(t, n, info, required); len(missing) != 0 {
+					report(n.Pos(), t, "literal", missing)
+				}
+			case *ast.CallExpr:
+				ident, ok := ast.Unparen(n.Fun).(*ast.Ident)
+				if !ok || len(n.Args) != 1 {
+					return true
+				}
+				if b, ok := info.Uses[ident].(*types.Builtin); !ok || b.Name() != "new" {
+					return true
+				}
+				t := info.TypeOf(n.Args[0])
+				if missing := missingFields(t, nil, info, required); len(missing) != 0 {
+					report(n.Pos(), t, "created by new", missing)
+				}
+			case *ast.ValueSpec:
+				if len(n.Values) != 0 || n.Type == nil {
+					return true
+				}
+				t := info.TypeOf(n.Type)
+				if missing := missingFields(t, nil, info, required); len(missing) != 0 {
+					report(n.Pos(), t, "zero value", missing)
+				}
+			}
+			return true
+		})
+	}
+	return problems
+}
+
+// missingFields returns the names of required fields of the struct type t
+// which aren't set by the literal. A nil literal sets no fields.
+func missingFields(t types.Type, lit *ast.CompositeLit, info *loader.PackageInfo, required map[*types.Var]bool) []string {
+	s, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	set := make(map[string]bool)
+	if lit != nil {
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				// Unkeyed literals must set every field.
+				return nil
+			}
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				set[key.Name] = true
+			}
+		}
+	}
+	var missing []string
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if required[f] && !set[f.Name()] {
+			missing = append(missing, f.Name())
+		}
+	}
+	return missing
+}
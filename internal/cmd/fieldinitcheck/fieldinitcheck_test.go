@@ -0,0 +1,67 @@
+package fieldinitcheck
+
+import (
+	"fmt"
+	"go/parser"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestCheck(t *testing.T) {
+	src := `package p
+
+import "time"
+
+type Base struct{}
+
+type Client struct {
+	Base    //gofieldinit:required
+	Timeout time.Duration //gofieldinit:required
+
+	// retries must be set by constructors.
+	//gofieldinit:required
+	retries int
+	Name    string
+}
+
+func f() {
+	_ = Client{Timeout: time.Second}
+	_ = &Client{Base: Base{}, Timeout: time.Second, retries: 1}
+	_ = Client{Base{}, time.Second, 1, ""}
+	_ = new(Client)
+	var c Client
+	var d = Client{}
+	_, _ = c, d
+}
+`
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := prog.Created[0]
+	required := make(map[*types.Var]bool)
+	markDirectives(info, required)
+
+	var got []string
+	for _, p := range check(prog.Fset, info, required) {
+		got = append(got, fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message))
+	}
+	want := []string{
+		"18:6: Client literal leaves required fields unset: Base, retries",
+		"21:6: Client created by new leaves required fields unset: Base, Timeout, retries",
+		"22:6: Client zero value leaves required fields unset: Base, Timeout, retries",
+		"23:10: Client literal leaves required fields unset: Base, Timeout, retries",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected problems:\n%q\ngot:\n%q", want, got)
+	}
+}
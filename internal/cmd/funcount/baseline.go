@@ -0,0 +1,78 @@
+package funcount
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// baseline is the set of unused functions grandfathered by -baseline, stored
+// as JSON such as:
+//
+//	{
+//	  "Unused": [
+//	    "github.com/org/repo/api Client.Close",
+//	    "github.com/org/repo/legacy Parse"
+//	  ]
+//	}
+type baseline struct {
+	// Unused are the IDs of unused functions, as printed by -json.
+	Unused []string
+}
+
+// checkBaseline compares the IDs of unused functions against a baseline file
+// and returns those which aren't in it, sorted. If the file doesn't exist,
+// it's created from the unused functions. If ratchet is true, functions in
+// the baseline which are no longer unused are removed from the file, so they
+// can't become unused again without failing the check.
+func checkBaseline(filename string, unused map[string]bool, ratchet bool) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "%s: recorded %d unused functions\n", filename, len(unused))
+		return nil, writeBaseline(filename, unused)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	known := make(map[string]bool)
+	kept := make(map[string]bool)
+	for _, id := range b.Unused {
+		known[id] = true
+		if unused[id] {
+			kept[id] = true
+		}
+	}
+	var added []string
+	for id := range unused {
+		if !known[id] {
+			added = append(added, id)
+		}
+	}
+	sort.Strings(added)
+	if ratchet && len(kept) < len(known) {
+		fmt.Fprintf(os.Stderr, "%s: removed %d functions which are no longer unused\n", filename, len(known)-len(kept))
+		if err := writeBaseline(filename, kept); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+func writeBaseline(filename string, unused map[string]bool) error {
+	b := baseline{Unused: make([]string, 0, len(unused))}
+	for id := range unused {
+		b.Unused = append(b.Unused, id)
+	}
+	sort.Strings(b.Unused)
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,45 @@
+package funcount
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "giveupthefunc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "baseline.json")
+
+	// The first run records the unused functions.
+	if _, err := checkBaseline(filename, map[string]bool{"p A": true, "p B": true}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// B is now used, and C is newly unused.
+	unused := map[string]bool{"p A": true, "p C": true}
+	added, err := checkBaseline(filename, unused, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"p C"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("expected %v to be added, got %v", want, added)
+	}
+
+	// Ratcheting drops B, so it can't become unused again.
+	if _, err := checkBaseline(filename, unused, true); err != nil {
+		t.Fatal(err)
+	}
+	added, err = checkBaseline(filename, map[string]bool{"p A": true, "p B": true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"p B"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("after ratcheting, expected %v to be added, got %v", want, added)
+	}
+}
@@ -0,0 +1,139 @@
+package funcount
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// dotNode is a function in a call graph, with the number of times it's used.
+// Functions which only appear as callers have no count.
+type dotNode struct {
+	name   string
+	uses   int
+	caller bool
+}
+
+// dotEdge is a function using another, and the number of times it does.
+type dotEdge struct {
+	caller, callee string
+	uses           int
+}
+
+// findCallers returns the functions using each of the functions, by name,
+// and the number of times they use them. Uses outside of functions, such as
+// in the initializers of package variables, are attributed to the package.
+func (c *counter) findCallers(infos []*loader.PackageInfo, funcs map[types.Object]bool) map[types.Object]map[string]int {
+	callers := make(map[types.Object]map[string]int)
+	for _, info := range infos {
+		if c.allowErrors && len(info.Errors) != 0 {
+			continue
+		}
+		for _, file := range info.Files {
+			walkUses(info, file, func(ident *ast.Ident, obj types.Object, kind useKind) {
+				if !funcs[obj] || (c.discountGenerated && c.generated(ident)) {
+					return
+				}
+				caller := info.Pkg.Path() + " (package scope)"
+				for _, decl := range file.Decls {
+					fd, ok := decl.(*ast.FuncDecl)
+					if ok && fd.Pos() <= ident.Pos() && ident.Pos() < fd.End() {
+						caller = objString(info.Defs[fd.Name])
+						break
+					}
+				}
+				if callers[obj] == nil {
+					callers[obj] = make(map[string]int)
+				}
+				callers[obj][caller]++
+			})
+		}
+	}
+	return callers
+}
+
+// callGraph returns the counted functions and their callers as the nodes and
+// edges of a graph.
+func callGraph(counts []defCount, callers map[types.Object]map[string]int) ([]dotNode, []dotEdge) {
+	var nodes []dotNode
+	var edges []dotEdge
+	counted := make(map[string]bool)
+	for _, count := range counts {
+		name := objString(count.obj)
+		counted[name] = true
+		nodes = append(nodes, dotNode{name: name, uses: count.count})
+		for caller, n := range callers[count.obj] {
+			edges = append(edges, dotEdge{caller, name, n})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, e := range edges {
+		if !counted[e.caller] && !seen[e.caller] {
+			seen[e.caller] = true
+			nodes = append(nodes, dotNode{name: e.caller, caller: true})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].name < nodes[j].name })
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].caller != edges[j].caller {
+			return edges[i].caller < edges[j].caller
+		}
+		return edges[i].callee < edges[j].callee
+	})
+	return nodes, edges
+}
+
+// writeDOT writes a call graph in the Graphviz DOT language. Counted
+// functions are filled, labeled with their number of uses, and functions
+// which only appear as callers are dashed.
+func writeDOT(w io.Writer, nodes []dotNode, edges []dotEdge) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph giveupthefunc {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for _, n := range nodes {
+		if n.caller {
+			fmt.Fprintf(bw, "\t%s [style=dashed];\n", strconv.Quote(n.name))
+			continue
+		}
+		label := fmt.Sprintf("%s\n%d uses", n.name, n.uses)
+		if n.uses == 1 {
+			label = n.name + "\n1 use"
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s, style=filled, fillcolor=lightgray];\n", strconv.Quote(n.name), strconv.Quote(label))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(bw, "\t%s -> %s", strconv.Quote(e.caller), strconv.Quote(e.callee))
+		if e.uses > 1 {
+			fmt.Fprintf(bw, " [label=\"%d\"]", e.uses)
+		}
+		fmt.Fprintln(bw, ";")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// writeDOTFile writes the call graph of the counted functions to a file.
+func (c *counter) writeDOTFile(filename string, infos []*loader.PackageInfo, counts []defCount) error {
+	funcs := make(map[types.Object]bool, len(counts))
+	for _, count := range counts {
+		funcs[count.obj] = true
+	}
+	nodes, edges := callGraph(counts, c.findCallers(infos, funcs))
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeDOT(f, nodes, edges); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
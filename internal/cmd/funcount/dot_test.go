@@ -0,0 +1,35 @@
+package funcount
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	nodes := []dotNode{
+		{name: "a.F", uses: 1},
+		{name: "a.G", uses: 2},
+		{name: "a.main", caller: true},
+	}
+	edges := []dotEdge{
+		{"a.G", "a.F", 1},
+		{"a.main", "a.G", 2},
+	}
+	want := `digraph giveupthefunc {
+	rankdir=LR;
+	node [shape=box];
+	"a.F" [label="a.F\n1 use", style=filled, fillcolor=lightgray];
+	"a.G" [label="a.G\n2 uses", style=filled, fillcolor=lightgray];
+	"a.main" [style=dashed];
+	"a.G" -> "a.F";
+	"a.main" -> "a.G" [label="2"];
+}
+`
+	var buf bytes.Buffer
+	if err := writeDOT(&buf, nodes, edges); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
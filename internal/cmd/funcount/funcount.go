@@ -0,0 +1,943 @@
+package funcount
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: giveupthefunc [flags] <list of packages>
+
+giveupthefunc counts the number of times function calls are used.
+
+Each line lists the total number of uses of a function, followed by the
+number of calls, other references (method values, functions stored in
+variables or passed as arguments), and conversions such as T(f).
+
+Flags:
+
+	-i	Don't count function calls of functions that are used to satisfy interfaces.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results. 
+
+	-discount-generated
+		Don't count uses which appear in generated files. By default functions
+		with at least half of their uses in generated files are annotated.
+
+	-min-count N, -max-count N
+		Only print functions used at least or at most N times.
+
+	-top N, -bottom N
+		Only print the N most or least used functions. Both may be provided.
+
+	-json	Print counts as JSON objects. Each object's ID field is a stable
+		identifier of the function, its package path and objectpath
+		separated by a space, such as "net/http Server.M8" for
+		(*net/http.Server).Close, which matches the IDs printed by the
+		repo's other tools. The First, Last and Packages fields are set
+		as by -span.
+
+	-span	After each function, print the positions of its first and last
+		use, ordered by filename and line, or "never" if it's unused,
+		and the number of distinct packages using it, counting a
+		package's external tests as the package. A function used by a
+		single other package may belong next to its consumer.
+
+	-policy file
+		Instead of printing counts, check unused functions against a policy
+		file of per-package budgets and allowed functions. Violations are
+		printed and the command exits with status 1. See deadcode.policy
+		in the README for the format.
+
+	-baseline file
+		Instead of printing counts, fail if any unused function isn't
+		listed in a JSON baseline file, grandfathering the functions which
+		were already unused. Functions are listed by the IDs printed by
+		-json. If the file doesn't exist, it's created from the functions
+		which are currently unused.
+
+	-ratchet
+		With -baseline, remove functions which are no longer unused from
+		the baseline file, so the number of unused functions can only
+		decrease. Commit the updated file along with the change.
+
+	-dot file
+		Instead of printing counts, write a Graphviz DOT graph of the
+		functions to the file, with an edge from each function using them.
+		Combined with -max-count, clusters of rarely used functions which
+		only use each other, and could be deleted together, stand out.
+		Uses outside of functions are attributed to the package.
+
+	-write-only
+		Load *_test.go files, and before the counts, list the functions
+		which are certainly dead code, and the number of lines deleting
+		them would remove: functions which are exported, including their
+		receiver type, and documented, yet unused even by tests, which
+		don't satisfy any interface, and whose name doesn't appear in any
+		string literal or //go:linkname directive, which may refer to it
+		dynamically, such as through reflection or templates.
+
+	-wrappers
+		Instead of printing counts, print functions which only forward
+		their parameters to another function. Each line lists the uses
+		of a wrapper and the chain of calls it forwards through, where
+		every function but the first and last is a wrapper with exactly
+		one use, which could be inlined.
+
+	-owners file
+		Attribute each function to the owners of its file, as listed by
+		a GitHub CODEOWNERS file, and print the counts grouped by owner.
+		Functions with several owners are listed under each of them, and
+		functions without any under "(unowned)". With -json, each
+		object's Owners field lists the owners instead.
+
+	-pprof file
+		Join the counts with a pprof profile, such as a CPU profile,
+		adding columns for the flat and cumulative value of samples in
+		which each function was running or on the stack. The last
+		sample type of the profile is used. Functions with at least %d
+		uses which are never hot, and hot functions with a single use,
+		which may be worth inlining, are annotated. Functions are hot if
+		their cumulative value is at least %d%% of the profile's total.
+
+	Functions whose doc comment has a //funcount:ignore directive, followed by
+	the reason, such as "//funcount:ignore called by the plugin loader", are
+	known false positives: they're left out of the counts, -policy, -baseline
+	and the other reports of unused functions, and before the counts they're
+	listed as ignored with their uses and reason. With -json, they follow the
+	counts with their reason as the Ignored field.
+
+	References to functions from the assembly files of the packages and from
+	//go:linkname directives are counted as references, and before the counts
+	such functions are listed as assembly-linked with the position of each
+	reference. Functions declared without a body are implemented by assembly.
+
+	-watch	After printing counts, keep running and print how counts change as
+		files are edited. Only packages whose files change, and the provided
+		packages which import them, are type checked again.
+`
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Command runs giveupthefunc as the funcount subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "funcount",
+	Tool:    "giveupthefunc",
+	Summary: "count the uses of every function to find dead code",
+	Help:    help,
+	Run:     Run,
+}
+
+// Run runs giveupthefunc with the provided arguments.
+func Run(args []string) {
+	interfaceAnalysis := false
+	allowErrors := false
+	discountGenerated := false
+	minCount, maxCount := 0, -1
+	top, bottom := 0, 0
+	policyFile := ""
+	watch := false
+	showWrappers := false
+	jsonOutput := false
+	ownersFile := ""
+	pprofFile := ""
+	baselineFile := ""
+	ratchet := false
+	dotFile := ""
+	showWriteOnly := false
+	showSpan := false
+	fs := flag.NewFlagSet("giveupthefunc", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(fmt.Sprintf(help, widelyUsed, hotPercent))
+	}
+	fs.BoolVar(&interfaceAnalysis, "i", false, "")
+	fs.BoolVar(&allowErrors, "a", false, "")
+	fs.BoolVar(&discountGenerated, "discount-generated", false, "")
+	fs.IntVar(&minCount, "min-count", 0, "")
+	fs.IntVar(&maxCount, "max-count", -1, "")
+	fs.IntVar(&top, "top", 0, "")
+	fs.IntVar(&bottom, "bottom", 0, "")
+	fs.StringVar(&policyFile, "policy", "", "")
+	fs.BoolVar(&watch, "watch", false, "")
+	fs.BoolVar(&showWrappers, "wrappers", false, "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.StringVar(&ownersFile, "owners", "", "")
+	fs.StringVar(&pprofFile, "pprof", "", "")
+	fs.StringVar(&baselineFile, "baseline", "", "")
+	fs.BoolVar(&ratchet, "ratchet", false, "")
+	fs.StringVar(&dotFile, "dot", "", "")
+	fs.BoolVar(&showWriteOnly, "write-only", false, "")
+	fs.BoolVar(&showSpan, "span", false, "")
+	fs.Parse(args)
+
+	if watch && (policyFile != "" || showWrappers || jsonOutput || ownersFile != "" || pprofFile != "") {
+		fatal("-watch can't be used with -policy, -wrappers, -json, -owners or -pprof")
+	}
+	if (ownersFile != "" || pprofFile != "") && (policyFile != "" || showWrappers) {
+		fatal("-owners and -pprof can't be used with -policy or -wrappers")
+	}
+	if baselineFile != "" && (policyFile != "" || showWrappers || watch || jsonOutput || ownersFile != "" || pprofFile != "") {
+		fatal("-baseline can't be used with -policy, -wrappers, -watch, -json, -owners or -pprof")
+	}
+	if dotFile != "" && (policyFile != "" || baselineFile != "" || showWrappers || watch || jsonOutput || ownersFile != "") {
+		fatal("-dot can't be used with -policy, -baseline, -wrappers, -watch, -json or -owners")
+	}
+	if showWriteOnly && (policyFile != "" || baselineFile != "" || showWrappers || watch || jsonOutput || ownersFile != "" || dotFile != "") {
+		fatal("-write-only can't be used with -policy, -baseline, -wrappers, -watch, -json, -owners or -dot")
+	}
+	if top < 0 || bottom < 0 {
+		fatal("-top and -bottom can't be negative")
+	}
+	if ratchet && baselineFile == "" {
+		fatal("-ratchet requires -baseline")
+	}
+
+	var prof *profile
+	if pprofFile != "" {
+		var err error
+		if prof, err = readProfile(pprofFile); err != nil {
+			fatal(err)
+		}
+	}
+
+	var own *owners
+	if ownersFile != "" {
+		var err error
+		if own, err = readOwners(ownersFile); err != nil {
+			fatal(err)
+		}
+	}
+
+	var pol *policy
+	if policyFile != "" {
+		var err error
+		if pol, err = readPolicy(policyFile); err != nil {
+			fatal(err)
+		}
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	conf := &load.Config{AllowErrors: allowErrors, Tests: showWriteOnly, Comments: true}
+	program, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+
+	infos := make([]*loader.PackageInfo, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		infos = append(infos, program.Imported[pkg])
+	}
+	// External test packages only use functions.
+	infos = append(infos, program.Created...)
+	c := &counter{
+		fset:              program.Fset,
+		interfaceAnalysis: interfaceAnalysis,
+		allowErrors:       allowErrors,
+		discountGenerated: discountGenerated,
+		generatedFiles:    make(map[string]bool),
+	}
+	if interfaceAnalysis {
+		c.scan = make(interfaceScan)
+		c.interfaces = c.scan.index(program.AllPackages)
+	}
+	defs, generated := c.count(infos)
+
+	if pol != nil {
+		unused := make(map[string][]string)
+		for obj, u := range defs {
+			if u.total() == 0 {
+				pkg := obj.Pkg().Path()
+				unused[pkg] = append(unused[pkg], objString(obj))
+			}
+		}
+		violations := pol.check(unused)
+		for _, v := range violations {
+			fmt.Println(v)
+		}
+		if len(violations) != 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if baselineFile != "" {
+		unused := make(map[string]bool)
+		byID := make(map[string]types.Object)
+		for obj, u := range defs {
+			if u.total() == 0 {
+				id := resolve.ID(obj)
+				unused[id] = true
+				byID[id] = obj
+			}
+		}
+		added, err := checkBaseline(baselineFile, unused, ratchet)
+		if err != nil {
+			fatal(err)
+		}
+		for _, id := range added {
+			p := program.Fset.Position(byID[id].Pos())
+			fmt.Printf("%s:%d: %s is unused and not in the baseline\n", p.Filename, p.Line, objString(byID[id]))
+		}
+		if len(added) != 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if showWrappers {
+		printChains(wrapperChains(findWrappers(infos), defs), defs)
+		return
+	}
+
+	if showWriteOnly {
+		interfaces := c.interfaces
+		if interfaces == nil {
+			interfaces = make(interfaceScan).index(program.AllPackages)
+		}
+		printWriteOnly(os.Stdout, findWriteOnly(program.Fset, infos, defs, interfaces))
+	}
+
+	counts := make([]defCount, 0, len(defs))
+	for obj, u := range defs {
+		count := u.total()
+		if count < minCount || (maxCount >= 0 && count > maxCount) {
+			continue
+		}
+		counts = append(counts, defCount{obj, count, u})
+	}
+	sort.Sort(byCount(counts))
+	counts = truncate(counts, top, bottom)
+	if dotFile != "" {
+		if err := c.writeDOTFile(dotFile, infos, counts); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(c.links) != 0 && !jsonOutput {
+		printLinks(os.Stdout, c.links)
+	}
+	if len(c.ignored) != 0 && !jsonOutput {
+		printIgnored(os.Stdout, c.ignored)
+	}
+	if own != nil && !jsonOutput {
+		byOwner := make(map[string][]defCount)
+		for _, count := range counts {
+			for _, o := range own.lookup(program.Fset.Position(count.obj.Pos()).Filename) {
+				byOwner[o] = append(byOwner[o], count)
+			}
+		}
+		names := make([]string, 0, len(byOwner))
+		for o := range byOwner {
+			names = append(names, o)
+		}
+		sort.Strings(names)
+		for _, o := range names {
+			fmt.Println(o)
+			for _, count := range byOwner[o] {
+				printCount(count, generated[count.obj], discountGenerated, prof, showSpan)
+			}
+		}
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, count := range counts {
+		u := count.uses
+		if jsonOutput {
+			jc := jsonCount{
+				ID:        resolve.ID(count.obj),
+				Name:      objString(count.obj),
+				Total:     count.count,
+				Calls:     u.calls,
+				Refs:      u.refs,
+				Convs:     u.convs,
+				Generated: generated[count.obj],
+				Linked:    c.links[count.obj],
+				First:     spanPos(u.first),
+				Last:      spanPos(u.last),
+				Packages:  len(u.pkgs),
+			}
+			if own != nil {
+				jc.Owners = own.lookup(program.Fset.Position(count.obj.Pos()).Filename)
+			}
+			if f, ok := count.obj.(*types.Func); ok && prof != nil {
+				name := pprofName(f)
+				jc.Flat, jc.Cum, jc.Note = prof.flat[name], prof.cum[name], prof.note(name, count.count)
+			}
+			if err := enc.Encode(jc); err != nil {
+				fatal(err)
+			}
+			continue
+		}
+		printCount(count, generated[count.obj], discountGenerated, prof, showSpan)
+	}
+	if jsonOutput {
+		ignored := make([]types.Object, 0, len(c.ignored))
+		for obj := range c.ignored {
+			ignored = append(ignored, obj)
+		}
+		sort.Slice(ignored, func(i, j int) bool { return objString(ignored[i]) < objString(ignored[j]) })
+		for _, obj := range ignored {
+			f := c.ignored[obj]
+			jc := jsonCount{
+				ID:       resolve.ID(obj),
+				Name:     objString(obj),
+				Total:    f.uses.total(),
+				Calls:    f.uses.calls,
+				Refs:     f.uses.refs,
+				Convs:    f.uses.convs,
+				First:    spanPos(f.uses.first),
+				Last:     spanPos(f.uses.last),
+				Packages: len(f.uses.pkgs),
+				Ignored:  f.reason,
+			}
+			if err := enc.Encode(jc); err != nil {
+				fatal(err)
+			}
+		}
+	}
+
+	if watch {
+		w := newWatcher(c, program, pkgs, defs)
+		w.run(watchInterval)
+	}
+}
+
+// printCount prints the uses of a function, annotating it if at least half of
+// them are in generated files. If a profile is provided, the function's
+// sampled values are printed after its uses. If span is true, the positions
+// of its first and last use and the number of packages using it are printed
+// last.
+func printCount(count defCount, generated int, discountGenerated bool, prof *profile, span bool) {
+	u := count.uses
+	fmt.Printf("\t%d\t%d\t%d\t%d", count.count, u.calls, u.refs, u.convs)
+	note := ""
+	if f, ok := count.obj.(*types.Func); ok && prof != nil {
+		name := pprofName(f)
+		fmt.Printf("\t%d\t%d", prof.flat[name], prof.cum[name])
+		note = prof.note(name, count.count)
+	}
+	fmt.Printf("\t%s", objString(count.obj))
+	if !discountGenerated && generated > 0 && generated*2 >= count.count {
+		fmt.Printf("\t(%d from generated files)", generated)
+	}
+	if note != "" {
+		fmt.Printf("\t(%s)", note)
+	}
+	if span {
+		if u.first.IsValid() {
+			fmt.Printf("\tfirst %s, last %s, %d packages", u.first, u.last, len(u.pkgs))
+		} else {
+			fmt.Printf("\tnever")
+		}
+	}
+	fmt.Println()
+}
+
+// counter counts the uses of functions declared in a set of packages.
+type counter struct {
+	fset              *token.FileSet
+	interfaceAnalysis bool
+	allowErrors       bool
+	discountGenerated bool
+
+	scan           interfaceScan
+	interfaces     interfaceIndex
+	generatedFiles map[string]bool
+	// links is set by count to the references to each function from
+	// assembly and //go:linkname directives.
+	links map[types.Object][]string
+	// ignored is set by count to the functions with a //funcount:ignore
+	// directive, which count leaves out of its results.
+	ignored map[types.Object]*ignoredFunc
+}
+
+// ignoredFunc is a function with a //funcount:ignore directive.
+type ignoredFunc struct {
+	reason string
+	uses   *uses
+}
+
+// count returns the uses of each function declared in the packages, and the
+// number of those uses which appear in generated files. References from
+// assembly and //go:linkname directives are counted as references. Functions
+// with a //funcount:ignore directive are counted, but set aside in c.ignored
+// rather than returned.
+func (c *counter) count(infos []*loader.PackageInfo) (map[types.Object]*uses, map[types.Object]int) {
+	defs := make(map[types.Object]*uses)
+	reasons := make(map[types.Object]string)
+	for _, info := range infos {
+		if c.allowErrors && len(info.Errors) != 0 {
+			continue
+		}
+		for _, obj := range info.Defs {
+			if obj == nil {
+				continue
+			}
+			if f, ok := obj.(*types.Func); ok {
+				switch obj.Name() {
+				case "main", "init":
+					continue
+				}
+				if strings.HasSuffix(c.fset.Position(obj.Pos()).Filename, "_test.go") {
+					// Tests are only loaded to count their uses.
+					continue
+				}
+				if c.interfaceAnalysis && c.interfaces.satisfies(f) {
+					continue
+				}
+				defs[obj] = &uses{}
+			}
+		}
+		for _, file := range info.Files {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok {
+					if reason, ok := ignoreDirective(fd.Doc); ok {
+						reasons[info.Defs[fd.Name]] = reason
+					}
+				}
+			}
+		}
+	}
+
+	// Count number of times each definition is used.
+	generated := make(map[types.Object]int)
+	for _, info := range infos {
+		if c.allowErrors && len(info.Errors) != 0 {
+			continue
+		}
+		for _, file := range info.Files {
+			walkUses(info, file, func(ident *ast.Ident, obj types.Object, kind useKind) {
+				u, ok := defs[obj]
+				if !ok {
+					return
+				}
+				if c.generated(ident) {
+					generated[obj]++
+					if c.discountGenerated {
+						return
+					}
+				}
+				u.add(kind)
+				u.addSpan(c.fset.Position(ident.Pos()), strings.TrimSuffix(info.Pkg.Path(), "_test"))
+			})
+		}
+	}
+	var linked []*loader.PackageInfo
+	for _, info := range infos {
+		if !c.allowErrors || len(info.Errors) == 0 {
+			linked = append(linked, info)
+		}
+	}
+	c.links = findLinks(c.fset, linked, defs)
+	for obj, positions := range c.links {
+		defs[obj].refs += len(positions)
+	}
+	c.ignored = make(map[types.Object]*ignoredFunc)
+	for obj, reason := range reasons {
+		if u, ok := defs[obj]; ok {
+			if reason == "" {
+				reason = "no reason given"
+			}
+			c.ignored[obj] = &ignoredFunc{reason, u}
+			delete(defs, obj)
+		}
+	}
+	return defs, generated
+}
+
+// ignoreDirective returns the reason of a //funcount:ignore directive in a
+// doc comment, and whether there is one.
+func ignoreDirective(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		if c.Text == "//funcount:ignore" {
+			return "", true
+		}
+		if reason := strings.TrimPrefix(c.Text, "//funcount:ignore "); reason != c.Text {
+			return strings.TrimSpace(reason), true
+		}
+	}
+	return "", false
+}
+
+// printIgnored prints the functions with a //funcount:ignore directive, their
+// uses and the reason for ignoring them.
+func printIgnored(w io.Writer, ignored map[types.Object]*ignoredFunc) {
+	names := make([]string, 0, len(ignored))
+	byName := make(map[string]*ignoredFunc)
+	for obj, f := range ignored {
+		name := objString(obj)
+		names = append(names, name)
+		byName[name] = f
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Ignored functions: suppressed by //funcount:ignore (%d functions)\n", len(names))
+	for _, name := range names {
+		f := byName[name]
+		fmt.Fprintf(w, "\t%d\t%s\t(%s)\n", f.uses.total(), name, f.reason)
+	}
+	fmt.Fprintln(w)
+}
+
+// generated reports if an identifier is within a generated file.
+func (c *counter) generated(ident *ast.Ident) bool {
+	filename := c.fset.Position(ident.Pos()).Filename
+	isGen, ok := c.generatedFiles[filename]
+	if !ok {
+		isGen = isGenerated(filename)
+		c.generatedFiles[filename] = isGen
+	}
+	return isGen
+}
+
+// truncate limits sorted counts to the bottom and top entries. A limit of
+// zero is ignored, and limits are clamped to the number of counts.
+func truncate(counts []defCount, top, bottom int) []defCount {
+	top, bottom = clamp(top, len(counts)), clamp(bottom, len(counts))
+	if (top == 0 && bottom == 0) || top+bottom >= len(counts) {
+		return counts
+	}
+	var truncated []defCount
+	if bottom > 0 {
+		truncated = append(truncated, counts[:bottom]...)
+	}
+	if top > 0 {
+		truncated = append(truncated, counts[len(counts)-top:]...)
+	}
+	return truncated
+}
+
+// clamp limits n to between zero and limit.
+func clamp(n, limit int) int {
+	switch {
+	case n < 0:
+		return 0
+	case n > limit:
+		return limit
+	}
+	return n
+}
+
+// generatedRegexp matches the comment which marks a file as generated.
+// See https://golang.org/s/generatedcode.
+var generatedRegexp = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports if the file contains a generated code comment before
+// its package clause.
+func isGenerated(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if generatedRegexp.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
+
+func objString(obj types.Object) string {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return obj.String()
+	}
+	return f.FullName()
+}
+
+// jsonCount is the JSON form of a function's counts.
+type jsonCount struct {
+	ID        string
+	Name      string
+	Total     int
+	Calls     int
+	Refs      int
+	Convs     int
+	Generated int      `json:",omitempty"`
+	Owners    []string `json:",omitempty"`
+	// Linked lists the references from assembly and //go:linkname.
+	Linked []string `json:",omitempty"`
+	// First and Last are the positions of the first and last uses, or
+	// "never" if the function is unused, and Packages is the number of
+	// packages using it.
+	First, Last string
+	Packages    int
+	// Ignored is the reason given by a //funcount:ignore directive.
+	Ignored string `json:",omitempty"`
+	// Flat, Cum and Note are set from -pprof.
+	Flat int64  `json:",omitempty"`
+	Cum  int64  `json:",omitempty"`
+	Note string `json:",omitempty"`
+}
+
+type defCount struct {
+	obj   types.Object
+	count int
+	uses  *uses
+}
+
+type useKind int
+
+const (
+	useCall useKind = iota
+	useRef
+	useConv
+)
+
+// uses counts the ways a function is used.
+type uses struct {
+	calls int
+	refs  int
+	convs int
+	// first and last are the first and last uses by filename and line,
+	// and pkgs the packages using the function.
+	first, last token.Position
+	pkgs        map[string]bool
+}
+
+func (u *uses) add(kind useKind) {
+	switch kind {
+	case useCall:
+		u.calls++
+	case useRef:
+		u.refs++
+	case useConv:
+		u.convs++
+	}
+}
+
+func (u *uses) addSpan(p token.Position, pkg string) {
+	if !u.first.IsValid() || before(p, u.first) {
+		u.first = p
+	}
+	if !u.last.IsValid() || before(u.last, p) {
+		u.last = p
+	}
+	if u.pkgs == nil {
+		u.pkgs = make(map[string]bool)
+	}
+	u.pkgs[pkg] = true
+}
+
+// spanPos returns a position of a use, or "never" if there's none.
+func spanPos(p token.Position) string {
+	if !p.IsValid() {
+		return "never"
+	}
+	return p.String()
+}
+
+// before reports whether a position is ordered before another by filename,
+// line and column.
+func before(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+func (u *uses) total() int { return u.calls + u.refs + u.convs }
+
+// walkUses calls f for every identifier in the file which refers to an
+// object, classifying how the object is used by the enclosing expressions.
+func walkUses(info *loader.PackageInfo, file *ast.File, f func(*ast.Ident, types.Object, useKind)) {
+	var stack []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := info.Uses[ident]
+		if obj == nil {
+			return true
+		}
+		f(ident, obj, classify(info, stack))
+		return true
+	})
+}
+
+// classify determines how the identifier at the top of the stack is used.
+func classify(info *loader.PackageInfo, stack []ast.Node) useKind {
+	var expr ast.Node = stack[len(stack)-1]
+	i := len(stack) - 2
+	for ; i >= 0; i-- {
+		switch p := stack[i].(type) {
+		case *ast.SelectorExpr:
+			if p.Sel == expr {
+				expr = p
+				continue
+			}
+		case *ast.ParenExpr:
+			expr = p
+			continue
+		case *ast.IndexExpr:
+			// Explicit instantiation of a generic function.
+			if p.X == expr {
+				expr = p
+				continue
+			}
+		case *ast.IndexListExpr:
+			if p.X == expr {
+				expr = p
+				continue
+			}
+		}
+		break
+	}
+	if i < 0 {
+		return useRef
+	}
+	call, ok := stack[i].(*ast.CallExpr)
+	if !ok {
+		return useRef
+	}
+	if call.Fun == expr {
+		return useCall
+	}
+	if tv, ok := info.Types[call.Fun]; ok && tv.IsType() {
+		return useConv
+	}
+	return useRef
+}
+
+type byCount []defCount
+
+func (b byCount) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCount) Len() int      { return len(b) }
+
+func (b byCount) Less(i, j int) bool {
+	if b[i].count != b[j].count {
+		return b[i].count < b[j].count
+	}
+	return b[i].obj.String() < b[j].obj.String()
+}
+
+// methodKey is the arity of a method. Methods are matched by signature
+// alone, so it doesn't include the name.
+type methodKey struct {
+	params, results int
+}
+
+func keyOf(sig *types.Signature) methodKey {
+	return methodKey{sig.Params().Len(), sig.Results().Len()}
+}
+
+// interfaceMethod is a method of an interface, and the object which declared
+// the interface.
+type interfaceMethod struct {
+	key methodKey
+	obj types.Object
+	sig *types.Signature
+}
+
+// interfaceIndex holds the methods of every non-empty interface, indexed by
+// arity so a function is only compared against the methods it could satisfy.
+type interfaceIndex map[methodKey][]interfaceMethod
+
+// interfaceScan memoizes the interface methods found in each package, so
+// repeated scans, such as by -watch, only visit new packages.
+type interfaceScan map[*loader.PackageInfo][]interfaceMethod
+
+// index returns the interfaces of the packages, scanning any not seen before
+// and forgetting packages which are no longer present.
+func (s interfaceScan) index(pkgs map[*types.Package]*loader.PackageInfo) interfaceIndex {
+	present := make(map[*loader.PackageInfo]bool, len(pkgs))
+	index := make(interfaceIndex)
+	for _, info := range pkgs {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		present[info] = true
+		methods, ok := s[info]
+		if !ok {
+			methods = packageInterfaces(info)
+			s[info] = methods
+		}
+		for _, m := range methods {
+			index[m.key] = append(index[m.key], m)
+		}
+	}
+	for info := range s {
+		if !present[info] {
+			delete(s, info)
+		}
+	}
+	return index
+}
+
+// packageInterfaces returns the methods of the non-empty interfaces which are
+// the types of objects defined in a package. Each interface is only visited
+// once, no matter how many objects have it as their type.
+func packageInterfaces(info *loader.PackageInfo) []interfaceMethod {
+	seen := make(map[*types.Interface]bool)
+	var methods []interfaceMethod
+	for _, obj := range info.Defs {
+		if obj == nil {
+			continue
+		}
+		inter, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok || seen[inter] {
+			continue
+		}
+		seen[inter] = true
+		for i := 0; i < inter.NumMethods(); i++ {
+			m := inter.Method(i)
+			sig := m.Type().(*types.Signature)
+			methods = append(methods, interfaceMethod{keyOf(sig), obj, sig})
+		}
+	}
+	return methods
+}
+
+// satisfies reports if a method has the signature of a method of any
+// interface it may be used to satisfy, whatever the method's name.
+func (idx interfaceIndex) satisfies(f *types.Func) bool {
+	sig, ok := f.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return false
+	}
+	for _, m := range idx[keyOf(sig)] {
+		samePkg := m.obj.Pkg() != nil && m.obj.Pkg() == f.Pkg()
+		if (!samePkg) && (!m.obj.Exported() || !f.Exported()) {
+			continue
+		}
+		if types.Identical(sig, m.sig) {
+			return true
+		}
+	}
+	return false
+}
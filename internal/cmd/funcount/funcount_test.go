@@ -0,0 +1,103 @@
+package funcount
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestIgnoreDirective(t *testing.T) {
+	tests := []struct {
+		comments []string
+		reason   string
+		ok       bool
+	}{
+		{[]string{"// Hook is called by plugins."}, "", false},
+		{[]string{"// Hook is called by plugins.", "//", "//funcount:ignore called by plugins"}, "called by plugins", true},
+		{[]string{"//funcount:ignore"}, "", true},
+		{[]string{"// funcount:ignore isn't a directive"}, "", false},
+		{[]string{"//funcount:ignored"}, "", false},
+	}
+	for _, tt := range tests {
+		doc := &ast.CommentGroup{}
+		for _, text := range tt.comments {
+			doc.List = append(doc.List, &ast.Comment{Text: text})
+		}
+		reason, ok := ignoreDirective(doc)
+		if reason != tt.reason || ok != tt.ok {
+			t.Errorf("%q: expected (%q, %t), got (%q, %t)", tt.comments, tt.reason, tt.ok, reason, ok)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	counts := make([]defCount, 16)
+	for i := range counts {
+		counts[i].count = i
+	}
+	tests := []struct {
+		top, bottom int
+		want        []int
+	}{
+		{0, 0, nil},
+		{2, 0, []int{14, 15}},
+		{0, 2, []int{0, 1}},
+		{1, 1, []int{0, 15}},
+		{-20, 20, nil},
+		{20, 0, nil},
+	}
+	for _, tt := range tests {
+		got := truncate(counts, tt.top, tt.bottom)
+		if tt.want == nil {
+			if len(got) != len(counts) {
+				t.Errorf("truncate(%d, %d): expected every count, got %d", tt.top, tt.bottom, len(got))
+			}
+			continue
+		}
+		var values []int
+		for _, c := range got {
+			values = append(values, c.count)
+		}
+		if !reflect.DeepEqual(values, tt.want) {
+			t.Errorf("truncate(%d, %d): expected %v, got %v", tt.top, tt.bottom, tt.want, values)
+		}
+	}
+}
+
+func TestInterfaceIndex(t *testing.T) {
+	src := `package p
+
+type Closer interface{ Close() error }
+
+type F struct{}
+
+func (F) Flush() error { return nil }
+
+func (F) Name() string { return "" }
+
+func (F) Wait(n int) error { return nil }
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := make(interfaceScan).index(prog.AllPackages)
+	named := prog.Created[0].Pkg.Scope().Lookup("F").Type().(*types.Named)
+	// Methods are matched by signature, whatever their name.
+	want := map[string]bool{"Flush": true, "Name": false, "Wait": false}
+	for i := 0; i < named.NumMethods(); i++ {
+		m := named.Method(i)
+		if got := idx.satisfies(m); got != want[m.Name()] {
+			t.Errorf("%s: expected satisfies %t, got %t", m.Name(), want[m.Name()], got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package funcount
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+// asmSymbolRegexp matches a reference to a Go symbol in an assembly file,
+// such as ·add(SB) or runtime·memmove<ABIInternal>(SB). Slashes in package
+// paths are written as division slashes.
+var asmSymbolRegexp = regexp.MustCompile(`([\w./∕-]*)·(\w+)(?:<[^>]*>)?\(SB\)`)
+
+// findLinks returns the references to the functions from the assembly files
+// of the packages and from //go:linkname directives, which the type checker
+// doesn't see, as file:line positions. A function declared without a body
+// is implemented by a TEXT symbol in assembly, which is one of its
+// references. Both names of a directive are references, since it either
+// provides the function to another package or uses one from it.
+func findLinks(fset *token.FileSet, infos []*loader.PackageInfo, defs map[types.Object]*uses) map[types.Object][]string {
+	symbols := make(map[string]types.Object)
+	for obj := range defs {
+		if f, ok := obj.(*types.Func); ok {
+			symbols[linkSymbol(f)] = obj
+		}
+	}
+	links := make(map[types.Object][]string)
+	add := func(symbol, pos string) {
+		if obj, ok := symbols[symbol]; ok {
+			links[obj] = append(links[obj], pos)
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for _, info := range infos {
+		path := strings.TrimSuffix(info.Pkg.Path(), "_test")
+		for _, file := range info.Files {
+			for _, group := range file.Comments {
+				for _, c := range group.List {
+					fields := strings.Fields(c.Text)
+					if len(fields) < 2 || fields[0] != "//go:linkname" {
+						continue
+					}
+					p := fset.Position(c.Pos())
+					pos := fmt.Sprintf("%s:%d", p.Filename, p.Line)
+					add(path+"."+fields[1], pos)
+					if len(fields) > 2 {
+						add(fields[2], pos)
+					}
+				}
+			}
+			dir := filepath.Dir(fset.Position(file.Pos()).Filename)
+			if dirs[dir] {
+				continue
+			}
+			dirs[dir] = true
+			pkg, err := load.BuildContext().ImportDir(dir, 0)
+			if err != nil {
+				continue
+			}
+			for _, name := range pkg.SFiles {
+				// Unreadable files are reported by the build, not here.
+				scanAssembly(filepath.Join(dir, name), path, add)
+			}
+		}
+	}
+	return links
+}
+
+// scanAssembly calls add with the symbol and position of every reference to
+// a Go symbol in an assembly file of the package with the import path.
+func scanAssembly(filename, path string, add func(symbol, pos string)) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		for _, m := range asmSymbolRegexp.FindAllStringSubmatch(s.Text(), -1) {
+			pkg := strings.Replace(m[1], "∕", "/", -1)
+			if pkg == "" {
+				pkg = path
+			}
+			add(pkg+"."+m[2], fmt.Sprintf("%s:%d", filename, line))
+		}
+	}
+	return s.Err()
+}
+
+// linkSymbol returns the name the linker knows a function by, such as
+// "net/http.Get" or "net/http.(*Client).Get".
+func linkSymbol(f *types.Func) string {
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return f.Pkg().Path() + "." + f.Name()
+	}
+	t := recv.Type()
+	ptr := false
+	if p, ok := t.(*types.Pointer); ok {
+		t, ptr = p.Elem(), true
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return ""
+	}
+	if ptr {
+		return fmt.Sprintf("%s.(*%s).%s", f.Pkg().Path(), named.Obj().Name(), f.Name())
+	}
+	return fmt.Sprintf("%s.%s.%s", f.Pkg().Path(), named.Obj().Name(), f.Name())
+}
+
+// printLinks prints the functions referenced from assembly or //go:linkname
+// directives, and the positions of the references.
+func printLinks(w io.Writer, links map[types.Object][]string) {
+	names := make([]string, 0, len(links))
+	byName := make(map[string][]string)
+	for obj, positions := range links {
+		name := objString(obj)
+		names = append(names, name)
+		byName[name] = positions
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Assembly-linked functions: referenced from assembly or //go:linkname (%d functions)\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%s\t%s\n", name, strings.Join(byName[name], ", "))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,44 @@
+package funcount
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanAssembly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "giveupthefunc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "add_amd64.s")
+	src := `#include "textflag.h"
+
+TEXT ·add(SB),NOSPLIT,$0-24
+	CALL runtime·memmove<ABIInternal>(SB)
+	JMP example.com∕pkg·helper(SB)
+	MOVQ $0, ret+16(FP)
+	RET
+`
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = scanAssembly(filename, "example.com/add", func(symbol, pos string) {
+		got = append(got, symbol+" "+filepath.Base(pos))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"example.com/add.add add_amd64.s:3",
+		"runtime.memmove add_amd64.s:4",
+		"example.com/pkg.helper add_amd64.s:5",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,134 @@
+package funcount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// owners maps files to their owners using a GitHub CODEOWNERS file. The last
+// rule matching a file applies.
+type owners struct {
+	// root is the directory paths are matched relative to.
+	root  string
+	rules []ownerRule
+}
+
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// unowned is listed as the owner of files no rule assigns owners to.
+const unowned = "(unowned)"
+
+// readOwners parses a CODEOWNERS file. Patterns are relative to the directory
+// containing the file, or its parent if the file is within a .github or docs
+// directory.
+func readOwners(filename string) (*owners, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	o := &owners{root: filepath.Dir(abs)}
+	switch filepath.Base(o.root) {
+	case ".github", "docs":
+		o.root = filepath.Dir(o.root)
+	}
+
+	s := bufio.NewScanner(f)
+	lineNum := 0
+	for s.Scan() {
+		lineNum++
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := ownersPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %v", filename, lineNum, fields[0], err)
+		}
+		o.rules = append(o.rules, ownerRule{re, fields[1:]})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ownersPattern converts a CODEOWNERS pattern, which follows the rules of
+// gitignore, into a regular expression matching slash separated paths.
+func ownersPattern(pattern string) (*regexp.Regexp, error) {
+	// Patterns containing a slash other than a trailing one are relative
+	// to the root, others match at any depth.
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("(^|/)")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	switch {
+	case dir:
+		b.WriteString("/")
+	case strings.HasSuffix(pattern, "/*"):
+		// "docs/*" matches files within docs, but not nested ones.
+		b.WriteString("$")
+	default:
+		// Otherwise the pattern matches a file, or a directory and
+		// everything within it.
+		b.WriteString("(/|$)")
+	}
+	return regexp.Compile(b.String())
+}
+
+// lookup returns the owners of a file, or unowned if no rule lists any.
+func (o *owners) lookup(filename string) []string {
+	rel, err := filepath.Rel(o.root, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return []string{unowned}
+	}
+	rel = filepath.ToSlash(rel)
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if o.rules[i].pattern.MatchString(rel) {
+			if len(o.rules[i].owners) == 0 {
+				break
+			}
+			return o.rules[i].owners
+		}
+	}
+	return []string{unowned}
+}
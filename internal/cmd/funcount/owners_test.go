@@ -0,0 +1,35 @@
+package funcount
+
+import "testing"
+
+func TestOwnersPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*", "a/b/c.go", true},
+		{"*.go", "a/b/c.go", true},
+		{"*.go", "a/b/c.md", false},
+		{"/api/", "api/server.go", true},
+		{"/api/", "x/api/server.go", false},
+		{"api/", "x/api/server.go", true},
+		{"api", "x/api/server.go", true},
+		{"docs/*", "docs/a.md", true},
+		{"docs/*", "docs/build/a.md", false},
+		{"pkg/**/gen.go", "pkg/gen.go", true},
+		{"pkg/**/gen.go", "pkg/a/b/gen.go", true},
+		{"/cmd/tool", "cmd/tool/main.go", true},
+		{"/cmd/tool", "cmd/toolbox/main.go", false},
+	}
+	for _, tt := range tests {
+		re, err := ownersPattern(tt.pattern)
+		if err != nil {
+			t.Errorf("ownersPattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("ownersPattern(%q): expected match of %q to be %t, got %t", tt.pattern, tt.path, tt.match, got)
+		}
+	}
+}
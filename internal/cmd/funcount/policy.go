@@ -0,0 +1,359 @@
+package funcount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// policy limits the number of unused functions in a set of packages. It's
+// read from a YAML file such as:
+//
+//	# Unused functions allowed per package. The most specific pattern
+//	# applies to each package.
+//	budgets:
+//	  "...": 0
+//	  github.com/org/repo/legacy/...: 10
+//
+//	# Unused functions exempt from budgets.
+//	allow:
+//	  - symbol: github.com/org/repo/api.NewClient
+//	    reason: public API
+//	    reviewed: alice
+//
+//	# Require every allow entry to record who reviewed it.
+//	require-review: true
+type policy struct {
+	budgets       map[string]int
+	allow         map[string]allowEntry
+	requireReview bool
+}
+
+type allowEntry struct {
+	symbol   string
+	reason   string
+	reviewed string
+}
+
+func readPolicy(filename string) (*policy, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v, err := parseYAML(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	p, err := newPolicy(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return p, nil
+}
+
+func newPolicy(v interface{}) (*policy, error) {
+	p := &policy{budgets: make(map[string]int), allow: make(map[string]allowEntry)}
+	if v == nil {
+		return p, nil
+	}
+	top, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+	for key, val := range top {
+		switch key {
+		case "budgets":
+			m, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("budgets: expected a mapping of package patterns to counts")
+			}
+			for pattern, n := range m {
+				s, _ := n.(string)
+				budget, err := strconv.Atoi(s)
+				if err != nil || budget < 0 {
+					return nil, fmt.Errorf("budgets: %s: invalid budget %q", pattern, s)
+				}
+				p.budgets[pattern] = budget
+			}
+		case "allow":
+			l, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("allow: expected a list")
+			}
+			for _, item := range l {
+				var e allowEntry
+				switch item := item.(type) {
+				case string:
+					e.symbol = item
+				case map[string]interface{}:
+					e.symbol, _ = item["symbol"].(string)
+					e.reason, _ = item["reason"].(string)
+					e.reviewed, _ = item["reviewed"].(string)
+				}
+				if e.symbol == "" {
+					return nil, fmt.Errorf("allow: entry without a symbol")
+				}
+				p.allow[e.symbol] = e
+			}
+		case "require-review":
+			s, _ := val.(string)
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("require-review: expected true or false")
+			}
+			p.requireReview = b
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return p, nil
+}
+
+// budget returns the budget of the most specific pattern matching the
+// package, and false if no pattern matches.
+func (p *policy) budget(pkg string) (int, bool) {
+	best, budget, ok := -1, 0, false
+	for pattern, n := range p.budgets {
+		if matchPattern(pattern, pkg) && len(pattern) > best {
+			best, budget, ok = len(pattern), n, true
+		}
+	}
+	return budget, ok
+}
+
+// matchPattern reports if a package path matches a pattern, where a trailing
+// "/..." matches the package and any below it, and "..." matches everything.
+func matchPattern(pattern, pkg string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	return pattern == pkg
+}
+
+// check evaluates the policy against the unused functions, grouped by
+// package, and returns a description of each violation.
+func (p *policy) check(unused map[string][]string) []string {
+	var violations []string
+	allowed := make(map[string]bool)
+
+	pkgs := make([]string, 0, len(unused))
+	for pkg := range unused {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		var counted []string
+		for _, name := range unused[pkg] {
+			if _, ok := p.allow[name]; ok {
+				allowed[name] = true
+				continue
+			}
+			counted = append(counted, name)
+		}
+		budget, ok := p.budget(pkg)
+		if !ok || len(counted) <= budget {
+			continue
+		}
+		sort.Strings(counted)
+		violations = append(violations, fmt.Sprintf("%s: %d unused functions exceeds budget of %d:\n\t%s",
+			pkg, len(counted), budget, strings.Join(counted, "\n\t")))
+	}
+
+	var symbols []string
+	for symbol := range p.allow {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		e := p.allow[symbol]
+		if !allowed[symbol] {
+			violations = append(violations, fmt.Sprintf("allow: %s is not an unused function, remove it from the policy", symbol))
+		}
+		if p.requireReview && e.reviewed == "" {
+			violations = append(violations, fmt.Sprintf("allow: %s has no reviewed annotation", symbol))
+		}
+	}
+	return violations
+}
+
+// parseYAML parses the subset of YAML used by policy files: block mappings
+// and sequences nested by indentation, plain or quoted scalars, and comments.
+// Scalars are returned as strings.
+func parseYAML(r io.Reader) (interface{}, error) {
+	var lines []yamlLine
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		text := stripComment(s.Text())
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if strings.Contains(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		lines = append(lines, yamlLine{n, len(text) - len(trimmed), strings.TrimRight(trimmed, " ")})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.parse(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.i].n)
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func (p *yamlParser) parse(indent int) (interface{}, error) {
+	if strings.HasPrefix(p.lines[p.i].text, "-") {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	var l []interface{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		line := p.lines[p.i]
+		if !strings.HasPrefix(line.text, "-") {
+			return nil, fmt.Errorf("line %d: expected a list item", line.n)
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.i++
+			if p.i >= len(p.lines) || p.lines[p.i].indent <= indent {
+				l = append(l, nil)
+				continue
+			}
+			v, err := p.parse(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok {
+			// A mapping starting on the same line as the dash. Treat the
+			// rest of the line as the first entry of an indented block.
+			p.lines[p.i] = yamlLine{line.n, indent + len(line.text) - len(rest), rest}
+			v, err := p.parseMapping(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			continue
+		}
+		v, err := unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.n, err)
+		}
+		l = append(l, v)
+		p.i++
+	}
+	return l, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		line := p.lines[p.i]
+		key, val, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line.n)
+		}
+		k, err := unquote(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.n, err)
+		}
+		if _, dup := m[k]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.n, k)
+		}
+		p.i++
+		if val != "" {
+			if m[k], err = unquote(val); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line.n, err)
+			}
+			continue
+		}
+		if p.i < len(p.lines) && (p.lines[p.i].indent > indent ||
+			(p.lines[p.i].indent == indent && strings.HasPrefix(p.lines[p.i].text, "-"))) {
+			if m[k], err = p.parse(p.lines[p.i].indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m[k] = nil
+	}
+	return m, nil
+}
+
+// splitKey splits a "key: value" line, respecting quoted keys.
+func splitKey(s string) (key, val string, ok bool) {
+	inQuote := byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strconv.Unquote(s)
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
+
+// stripComment removes a trailing comment outside of quotes.
+func stripComment(s string) string {
+	inQuote := byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
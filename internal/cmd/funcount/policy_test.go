@@ -0,0 +1,69 @@
+package funcount
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `# comment
+budgets:
+  "...": 0
+  github.com/org/repo/legacy/...: 10 # trailing comment
+
+allow:
+  - github.com/org/repo.Plain
+  - symbol: github.com/org/repo.Reviewed
+    reason: 'used by #tooling'
+    reviewed: alice
+require-review: true
+`
+	got, err := parseYAML(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"budgets": map[string]interface{}{
+			"...":                            "0",
+			"github.com/org/repo/legacy/...": "10",
+		},
+		"allow": []interface{}{
+			"github.com/org/repo.Plain",
+			map[string]interface{}{
+				"symbol":   "github.com/org/repo.Reviewed",
+				"reason":   "used by #tooling",
+				"reviewed": "alice",
+			},
+		},
+		"require-review": "true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML: expected %#v, got %#v", want, got)
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := &policy{
+		budgets: map[string]int{"...": 0, "a/legacy/...": 1},
+		allow: map[string]allowEntry{
+			"a.Allowed": {symbol: "a.Allowed", reviewed: "bob"},
+			"a.Stale":   {symbol: "a.Stale"},
+		},
+		requireReview: true,
+	}
+	unused := map[string][]string{
+		"a":          {"a.Allowed", "a.Dead"},
+		"a/legacy":   {"a/legacy.Old"},
+		"a/legacy/x": {"a/legacy/x.Old", "a/legacy/x.Older"},
+	}
+	want := []string{
+		"a: 1 unused functions exceeds budget of 0:\n\ta.Dead",
+		"a/legacy/x: 2 unused functions exceeds budget of 1:\n\ta/legacy/x.Old\n\ta/legacy/x.Older",
+		"allow: a.Stale is not an unused function, remove it from the policy",
+		"allow: a.Stale has no reviewed annotation",
+	}
+	if got := p.check(unused); !reflect.DeepEqual(got, want) {
+		t.Errorf("check: expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,292 @@
+package funcount
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"strings"
+)
+
+// profile holds the sampled values of each function in a pprof profile,
+// keyed by their symbol names such as "net/http.(*Server).Serve".
+type profile struct {
+	// flat is the value of samples in which the function was running,
+	// and cum the value of samples in which it was on the stack.
+	flat, cum map[string]int64
+	total     int64
+	// unit is the type and unit of the sampled value, such as
+	// "cpu/nanoseconds".
+	unit string
+}
+
+const (
+	// hotPercent is the share of a profile's total at which a function's
+	// cumulative value is considered hot.
+	hotPercent = 1
+	// widelyUsed is the number of uses at which a function which is never
+	// hot is noted.
+	widelyUsed = 10
+)
+
+func (p *profile) hot(name string) bool {
+	return p.total > 0 && p.cum[name]*100 >= p.total*hotPercent
+}
+
+// note describes functions of interest when joining uses with samples.
+func (p *profile) note(name string, count int) string {
+	switch {
+	case count >= widelyUsed && !p.hot(name):
+		return "widely used, never hot"
+	case count == 1 && p.hot(name):
+		return "hot, single use"
+	}
+	return ""
+}
+
+var errMalformed = errors.New("malformed profile")
+
+// readProfile parses a pprof profile, which may be gzip compressed. The last
+// sample value, such as CPU time rather than the number of samples, is used.
+func readProfile(filename string) (*profile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	p, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return p, nil
+}
+
+// parseProfile decodes the parts of a profile.proto message needed to sum
+// the values of each function. See
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+func parseProfile(data []byte) (*profile, error) {
+	type sample struct {
+		locations []uint64
+		values    []int64
+	}
+	var (
+		strs       []string
+		sampleType [][2]int64
+		samples    []sample
+		funcNames  = make(map[uint64]int64)    // function ID to string index
+		locFuncs   = make(map[uint64][]uint64) // location ID to function IDs, innermost first
+	)
+	err := decodeFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1: // sample_type
+			var t [2]int64
+			err := decodeFields(b, func(field int, v uint64, _ []byte) error {
+				if field == 1 || field == 2 {
+					t[field-1] = int64(v)
+				}
+				return nil
+			})
+			sampleType = append(sampleType, t)
+			return err
+		case 2: // sample
+			var s sample
+			err := decodeFields(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					ids, err := varints(v, b)
+					s.locations = append(s.locations, ids...)
+					return err
+				case 2:
+					vals, err := varints(v, b)
+					for _, val := range vals {
+						s.values = append(s.values, int64(val))
+					}
+					return err
+				}
+				return nil
+			})
+			samples = append(samples, s)
+			return err
+		case 4: // location
+			var id uint64
+			var funcs []uint64
+			err := decodeFields(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4: // line
+					return decodeFields(b, func(field int, v uint64, _ []byte) error {
+						if field == 1 {
+							funcs = append(funcs, v)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			locFuncs[id] = funcs
+			return err
+		case 5: // function
+			var id uint64
+			var name int64
+			err := decodeFields(b, func(field int, v uint64, _ []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 2:
+					name = int64(v)
+				}
+				return nil
+			})
+			funcNames[id] = name
+			return err
+		case 6: // string_table
+			strs = append(strs, string(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	str := func(i int64) string {
+		if i < 0 || i >= int64(len(strs)) {
+			return ""
+		}
+		return strs[i]
+	}
+	p := &profile{flat: make(map[string]int64), cum: make(map[string]int64)}
+	if len(sampleType) > 0 {
+		t := sampleType[len(sampleType)-1]
+		p.unit = str(t[0]) + "/" + str(t[1])
+	}
+	for _, s := range samples {
+		if len(s.values) == 0 {
+			continue
+		}
+		v := s.values[len(s.values)-1]
+		p.total += v
+		seen := make(map[string]bool)
+		for i, loc := range s.locations {
+			for j, fn := range locFuncs[loc] {
+				name := symbolName(str(funcNames[fn]))
+				if i == 0 && j == 0 {
+					p.flat[name] += v
+				}
+				if !seen[name] {
+					seen[name] = true
+					p.cum[name] += v
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// decodeFields calls fn for each field of an encoded protocol buffer
+// message. Varint and fixed width fields are passed as v, and length
+// delimited fields as b.
+func decodeFields(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errMalformed
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errMalformed
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return errMalformed
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return errMalformed
+			}
+			b, data = data[n:n+int(l)], data[n+int(l):]
+		case 5:
+			if len(data) < 4 {
+				return errMalformed
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return errMalformed
+		}
+		if err := fn(int(key>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varints returns the values of a repeated varint field, which is either a
+// single value v, or packed into b.
+func varints(v uint64, b []byte) ([]uint64, error) {
+	if b == nil {
+		return []uint64{v}, nil
+	}
+	var vals []uint64
+	for len(b) > 0 {
+		val, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformed
+		}
+		vals, b = append(vals, val), b[n:]
+	}
+	return vals, nil
+}
+
+// symbolName removes the type arguments the runtime prints for generic
+// functions, such as "pkg.Map[...]".
+func symbolName(name string) string {
+	return strings.Replace(name, "[...]", "", -1)
+}
+
+// pprofName returns the symbol name the runtime uses for a function or
+// method, such as "net/http.(*Server).Serve".
+func pprofName(f *types.Func) string {
+	if f.Pkg() == nil {
+		return ""
+	}
+	pkg := f.Pkg().Path()
+	if f.Pkg().Name() == "main" {
+		pkg = "main"
+	}
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return pkg + "." + f.Name()
+	}
+	t := recv.Type()
+	ptr := false
+	if p, ok := t.(*types.Pointer); ok {
+		t, ptr = p.Elem(), true
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return ""
+	}
+	name := named.Obj().Name()
+	if ptr {
+		name = "(*" + name + ")"
+	}
+	return pkg + "." + name + "." + f.Name()
+}
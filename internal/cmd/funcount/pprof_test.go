@@ -0,0 +1,38 @@
+package funcount
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestParseProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := parseProfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.unit != "goroutine/count" {
+		t.Errorf("parseProfile: expected unit %q, got %q", "goroutine/count", p.unit)
+	}
+	// This test is running, so its goroutine is on the stack.
+	if p.cum["testing.tRunner"] == 0 {
+		t.Errorf("parseProfile: expected samples with testing.tRunner on the stack, got %v", p.cum)
+	}
+	if p.flat["testing.tRunner"] != 0 {
+		t.Errorf("parseProfile: expected no samples with testing.tRunner running, got %d", p.flat["testing.tRunner"])
+	}
+}
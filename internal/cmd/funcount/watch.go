@@ -0,0 +1,289 @@
+package funcount
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// watchInterval is how often files are checked for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watcher keeps type information for a program resident and type checks
+// packages again as their files change.
+type watcher struct {
+	c      *counter
+	pkgs   []string // provided packages, dependencies first
+	infos  map[string]*loader.PackageInfo
+	all    map[*types.Package]*loader.PackageInfo
+	files  map[string]map[string]time.Time // package to file modification times
+	dirs   map[string]time.Time            // package to directory modification time
+	totals map[string]int
+}
+
+func newWatcher(c *counter, prog *loader.Program, pkgs []string, defs map[types.Object]*uses) *watcher {
+	w := &watcher{
+		c:      c,
+		infos:  make(map[string]*loader.PackageInfo),
+		all:    make(map[*types.Package]*loader.PackageInfo),
+		files:  make(map[string]map[string]time.Time),
+		dirs:   make(map[string]time.Time),
+		totals: totals(defs),
+	}
+	for pkg, info := range prog.AllPackages {
+		w.all[pkg] = info
+	}
+	for _, pkg := range pkgs {
+		info := prog.Imported[pkg]
+		w.infos[pkg] = info
+		w.files[pkg], w.dirs[pkg] = w.stat(pkg)
+	}
+	w.pkgs = w.sortByImports(pkgs)
+	return w
+}
+
+// run checks for changes at the provided interval, forever.
+func (w *watcher) run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		var changed []string
+		for _, pkg := range w.pkgs {
+			files, dir := w.stat(pkg)
+			if !dir.Equal(w.dirs[pkg]) || !sameTimes(files, w.files[pkg]) {
+				changed = append(changed, pkg)
+				for filename := range files {
+					delete(w.c.generatedFiles, filename)
+				}
+			}
+			w.files[pkg], w.dirs[pkg] = files, dir
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		if err := w.recheck(changed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// stat returns the modification times of the Go files of a package and of
+// the package's directory, which changes as files are added or removed.
+func (w *watcher) stat(pkg string) (map[string]time.Time, time.Time) {
+	files := make(map[string]time.Time)
+	var dir time.Time
+	for _, f := range w.infos[pkg].Files {
+		filename := w.c.fset.Position(f.Package).Filename
+		if fi, err := os.Stat(filename); err == nil {
+			files[filename] = fi.ModTime()
+		}
+		if dir.IsZero() {
+			if fi, err := os.Stat(filepath.Dir(filename)); err == nil {
+				dir = fi.ModTime()
+			}
+		}
+	}
+	return files, dir
+}
+
+func sameTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, t := range a {
+		if !t.Equal(b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortByImports orders packages so each comes after the provided packages
+// it imports.
+func (w *watcher) sortByImports(pkgs []string) []string {
+	var sorted []string
+	seen := make(map[string]bool)
+	var visit func(pkg string)
+	visit = func(pkg string) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, imp := range w.infos[pkg].Pkg.Imports() {
+			if _, ok := w.infos[imp.Path()]; ok {
+				visit(imp.Path())
+			}
+		}
+		sorted = append(sorted, pkg)
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+	return sorted
+}
+
+// recheck type checks the changed packages and the provided packages which
+// import them, then prints how the counts changed. If any package fails to
+// type check the previous type information is kept.
+func (w *watcher) recheck(changed []string) error {
+	dirty := make(map[string]bool)
+	for _, pkg := range changed {
+		dirty[pkg] = true
+	}
+	var recheck []string
+	for _, pkg := range w.pkgs {
+		if !dirty[pkg] {
+			for _, imp := range w.infos[pkg].Pkg.Imports() {
+				if dirty[imp.Path()] {
+					dirty[pkg] = true
+					break
+				}
+			}
+		}
+		if dirty[pkg] {
+			recheck = append(recheck, pkg)
+		}
+	}
+
+	// Packages are checked in dependency order, so the importer sees the
+	// new version of any package checked earlier in this round.
+	checked := make(map[string]*loader.PackageInfo)
+	byPath := make(map[string]*types.Package)
+	for pkg := range w.all {
+		byPath[pkg.Path()] = pkg
+	}
+	for _, pkg := range recheck {
+		info, err := w.check(pkg, byPath)
+		if err != nil {
+			return err
+		}
+		checked[pkg] = info
+		byPath[pkg] = info.Pkg
+	}
+	for pkg, info := range checked {
+		delete(w.all, w.infos[pkg].Pkg)
+		w.all[info.Pkg] = info
+		w.infos[pkg] = info
+	}
+	if w.c.interfaceAnalysis {
+		w.c.interfaces = w.c.scan.index(w.all)
+	}
+
+	infos := make([]*loader.PackageInfo, 0, len(w.pkgs))
+	for _, pkg := range w.pkgs {
+		infos = append(infos, w.infos[pkg])
+	}
+	defs, _ := w.c.count(infos)
+	totals := totals(defs)
+	fmt.Printf("%s: type checked %s\n", time.Now().Format("15:04:05"), strings.Join(recheck, ", "))
+	printDeltas(w.totals, totals)
+	w.totals = totals
+	return nil
+}
+
+// check parses and type checks a package, resolving imports from the
+// resident packages.
+func (w *watcher) check(pkg string, byPath map[string]*types.Package) (*loader.PackageInfo, error) {
+	old := w.infos[pkg]
+	dir := filepath.Dir(w.c.fset.Position(old.Files[0].Package).Filename)
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(w.c.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			if !w.c.allowErrors {
+				return nil, err
+			}
+			if f == nil {
+				continue
+			}
+		}
+		files = append(files, f)
+	}
+
+	info := &loader.PackageInfo{
+		Files: files,
+		Info: types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if p, ok := byPath[path]; ok {
+				return p, nil
+			}
+			return nil, fmt.Errorf("package %s isn't loaded, restart to pick up new imports", path)
+		}),
+		Error: func(err error) {
+			info.Errors = append(info.Errors, err)
+		},
+	}
+	info.Pkg, _ = conf.Check(pkg, w.c.fset, files, &info.Info)
+	if len(info.Errors) != 0 && !w.c.allowErrors {
+		return nil, info.Errors[0]
+	}
+	return info, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// totals returns the total uses of each function, keyed by name so counts
+// can be compared across type checks.
+func totals(defs map[types.Object]*uses) map[string]int {
+	t := make(map[string]int, len(defs))
+	for obj, u := range defs {
+		t[objString(obj)] = u.total()
+	}
+	return t
+}
+
+// printDeltas prints functions whose counts changed, were added or were
+// removed. Functions which are no longer used are marked as dead.
+func printDeltas(before, after map[string]int) {
+	var names []string
+	for name, n := range after {
+		if m, ok := before[name]; !ok || m != n {
+			names = append(names, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m, wasDefined := before[name]
+		n, isDefined := after[name]
+		switch {
+		case !isDefined:
+			fmt.Printf("\tremoved\t%s\n", name)
+		case !wasDefined && n == 0:
+			fmt.Printf("\tdead\t%d\t%s\n", n, name)
+		case !wasDefined:
+			fmt.Printf("\tadded\t%d\t%s\n", n, name)
+		case n == 0:
+			fmt.Printf("\tdead\t%d -> %d\t%s\n", m, n, name)
+		default:
+			fmt.Printf("\t\t%d -> %d\t%s\n", m, n, name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package funcount
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// findWrappers returns the functions declared in the packages whose body is
+// a single call forwarding their parameters, in order, to another function,
+// mapped to the function they call.
+func findWrappers(infos []*loader.PackageInfo) map[types.Object]*types.Func {
+	wrappers := make(map[types.Object]*types.Func)
+	for _, info := range infos {
+		for _, file := range info.Files {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Body == nil {
+					continue
+				}
+				obj := info.Defs[fd.Name]
+				if target := forwardedCall(info, fd); target != nil && target != obj {
+					wrappers[obj] = target
+				}
+			}
+		}
+	}
+	return wrappers
+}
+
+// forwardedCall returns the function called by a trivial wrapper, or nil if
+// the declaration isn't one.
+func forwardedCall(info *loader.PackageInfo, fd *ast.FuncDecl) *types.Func {
+	if len(fd.Body.List) != 1 {
+		return nil
+	}
+	var call *ast.CallExpr
+	switch stmt := fd.Body.List[0].(type) {
+	case *ast.ExprStmt:
+		call, _ = stmt.X.(*ast.CallExpr)
+	case *ast.ReturnStmt:
+		if len(stmt.Results) == 1 {
+			call, _ = stmt.Results[0].(*ast.CallExpr)
+		}
+	}
+	if call == nil {
+		return nil
+	}
+
+	var params []*ast.Ident
+	for _, field := range fd.Type.Params.List {
+		if len(field.Names) == 0 {
+			// Unnamed parameters can't be forwarded.
+			return nil
+		}
+		params = append(params, field.Names...)
+	}
+	if len(call.Args) != len(params) {
+		return nil
+	}
+	for i, arg := range call.Args {
+		id, ok := arg.(*ast.Ident)
+		if !ok || info.Uses[id] == nil || info.Uses[id] != info.Defs[params[i]] {
+			return nil
+		}
+	}
+	sig, ok := info.Defs[fd.Name].Type().(*types.Signature)
+	if !ok || sig.Variadic() != call.Ellipsis.IsValid() {
+		return nil
+	}
+
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	f, _ := info.Uses[id].(*types.Func)
+	return f
+}
+
+// wrapperChains follows wrappers through any wrappers they call which have
+// exactly one use, and so could be inlined into their caller. Each chain
+// starts with a wrapper and ends with the first function which isn't an
+// inlinable wrapper. Wrappers which aren't counted, such as main, init and
+// ignored functions, don't start a chain.
+func wrapperChains(wrappers map[types.Object]*types.Func, defs map[types.Object]*uses) [][]types.Object {
+	inlinable := func(obj types.Object) bool {
+		_, ok := wrappers[obj]
+		u, counted := defs[obj]
+		return ok && counted && u.total() == 1
+	}
+	// Wrappers called only by another wrapper are reported within the
+	// chain of that wrapper.
+	intermediate := make(map[types.Object]bool)
+	for _, target := range wrappers {
+		if inlinable(target) {
+			intermediate[target] = true
+		}
+	}
+
+	var chains [][]types.Object
+	for obj, target := range wrappers {
+		if _, counted := defs[obj]; !counted || intermediate[obj] {
+			continue
+		}
+		chain := []types.Object{obj}
+		seen := map[types.Object]bool{obj: true}
+		var next types.Object = target
+		for inlinable(next) && !seen[next] {
+			chain = append(chain, next)
+			seen[next] = true
+			next = wrappers[next]
+		}
+		chains = append(chains, append(chain, next))
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return objString(chains[i][0]) < objString(chains[j][0])
+	})
+	return chains
+}
+
+func printChains(chains [][]types.Object, defs map[types.Object]*uses) {
+	for _, chain := range chains {
+		names := make([]string, len(chain))
+		for i, obj := range chain {
+			names[i] = objString(obj)
+		}
+		fmt.Printf("\t%d\t%s\n", defs[chain[0]].total(), strings.Join(names, " -> "))
+	}
+}
@@ -0,0 +1,45 @@
+package funcount
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestWrapperChainsUncounted(t *testing.T) {
+	src := `package main
+
+func main() { run() }
+
+func run() { serve() }
+
+func serve() {}
+`
+	conf := loader.Config{Fset: token.NewFileSet()}
+	f, err := parser.ParseFile(conf.Fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := prog.Created[0]
+	scope := info.Pkg.Scope()
+	// main isn't counted, as when counting the package, and run is
+	// called twice so it isn't inlined into main.
+	defs := map[types.Object]*uses{
+		scope.Lookup("run"):   {calls: 2},
+		scope.Lookup("serve"): {calls: 1},
+	}
+
+	chains := wrapperChains(findWrappers(prog.Created), defs)
+	if len(chains) != 1 || chains[0][0] != scope.Lookup("run") {
+		t.Fatalf("expected a single chain starting with run, got %v", chains)
+	}
+	printChains(chains, defs)
+}
@@ -0,0 +1,129 @@
+package funcount
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// writeOnly is a function which is never used, the highest confidence dead
+// code: it's exported and documented, so it was meant to be used, yet nothing
+// refers to it, including tests.
+type writeOnly struct {
+	obj types.Object
+	pos token.Position
+	// lines is the number of lines deleting the function and its doc
+	// comment would remove.
+	lines int
+}
+
+// findWriteOnly returns the unused functions declared in the packages which
+// are exported, including their receiver type, documented, outside of test
+// files, don't satisfy any interface, and aren't referred to dynamically:
+// their name doesn't appear in a string literal or //go:linkname directive in
+// any of the packages. The packages must be loaded with comments and tests.
+func findWriteOnly(fset *token.FileSet, infos []*loader.PackageInfo, defs map[types.Object]*uses, interfaces interfaceIndex) []writeOnly {
+	dynamic := dynamicNames(infos)
+	var found []writeOnly
+	for _, info := range infos {
+		for _, file := range info.Files {
+			if strings.HasSuffix(fset.Position(file.Pos()).Filename, "_test.go") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Doc == nil || !fd.Name.IsExported() || dynamic[fd.Name.Name] {
+					continue
+				}
+				obj := info.Defs[fd.Name]
+				u, ok := defs[obj]
+				if !ok || u.total() != 0 {
+					continue
+				}
+				f := obj.(*types.Func)
+				if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+					if !receiverExported(recv.Type()) || interfaces.satisfies(f) {
+						continue
+					}
+				}
+				start := fset.Position(fd.Doc.Pos())
+				found = append(found, writeOnly{
+					obj:   obj,
+					pos:   fset.Position(fd.Name.Pos()),
+					lines: fset.Position(fd.End()).Line - start.Line + 1,
+				})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].pos.Filename != found[j].pos.Filename {
+			return found[i].pos.Filename < found[j].pos.Filename
+		}
+		return found[i].pos.Line < found[j].pos.Line
+	})
+	return found
+}
+
+func receiverExported(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Exported()
+}
+
+// dynamicNames returns the identifiers appearing within string literals and
+// //go:linkname directives, which may refer to functions without using them,
+// such as through reflect's MethodByName or templates.
+func dynamicNames(infos []*loader.PackageInfo) map[string]bool {
+	names := make(map[string]bool)
+	add := func(s string) {
+		for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		}) {
+			names[word] = true
+		}
+	}
+	for _, info := range infos {
+		for _, file := range info.Files {
+			for _, group := range file.Comments {
+				for _, c := range group.List {
+					if strings.HasPrefix(c.Text, "//go:linkname ") {
+						add(c.Text)
+					}
+				}
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					if s, err := strconv.Unquote(lit.Value); err == nil {
+						add(s)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return names
+}
+
+// printWriteOnly prints the write-only functions and the number of lines
+// deleting them would remove.
+func printWriteOnly(w io.Writer, found []writeOnly) {
+	total := 0
+	for _, f := range found {
+		total += f.lines
+	}
+	fmt.Fprintf(w, "Write-only functions: exported, documented and unused even by tests (%d functions, %d lines)\n", len(found), total)
+	for _, f := range found {
+		fmt.Fprintf(w, "\t%d lines\t%s\t%s:%d\n", f.lines, objString(f.obj), f.pos.Filename, f.pos.Line)
+	}
+	fmt.Fprintln(w)
+}
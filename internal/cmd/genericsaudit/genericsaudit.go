@@ -0,0 +1,383 @@
+// Package genericsaudit implements gogenerics-audit, which reports the
+// generic functions and types of packages and how many times each is
+// instantiated.
+package genericsaudit
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gogenerics-audit [flags] <list of packages>
+
+gogenerics-audit lists the generic functions and types declared in the
+provided packages with their type parameters, and the type arguments each is
+instantiated with within the packages, explicitly or by inference. For each
+generic it prints:
+
+	SITES		The number of places it's instantiated.
+	INSTANTIATIONS	The number of distinct lists of type arguments.
+	SHAPES		The number of distinct shapes of those lists.
+
+The compiler shares the code of instantiations whose type arguments have the
+same shape: the same underlying type, with all pointers, maps, channels and
+functions alike. Each shape is compiled separately, so generics with many
+shapes may bloat binaries, and are flagged as heavily instantiated.
+
+Instantiations by the type parameters of another generic, such as a generic
+function calling itself, aren't counted since they don't produce code by
+themselves.
+
+Flags:
+
+	-threshold n
+		Flag generics instantiated with at least n shapes. Defaults
+		to 8.
+
+	-l	After the table, list the instantiations of each generic and
+		the number of sites of each.
+
+	-json	Print a JSON object for each generic with Generic, Kind,
+		Filename, Line, Column, TypeParams, Sites, Shapes, Heavy and
+		Instantiations fields. Instantiations lists each with TypeArgs,
+		Shape and Sites fields.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gogenerics-audit exits with status 1 if any generics are flagged.
+`
+
+// Command runs gogenerics-audit as the generics-audit subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "generics-audit",
+	Tool:    "gogenerics-audit",
+	Summary: "report generics and how many times each is instantiated",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gogenerics-audit with the provided arguments.
+func Run(args []string) {
+	var (
+		list       bool
+		jsonOutput bool
+		threshold  int
+	)
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gogenerics-audit", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.IntVar(&threshold, "threshold", 8, "")
+	fs.BoolVar(&list, "l", false, "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	if threshold < 1 {
+		fatal("-threshold must be at least 1")
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		if info := prog.Imported[pkg]; len(info.Errors) == 0 {
+			searched = append(searched, info)
+		}
+	}
+	if conf.Tests {
+		for _, info := range prog.Created {
+			if len(info.Errors) == 0 {
+				searched = append(searched, info)
+			}
+		}
+	}
+
+	generics := audit(prog, searched, threshold)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, g := range generics {
+			if err := enc.Encode(g); err != nil {
+				fatal(err)
+			}
+		}
+	} else {
+		if err := printTable(os.Stdout, generics); err != nil {
+			fatal(err)
+		}
+		if list {
+			printInstantiations(os.Stdout, generics)
+		}
+	}
+	for _, g := range generics {
+		if g.Heavy {
+			os.Exit(1)
+		}
+	}
+}
+
+// generic is a generic function or type and its instantiations.
+type generic struct {
+	Generic  string
+	Kind     string
+	Filename string
+	Line     int
+	Column   int
+	// TypeParams is the type parameters and their constraints, such as
+	// "[K comparable, V any]".
+	TypeParams     string
+	Sites          int
+	Shapes         int
+	Heavy          bool
+	Instantiations []*instantiation
+}
+
+// instantiation is a distinct list of type arguments a generic is
+// instantiated with.
+type instantiation struct {
+	TypeArgs string
+	Shape    string
+	Sites    int
+}
+
+// audit returns the generic functions and types declared in the packages,
+// with their instantiations within them, sorted by the number of shapes
+// they're instantiated with. Generics with at least threshold shapes are
+// heavy.
+func audit(prog *loader.Program, infos []*loader.PackageInfo, threshold int) []*generic {
+	objs := make(map[types.Object]bool)
+	byObj := make(map[types.Object]*generic)
+	var generics []*generic
+	for _, info := range infos {
+		for _, obj := range info.Defs {
+			kind, tparams := declaredGeneric(obj)
+			if tparams == nil || byObj[obj] != nil {
+				continue
+			}
+			p := prog.Fset.Position(obj.Pos())
+			g := &generic{
+				Generic:        obj.Pkg().Path() + "." + obj.Name(),
+				Kind:           kind,
+				Filename:       p.Filename,
+				Line:           p.Line,
+				Column:         p.Column,
+				TypeParams:     typeParamsString(tparams),
+				Instantiations: []*instantiation{},
+			}
+			objs[obj] = true
+			byObj[obj] = g
+			generics = append(generics, g)
+		}
+	}
+
+	byArgs := make(map[*generic]map[string]*instantiation)
+	shapes := make(map[*generic]map[string]bool)
+	for _, inst := range match.Instances(infos, objs) {
+		if hasTypeParam(inst.TypeArgs) {
+			continue
+		}
+		g := byObj[inst.Obj]
+		if byArgs[g] == nil {
+			byArgs[g] = make(map[string]*instantiation)
+			shapes[g] = make(map[string]bool)
+		}
+		g.Sites++
+		args := inst.String()
+		in, ok := byArgs[g][args]
+		if !ok {
+			in = &instantiation{TypeArgs: args, Shape: shapeOf(inst.TypeArgs)}
+			byArgs[g][args] = in
+			shapes[g][in.Shape] = true
+			g.Instantiations = append(g.Instantiations, in)
+		}
+		in.Sites++
+	}
+	for _, g := range generics {
+		g.Shapes = len(shapes[g])
+		g.Heavy = g.Shapes >= threshold
+		sort.Slice(g.Instantiations, func(i, j int) bool {
+			a, b := g.Instantiations[i], g.Instantiations[j]
+			if a.Sites != b.Sites {
+				return a.Sites > b.Sites
+			}
+			return a.TypeArgs < b.TypeArgs
+		})
+	}
+	sort.Slice(generics, func(i, j int) bool {
+		a, b := generics[i], generics[j]
+		if a.Shapes != b.Shapes {
+			return a.Shapes > b.Shapes
+		}
+		if len(a.Instantiations) != len(b.Instantiations) {
+			return len(a.Instantiations) > len(b.Instantiations)
+		}
+		return a.Generic < b.Generic
+	})
+	return generics
+}
+
+// declaredGeneric returns the kind and type parameters of a package level
+// generic function or type, or nil if obj isn't one. Methods of generic types
+// aren't instantiated separately from their type, so they aren't included.
+func declaredGeneric(obj types.Object) (string, *types.TypeParamList) {
+	if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return "", nil
+	}
+	switch obj := obj.(type) {
+	case *types.Func:
+		if tparams := obj.Type().(*types.Signature).TypeParams(); tparams.Len() > 0 {
+			return "func", tparams
+		}
+	case *types.TypeName:
+		if obj.IsAlias() {
+			return "", nil
+		}
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return "type", named.TypeParams()
+		}
+	}
+	return "", nil
+}
+
+// typeParamsString returns type parameters and their constraints, such as
+// "[K comparable, V any]".
+func typeParamsString(tparams *types.TypeParamList) string {
+	params := make([]string, tparams.Len())
+	for i := range params {
+		tp := tparams.At(i)
+		params[i] = tp.Obj().Name() + " " + types.TypeString(tp.Constraint(), match.PackageName)
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// hasTypeParam reports whether any of the types is, or is composed of, a
+// type parameter.
+func hasTypeParam(ts []types.Type) bool {
+	for _, t := range ts {
+		if mentionsTypeParam(t, make(map[types.Type]bool)) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentionsTypeParam(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t := types.Unalias(t).(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return mentionsTypeParam(t.Elem(), seen)
+	case *types.Slice:
+		return mentionsTypeParam(t.Elem(), seen)
+	case *types.Array:
+		return mentionsTypeParam(t.Elem(), seen)
+	case *types.Chan:
+		return mentionsTypeParam(t.Elem(), seen)
+	case *types.Map:
+		return mentionsTypeParam(t.Key(), seen) || mentionsTypeParam(t.Elem(), seen)
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if mentionsTypeParam(t.TypeArgs().At(i), seen) {
+				return true
+			}
+		}
+	case *types.Signature:
+		for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+			for i := 0; i < tuple.Len(); i++ {
+				if mentionsTypeParam(tuple.At(i).Type(), seen) {
+					return true
+				}
+			}
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if mentionsTypeParam(t.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shapeOf approximates the shape the compiler gives a list of type arguments:
+// each argument's underlying type, with pointer shaped types alike.
+func shapeOf(ts []types.Type) string {
+	shapes := make([]string, len(ts))
+	for i, t := range ts {
+		switch u := t.Underlying().(type) {
+		case *types.Pointer, *types.Map, *types.Chan, *types.Signature:
+			shapes[i] = "pointer"
+			continue
+		case *types.Basic:
+			if u.Kind() == types.UnsafePointer {
+				shapes[i] = "pointer"
+				continue
+			}
+		}
+		shapes[i] = types.TypeString(t.Underlying(), match.PackageName)
+	}
+	return "[" + strings.Join(shapes, ", ") + "]"
+}
+
+// printTable prints a table of the generics, marking those which are heavily
+// instantiated.
+func printTable(w io.Writer, generics []*generic) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GENERIC\tKIND\tTYPE PARAMETERS\tSITES\tINSTANTIATIONS\tSHAPES\t")
+	for _, g := range generics {
+		note := ""
+		if g.Heavy {
+			note = "heavily instantiated"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", g.Generic, g.Kind, g.TypeParams, g.Sites, len(g.Instantiations), g.Shapes, note)
+	}
+	return tw.Flush()
+}
+
+// printInstantiations lists the instantiations of each instantiated generic.
+func printInstantiations(w io.Writer, generics []*generic) {
+	for _, g := range generics {
+		if len(g.Instantiations) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s%s (%s:%d)\n", g.Generic, g.TypeParams, g.Filename, g.Line)
+		for _, in := range g.Instantiations {
+			sites := "sites"
+			if in.Sites == 1 {
+				sites = "site"
+			}
+			fmt.Fprintf(w, "\t%s\t%d %s\tshape %s\n", in.TypeArgs, in.Sites, sites, in.Shape)
+		}
+	}
+}
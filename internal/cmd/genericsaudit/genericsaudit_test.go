@@ -0,0 +1,69 @@
+package genericsaudit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestAudit(t *testing.T) {
+	src := `package p
+
+import "cmp"
+
+type Set[K comparable] map[K]struct{}
+
+func Max[T cmp.Ordered](a, b T) T {
+	if a < b {
+		return Max(b, a)
+	}
+	return a
+}
+
+func Keys[M ~map[K]V, K comparable, V any](m M) []K { return nil }
+
+type ID int
+
+type node struct{}
+
+var (
+	_ = Max(1, 2)
+	_ = Max[ID](1, 2)
+	_ = Max("a", "b")
+	_ = Max(1.5, 2)
+	_ = Keys(Set[string]{})
+	_ = Set[*node]{}
+	_ = Set[*int]{}
+)
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, g := range audit(prog, prog.Created, 3) {
+		s := fmt.Sprintf("%s %s%s sites=%d shapes=%d heavy=%t", g.Kind, g.Generic, g.TypeParams, g.Sites, g.Shapes, g.Heavy)
+		for _, in := range g.Instantiations {
+			s += fmt.Sprintf("; %s %s %d", in.TypeArgs, in.Shape, in.Sites)
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"func p.Max[T cmp.Ordered] sites=4 shapes=3 heavy=true; [float64] [float64] 1; [int] [int] 1; [p.ID] [int] 1; [string] [string] 1",
+		"type p.Set[K comparable] sites=3 shapes=2 heavy=false; [*int] [pointer] 1; [*p.node] [pointer] 1; [string] [string] 1",
+		"func p.Keys[M ~map[K]V, K comparable, V any] sites=1 shapes=1 heavy=false; [p.Set[string], string, struct{}] [pointer, string, struct{}] 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected generics:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
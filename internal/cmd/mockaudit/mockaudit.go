@@ -0,0 +1,290 @@
+// Package mockaudit implements gomockaudit, which reports mocks generated by
+// gomock or mockery whose methods have drifted from the interface they imitate.
+package mockaudit
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gomockaudit [flags] <list of packages>
+
+gomockaudit finds mocks generated by gomock or mockery in the provided
+packages, matches each to the interface it imitates, and reports methods
+which are missing from the mock, extra to it, or have drifted signatures.
+
+A gomock mock is a struct holding a *gomock.Controller, and a mockery mock is
+a struct embedding testify's mock.Mock. The imitated interface is found by
+name, with any "Mock" prefix removed, among all loaded packages.
+
+Flags:
+
+	-json	Print each problem as a JSON object.
+
+	-t	Load and search *_test.go files for mocks.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gomockaudit exits with status 1 if any mock has drifted from its interface.
+`
+
+// Command runs gomockaudit as the mockaudit subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "mockaudit",
+	Tool:    "gomockaudit",
+	Summary: "find gomock and mockery mocks which drifted from their interface",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gomockaudit with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gomockaudit", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+
+	interfaces := make(map[string][]*types.TypeName)
+	for _, info := range prog.AllPackages {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		scope := info.Pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !types.IsInterface(tn.Type()) {
+				continue
+			}
+			interfaces[name] = append(interfaces[name], tn)
+		}
+	}
+
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	var problems []problem
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		scope := info.Pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			mock, ok := tn.Type().(*types.Named)
+			if !ok || mockKind(mock) == "" {
+				continue
+			}
+			iface, err := findInterface(mock, interfaces)
+			if err != nil {
+				problems = append(problems, newProblem(prog.Fset, mock, nil, "", err.Error()))
+				continue
+			}
+			problems = append(problems, compare(prog.Fset, mock, iface)...)
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Method < b.Method
+	})
+	enc := json.NewEncoder(os.Stdout)
+	for _, p := range problems {
+		if jsonOutput {
+			if err := enc.Encode(p); err != nil {
+				fatal(err)
+			}
+			continue
+		}
+		fmt.Printf("%s:%d: %s\n", p.Filename, p.Line, p.Message)
+	}
+	if len(problems) != 0 {
+		os.Exit(1)
+	}
+}
+
+// mockKind returns the name of the generator of a mock type, or an empty
+// string if the type isn't a mock.
+func mockKind(named *types.Named) string {
+	s, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return ""
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		t := f.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		n, ok := t.(*types.Named)
+		if !ok || n.Obj().Pkg() == nil {
+			continue
+		}
+		path, name := n.Obj().Pkg().Path(), n.Obj().Name()
+		switch {
+		case name == "Controller" && strings.HasSuffix(path, "/mock/gomock"):
+			return "gomock"
+		case name == "Mock" && f.Anonymous() && path == "github.com/stretchr/testify/mock":
+			return "mockery"
+		}
+	}
+	return ""
+}
+
+// findInterface finds the interface a mock imitates. When several interfaces
+// share a name, one declared in a package imported by the mock's package is
+// preferred.
+func findInterface(mock *types.Named, interfaces map[string][]*types.TypeName) (*types.TypeName, error) {
+	name := mock.Obj().Name()
+	candidates := interfaces[strings.TrimPrefix(name, "Mock")]
+	if len(candidates) == 0 && strings.HasPrefix(name, "Mock") {
+		candidates = interfaces[name]
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no interface found for mock %s", name)
+	case 1:
+		return candidates[0], nil
+	}
+	var imported []*types.TypeName
+	for _, c := range candidates {
+		if c.Pkg() == mock.Obj().Pkg() {
+			return c, nil
+		}
+		for _, imp := range mock.Obj().Pkg().Imports() {
+			if imp == c.Pkg() {
+				imported = append(imported, c)
+			}
+		}
+	}
+	if len(imported) == 1 {
+		return imported[0], nil
+	}
+	var paths []string
+	for _, c := range candidates {
+		paths = append(paths, c.Pkg().Path()+"."+c.Name())
+	}
+	sort.Strings(paths)
+	return nil, fmt.Errorf("mock %s matches multiple interfaces: %s", name, strings.Join(paths, ", "))
+}
+
+// helperMethods are generated methods which aren't part of the interface.
+var helperMethods = map[string]bool{"EXPECT": true}
+
+// compare reports the differences between the methods of an interface and
+// the methods declared on its mock.
+func compare(fset *token.FileSet, mock *types.Named, iface *types.TypeName) []problem {
+	it := iface.Type().Underlying().(*types.Interface)
+	if types.Implements(types.NewPointer(mock), it) && len(extraMethods(mock, it)) == 0 {
+		return nil
+	}
+
+	var problems []problem
+	qual := types.RelativeTo(iface.Pkg())
+	mset := types.NewMethodSet(types.NewPointer(mock))
+	for i := 0; i < it.NumMethods(); i++ {
+		m := it.Method(i)
+		sel := mset.Lookup(m.Pkg(), m.Name())
+		if sel == nil {
+			msg := fmt.Sprintf("missing method %s%s", m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qual), "func"))
+			problems = append(problems, newProblem(fset, mock, iface, m.Name(), msg))
+			continue
+		}
+		if !types.Identical(sel.Obj().Type(), m.Type()) {
+			msg := fmt.Sprintf("method %s has signature %s, want %s", m.Name(),
+				types.TypeString(sel.Obj().Type(), qual), types.TypeString(m.Type(), qual))
+			problems = append(problems, newProblem(fset, mock, iface, m.Name(), msg))
+		}
+	}
+	for _, m := range extraMethods(mock, it) {
+		msg := fmt.Sprintf("extra method %s not in interface", m.Name())
+		problems = append(problems, newProblem(fset, mock, iface, m.Name(), msg))
+	}
+	return problems
+}
+
+// extraMethods returns exported methods declared on the mock which aren't
+// part of the interface.
+func extraMethods(mock *types.Named, it *types.Interface) []*types.Func {
+	var extra []*types.Func
+	for i := 0; i < mock.NumMethods(); i++ {
+		m := mock.Method(i)
+		if !m.Exported() || helperMethods[m.Name()] {
+			continue
+		}
+		if obj, _, _ := types.LookupFieldOrMethod(it, false, m.Pkg(), m.Name()); obj == nil {
+			extra = append(extra, m)
+		}
+	}
+	return extra
+}
+
+// problem is a single difference between a mock and its interface.
+type problem struct {
+	Filename  string
+	Line      int
+	Mock      string
+	Generator string
+	Interface string `json:",omitempty"`
+	Method    string `json:",omitempty"`
+	Message   string
+}
+
+func newProblem(fset *token.FileSet, mock *types.Named, iface *types.TypeName, method, msg string) problem {
+	pos := fset.Position(mock.Obj().Pos())
+	p := problem{
+		Filename:  pos.Filename,
+		Line:      pos.Line,
+		Mock:      mock.Obj().Pkg().Path() + "." + mock.Obj().Name(),
+		Generator: mockKind(mock),
+		Method:    method,
+		Message:   mock.Obj().Name() + ": " + msg,
+	}
+	if iface != nil {
+		p.Interface = iface.Pkg().Path() + "." + iface.Name()
+	}
+	return p
+}
@@ -0,0 +1,117 @@
+package mockaudit
+
+import (
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// loadPackage type checks the source as the package with the path, so the
+// fake gomock and testify types the mocks are detected by can be declared
+// alongside them.
+func loadPackage(t *testing.T, path, src string) (*loader.Program, *types.Package) {
+	conf := loader.Config{}
+	f, err := conf.ParseFile("mocks.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles(path, f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog, prog.Created[0].Pkg
+}
+
+func TestCompare(t *testing.T) {
+	src := `package gomock
+
+type Controller struct{}
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+	Delete(key string) error
+}
+
+type MockStore struct{ ctrl *Controller }
+
+func (m *MockStore) EXPECT() {}
+
+func (m *MockStore) Get(key string) (string, error) { return "", nil }
+
+func (m *MockStore) Put(key string, value []byte) error { return nil }
+
+func (m *MockStore) Flush() error { return nil }
+
+type Closer interface{ Close() error }
+
+type MockCloser struct{ ctrl *Controller }
+
+func (m *MockCloser) Close() error { return nil }
+
+type MockCache struct{ ctrl *Controller }
+`
+	prog, pkg := loadPackage(t, "example.com/mock/gomock", src)
+	interfaces := map[string][]*types.TypeName{
+		"Store":  {pkg.Scope().Lookup("Store").(*types.TypeName)},
+		"Closer": {pkg.Scope().Lookup("Closer").(*types.TypeName)},
+	}
+	tests := []struct {
+		mock string
+		want []string
+	}{
+		{"MockStore", []string{
+			"MockStore: missing method Delete(key string) error",
+			"MockStore: method Put has signature func(key string, value []byte) error, want func(key string, value string) error",
+			"MockStore: extra method Flush not in interface",
+		}},
+		{"MockCloser", nil},
+		{"MockCache", []string{"MockCache: no interface found for mock MockCache"}},
+	}
+	for _, tt := range tests {
+		mock := pkg.Scope().Lookup(tt.mock).Type().(*types.Named)
+		if kind := mockKind(mock); kind != "gomock" {
+			t.Errorf("%s: expected a gomock mock, got %q", tt.mock, kind)
+		}
+		var got []string
+		iface, err := findInterface(mock, interfaces)
+		if err != nil {
+			got = append(got, newProblem(prog.Fset, mock, nil, "", err.Error()).Message)
+		} else {
+			for _, p := range compare(prog.Fset, mock, iface) {
+				got = append(got, p.Message)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected problems %q, got %q", tt.mock, tt.want, got)
+		}
+	}
+}
+
+func TestMockKind(t *testing.T) {
+	src := `package mock
+
+type Mock struct{}
+
+type MockStore struct{ Mock }
+
+type Named struct{ m Mock }
+
+type Store struct{}
+`
+	_, pkg := loadPackage(t, "github.com/stretchr/testify/mock", src)
+	want := map[string]string{
+		"MockStore": "mockery",
+		// Mockery mocks embed mock.Mock.
+		"Named": "",
+		"Store": "",
+	}
+	for name, kind := range want {
+		if got := mockKind(pkg.Scope().Lookup(name).Type().(*types.Named)); got != kind {
+			t.Errorf("%s: expected kind %q, got %q", name, kind, got)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package moved implements gomoved, which reports functions duplicated across
+// packages, such as code which was copied or moved without deleting the
+// original.
+package moved
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gomoved [flags] <list of packages>
+
+gomoved reports functions and methods declared in different packages among
+the provided packages which are identical or nearly so, such as functions
+copied into another package, or moved without deleting the original.
+
+Functions are compared by fingerprints of their syntax, normalized so that
+renaming local variables and parameters, reformatting, and comments don't
+matter, and references to the declaring package's own functions and types
+match those of the same name in another package. The similarity of two
+functions is the fraction of short runs of syntax they share, from 0 to 1.
+Functions whose normalized syntax is the same are identical.
+
+Each pair is printed with its similarity, followed by the functions and their
+positions, with the most similar first.
+
+Flags:
+
+	-threshold f
+		Report pairs with at least this similarity. Defaults to 0.9.
+
+	-min-size n
+		Only compare functions with at least n syntax nodes, so short
+		functions such as getters aren't reported. Defaults to 50.
+
+	-json	Print a JSON object for each pair with Similarity, Identical
+		and Functions fields. Functions lists both with ID, Function,
+		Filename, Line and Column fields. ID is the identifier printed
+		by the repo's other tools.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gomoved exits with status 1 if any pairs are reported.
+`
+
+// Command runs gomoved as the moved subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "moved",
+	Tool:    "gomoved",
+	Summary: "report functions duplicated across packages",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gomoved with the provided arguments.
+func Run(args []string) {
+	var (
+		jsonOutput bool
+		threshold  float64
+		minSize    int
+	)
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gomoved", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.Float64Var(&threshold, "threshold", 0.9, "")
+	fs.IntVar(&minSize, "min-size", 50, "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	if threshold <= 0 || threshold > 1 {
+		fatal("-threshold must be greater than 0 and at most 1")
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		if info := prog.Imported[pkg]; len(info.Errors) == 0 {
+			searched = append(searched, info)
+		}
+	}
+	if conf.Tests {
+		for _, info := range prog.Created {
+			if len(info.Errors) == 0 {
+				searched = append(searched, info)
+			}
+		}
+	}
+
+	var funcs []*function
+	for _, info := range searched {
+		for _, f := range fingerprints(prog.Fset, info) {
+			if f.size >= minSize {
+				funcs = append(funcs, f)
+			}
+		}
+	}
+	pairs := compare(funcs, threshold)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, p := range pairs {
+			if err := enc.Encode(p); err != nil {
+				fatal(err)
+			}
+		}
+	} else if err := printTable(os.Stdout, pairs); err != nil {
+		fatal(err)
+	}
+	if len(pairs) != 0 {
+		os.Exit(1)
+	}
+}
+
+// function is the fingerprint of a function.
+type function struct {
+	ID       string
+	Function string
+	Filename string
+	Line     int
+	Column   int
+
+	// pkg is the path of the declaring package, with the external test
+	// package counted as the package.
+	pkg string
+	// size is the number of syntax nodes, hash the hash of the whole
+	// normalized syntax, and shingles the hashes of its runs.
+	size     int
+	hash     uint64
+	shingles map[uint64]bool
+}
+
+// pair is two similar functions of different packages.
+type pair struct {
+	Similarity float64
+	Identical  bool
+	Functions  [2]*function
+}
+
+// shingleSize is the number of consecutive syntax tokens in each run.
+const shingleSize = 4
+
+// fingerprints returns the fingerprints of the functions and methods declared
+// with a body in a package.
+func fingerprints(fset *token.FileSet, info *loader.PackageInfo) []*function {
+	var funcs []*function
+	for _, file := range info.Files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Body == nil {
+				continue
+			}
+			obj, ok := info.Defs[fd.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			tokens := normalize(info, fd)
+			p := fset.Position(fd.Name.Pos())
+			f := &function{
+				ID:       resolve.ID(obj),
+				Function: obj.FullName(),
+				Filename: p.Filename,
+				Line:     p.Line,
+				Column:   p.Column,
+				pkg:      strings.TrimSuffix(info.Pkg.Path(), "_test"),
+				size:     len(tokens),
+				hash:     hash(tokens),
+				shingles: make(map[uint64]bool),
+			}
+			for i := 0; i+shingleSize <= len(tokens); i++ {
+				f.shingles[hash(tokens[i:i+shingleSize])] = true
+			}
+			funcs = append(funcs, f)
+		}
+	}
+	return funcs
+}
+
+// normalize returns the tokens of a function's signature and body: the kind
+// of each syntax node, its operator, and for identifiers, the object named.
+// Local objects are numbered in the order they appear, and package level
+// objects of the function's package are named without their package.
+func normalize(info *loader.PackageInfo, fd *ast.FuncDecl) []string {
+	var tokens []string
+	locals := make(map[types.Object]int)
+	visit := func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		tok := fmt.Sprintf("%T", n)
+		switch n := n.(type) {
+		case *ast.Ident:
+			obj := info.ObjectOf(n)
+			if pkgName, ok := obj.(*types.PkgName); ok {
+				tok = pkgName.Imported().Path()
+				break
+			}
+			switch {
+			case obj == nil || obj.Pkg() == nil:
+				// Blank identifiers and universe objects.
+				tok = n.Name
+			case obj.Parent() == obj.Pkg().Scope():
+				if obj.Pkg() == info.Pkg {
+					tok = "." + obj.Name()
+				} else {
+					tok = obj.Pkg().Path() + "." + obj.Name()
+				}
+			case obj.Parent() == nil:
+				// Fields and methods.
+				tok = "." + obj.Name()
+			default:
+				if _, ok := locals[obj]; !ok {
+					locals[obj] = len(locals)
+				}
+				tok = fmt.Sprintf("$%d", locals[obj])
+			}
+		case *ast.BasicLit:
+			tok = n.Value
+		case *ast.BinaryExpr:
+			tok += n.Op.String()
+		case *ast.UnaryExpr:
+			tok += n.Op.String()
+		case *ast.AssignStmt:
+			tok += n.Tok.String()
+		case *ast.IncDecStmt:
+			tok += n.Tok.String()
+		case *ast.BranchStmt:
+			tok += n.Tok.String()
+		}
+		tokens = append(tokens, tok)
+		return true
+	}
+	if fd.Recv != nil {
+		ast.Inspect(fd.Recv, visit)
+	}
+	ast.Inspect(fd.Type, visit)
+	ast.Inspect(fd.Body, visit)
+	return tokens
+}
+
+func hash(tokens []string) uint64 {
+	h := fnv.New64a()
+	for _, tok := range tokens {
+		io.WriteString(h, tok)
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// compare returns the pairs of functions of different packages with at least
+// the threshold similarity, the Jaccard index of their shingles, with the
+// most similar first.
+func compare(funcs []*function, threshold float64) []*pair {
+	sort.Slice(funcs, func(i, j int) bool { return len(funcs[i].shingles) < len(funcs[j].shingles) })
+	var pairs []*pair
+	for i, a := range funcs {
+		for _, b := range funcs[i+1:] {
+			// The similarity is at most the ratio of the sizes, which
+			// only decreases as b grows.
+			if float64(len(a.shingles)) < threshold*float64(len(b.shingles)) {
+				break
+			}
+			if a.pkg == b.pkg {
+				continue
+			}
+			shared := 0
+			for s := range a.shingles {
+				if b.shingles[s] {
+					shared++
+				}
+			}
+			union := len(a.shingles) + len(b.shingles) - shared
+			similarity := 1.0
+			if union > 0 {
+				similarity = float64(shared) / float64(union)
+			}
+			identical := a.hash == b.hash
+			if similarity < threshold && !identical {
+				continue
+			}
+			fs := [2]*function{a, b}
+			if before(b, a) {
+				fs = [2]*function{b, a}
+			}
+			pairs = append(pairs, &pair{similarity, identical, fs})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		return before(pairs[i].Functions[0], pairs[j].Functions[0])
+	})
+	return pairs
+}
+
+func before(a, b *function) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	return a.Line < b.Line
+}
+
+func printTable(w io.Writer, pairs []*pair) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIMILARITY\tFUNCTION\tPOSITION\tDUPLICATE\tPOSITION")
+	for _, p := range pairs {
+		similarity := fmt.Sprintf("%.2f", p.Similarity)
+		if p.Identical {
+			similarity = "identical"
+		}
+		a, b := p.Functions[0], p.Functions[1]
+		fmt.Fprintf(tw, "%s\t%s\t%s:%d\t%s\t%s:%d\n", similarity, a.Function, a.Filename, a.Line, b.Function, b.Filename, b.Line)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,122 @@
+package moved
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestCompare(t *testing.T) {
+	srcA := `package a
+
+type Point struct{ X, Y int }
+
+func Sum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+// Add copies Sum within the package, so the two aren't reported.
+func Add(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func Clamp(p Point, max int) Point {
+	if p.X > max {
+		p.X = max
+	}
+	if p.Y > max {
+		p.Y = max
+	}
+	return p
+}
+
+func Parse(s string) []string {
+	var fields []string
+	for _, f := range s {
+		fields = append(fields, string(f))
+	}
+	return fields
+}
+`
+	srcB := `package b
+
+type Point struct{ X, Y int }
+
+// Total renames Sum's locals.
+func Total(values []int) int {
+	sum := 0
+	for _, v := range values {
+		// Comments don't matter.
+		sum += v
+	}
+	return sum
+}
+
+// Clamp also clamps to a minimum.
+func Clamp(p Point, max int) Point {
+	if p.X > max {
+		p.X = max
+	}
+	if p.Y > max {
+		p.Y = max
+	}
+	if p.X < 0 {
+		p.X = 0
+	}
+	return p
+}
+
+// Split differs from Parse too much to be reported.
+func Split(s string) []string {
+	var fields []string
+	for i := 0; i < len(s); i += 2 {
+		fields = append(fields, s[i:i+1])
+	}
+	return fields
+}
+`
+	conf := loader.Config{}
+	for _, pkg := range []struct{ path, filename, src string }{
+		{"example.com/a", "a.go", srcA},
+		{"example.com/b", "b.go", srcB},
+	} {
+		f, err := conf.ParseFile(pkg.filename, pkg.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles(pkg.path, f)
+	}
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var funcs []*function
+	for _, info := range prog.Created {
+		funcs = append(funcs, fingerprints(prog.Fset, info)...)
+	}
+	var got []string
+	for _, p := range compare(funcs, 0.6) {
+		a, b := p.Functions[0], p.Functions[1]
+		got = append(got, fmt.Sprintf("%.2f %t %s %s:%d %s %s:%d", p.Similarity, p.Identical, a.Function, a.Filename, a.Line, b.Function, b.Filename, b.Line))
+	}
+	want := []string{
+		"1.00 true example.com/a.Sum a.go:5 example.com/b.Total b.go:6",
+		"1.00 true example.com/a.Add a.go:14 example.com/b.Total b.go:6",
+		"0.68 false example.com/a.Clamp a.go:22 example.com/b.Clamp b.go:16",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected pairs:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
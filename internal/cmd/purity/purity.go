@@ -0,0 +1,546 @@
+// Package purity implements gopurity, which classifies functions by the state
+// they touch: whether they perform I/O, use package level variables, mutate
+// their receiver or arguments, only read them, or are pure.
+package purity
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gopurity [flags] <list of packages>
+
+gopurity classifies each function and method declared in the provided
+packages by the state it touches, from most to least impure:
+
+	io		Calls into packages performing I/O, such as os, net,
+			syscall and log, or fmt's Print, Fprint and Scan functions.
+	global		Reads or writes a package level variable of any package.
+	mutating	Writes through a pointer, slice or map reached from its
+			receiver or parameters, such as assigning to a field of
+			a pointer receiver, or calls delete, clear or copy on one.
+	read-only	Reads through a pointer, slice or map reached from its
+			receiver or parameters, without writing.
+	pure		None of the above: its result only depends on its
+			arguments' values.
+
+Classes are transitive: a function calling one which performs I/O or uses a
+package level variable is classified the same, as is a method calling a
+mutating method on its receiver. Only calls to functions declared in the
+provided packages are followed, besides those to I/O packages. Calls through
+interfaces and function values can't be followed and are assumed to be pure,
+but are counted.
+
+Each function is printed with its class, position and the reason for its
+class, such as the package level variable it uses.
+
+Flags:
+
+	-class list
+		Only print functions of the classes in a comma separated list,
+		such as -class io,global.
+
+	-json	Print a JSON object for each function with ID, Function,
+		Filename, Line, Column, Class, Reason, IO, ReadsGlobals,
+		WritesGlobals, Mutates, Reads and DynamicCalls fields. ID is
+		the identifier printed by the repo's other tools.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+`
+
+// Command runs gopurity as the purity subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "purity",
+	Tool:    "gopurity",
+	Summary: "classify functions as pure, read-only, mutating, global or io",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gopurity with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	classList := ""
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gopurity", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.StringVar(&classList, "class", "", "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	only := make(map[string]bool)
+	if classList != "" {
+		for _, class := range strings.Split(classList, ",") {
+			if _, ok := classRanks[class]; !ok {
+				fatal(fmt.Sprintf("-class: unknown class %q, expected one of %s", class, strings.Join(classes, ", ")))
+			}
+			only[class] = true
+		}
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		if info := prog.Imported[pkg]; len(info.Errors) == 0 {
+			searched = append(searched, info)
+		}
+	}
+	if conf.Tests {
+		for _, info := range prog.Created {
+			if len(info.Errors) == 0 {
+				searched = append(searched, info)
+			}
+		}
+	}
+
+	var results []*function
+	for _, f := range classify(prog.Fset, searched) {
+		if len(only) == 0 || only[f.Class] {
+			results = append(results, f)
+		}
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, f := range results {
+			if err := enc.Encode(f); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+	if err := printTable(os.Stdout, results); err != nil {
+		fatal(err)
+	}
+}
+
+// Classes, from least to most impure.
+const (
+	classPure     = "pure"
+	classReadOnly = "read-only"
+	classMutating = "mutating"
+	classGlobal   = "global"
+	classIO       = "io"
+)
+
+var classes = []string{classPure, classReadOnly, classMutating, classGlobal, classIO}
+
+var classRanks = map[string]int{classPure: 0, classReadOnly: 1, classMutating: 2, classGlobal: 3, classIO: 4}
+
+// ioPackages are packages whose functions perform I/O. Their subpackages,
+// such as net/http, are included.
+var ioPackages = []string{"os", "net", "syscall", "log", "io/ioutil", "golang.org/x/sys"}
+
+// function is the classification of a function.
+type function struct {
+	ID       string
+	Function string
+	Filename string
+	Line     int
+	Column   int
+	Class    string
+	// Reason describes what gives the function its class, such as the
+	// I/O function or package level variable used, and the function
+	// called which uses it, if it's transitive.
+	Reason string `json:",omitempty"`
+	// IO is the I/O function called, directly or transitively.
+	IO string `json:",omitempty"`
+	// ReadsGlobals and WritesGlobals are the package level variables
+	// the function uses directly.
+	ReadsGlobals  []string `json:",omitempty"`
+	WritesGlobals []string `json:",omitempty"`
+	// Mutates and Reads are the receiver and parameters the function
+	// writes or reads through directly.
+	Mutates []string `json:",omitempty"`
+	Reads   []string `json:",omitempty"`
+	// DynamicCalls is the number of calls through interfaces and function
+	// values, which can't be followed.
+	DynamicCalls int
+
+	calls []call
+	// global is the package level variable used, directly or
+	// transitively, and via the function called which uses it.
+	global, globalVia string
+	ioVia             string
+	mutatesVia        string
+}
+
+// call is a static call from a function.
+type call struct {
+	callee *types.Func
+	// onState is true if the call is a method call on the caller's
+	// receiver or a parameter, so a mutating callee mutates the caller's
+	// state.
+	onState bool
+}
+
+// classify returns the classification of every function and method with a
+// body declared in the packages, sorted by position.
+func classify(fset *token.FileSet, infos []*loader.PackageInfo) []*function {
+	var funcs []*function
+	byObj := make(map[*types.Func]*function)
+	for _, info := range infos {
+		for _, file := range info.Files {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Body == nil {
+					continue
+				}
+				obj, ok := info.Defs[fd.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				f := inspect(info, fd, obj)
+				p := fset.Position(fd.Name.Pos())
+				f.Filename, f.Line, f.Column = p.Filename, p.Line, p.Column
+				funcs = append(funcs, f)
+				byObj[obj] = f
+			}
+		}
+	}
+
+	// Propagate I/O, globals and mutation through calls until nothing
+	// changes.
+	for changed := true; changed; {
+		changed = false
+		for _, f := range funcs {
+			for _, c := range f.calls {
+				g, ok := byObj[c.callee]
+				if !ok || g == f {
+					continue
+				}
+				if f.IO == "" && g.IO != "" {
+					f.IO, f.ioVia = g.IO, g.Function
+					changed = true
+				}
+				if f.global == "" && g.global != "" {
+					f.global, f.globalVia = g.global, g.Function
+					changed = true
+				}
+				if c.onState && len(f.Mutates) == 0 && f.mutatesVia == "" && (len(g.Mutates) != 0 || g.mutatesVia != "") {
+					f.mutatesVia = g.Function
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, f := range funcs {
+		via := func(s, via string) string {
+			if via == "" {
+				return s
+			}
+			return s + " via " + via
+		}
+		switch {
+		case f.IO != "":
+			f.Class, f.Reason = classIO, via("calls "+f.IO, f.ioVia)
+		case f.global != "":
+			f.Class, f.Reason = classGlobal, via("uses "+f.global, f.globalVia)
+		case len(f.Mutates) != 0:
+			f.Class, f.Reason = classMutating, "writes through "+strings.Join(f.Mutates, ", ")
+		case f.mutatesVia != "":
+			f.Class, f.Reason = classMutating, "calls "+f.mutatesVia
+		case len(f.Reads) != 0:
+			f.Class, f.Reason = classReadOnly, "reads through "+strings.Join(f.Reads, ", ")
+		default:
+			f.Class = classPure
+		}
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].Filename != funcs[j].Filename {
+			return funcs[i].Filename < funcs[j].Filename
+		}
+		return funcs[i].Line < funcs[j].Line
+	})
+	return funcs
+}
+
+// inspect records the state a function's body touches directly and the
+// functions it calls.
+func inspect(info *loader.PackageInfo, fd *ast.FuncDecl, obj *types.Func) *function {
+	f := &function{
+		ID:       resolve.ID(obj),
+		Function: obj.FullName(),
+	}
+	// state holds the receiver and parameters, whose state is shared with
+	// the caller when reached through a pointer, slice or map.
+	state := make(map[types.Object]bool)
+	sig := obj.Type().(*types.Signature)
+	if recv := sig.Recv(); recv != nil {
+		state[recv] = true
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		state[sig.Params().At(i)] = true
+	}
+
+	seen := make(map[string]bool)
+	addOnce := func(list *[]string, kind, name string) {
+		if !seen[kind+name] {
+			seen[kind+name] = true
+			*list = append(*list, name)
+		}
+	}
+	writes := make(map[*ast.Ident]bool)
+	// write records a write to an expression, or through it if the
+	// write modifies the value it references, as delete does.
+	write := func(e ast.Expr, through bool) {
+		root, deref := rootOf(info, e)
+		deref = deref || through
+		if root == nil {
+			return
+		}
+		obj := info.Uses[root]
+		switch {
+		case isGlobal(obj):
+			writes[root] = true
+			addOnce(&f.WritesGlobals, "w", globalName(obj))
+		case state[obj] && deref:
+			writes[root] = true
+			addOnce(&f.Mutates, "m", obj.Name())
+		}
+	}
+
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					write(lhs, false)
+				}
+			}
+		case *ast.IncDecStmt:
+			write(n.X, false)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					write(n.Key, false)
+				}
+				if n.Value != nil {
+					write(n.Value, false)
+				}
+			}
+		case *ast.CallExpr:
+			f.addCall(info, n, state, write)
+		}
+		return true
+	})
+	// Remaining uses of globals and shared state are reads.
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if obj := info.Uses[n]; isGlobal(obj) && !writes[n] {
+				addOnce(&f.ReadsGlobals, "r", globalName(obj))
+			}
+		case *ast.SelectorExpr:
+			if sel, ok := info.Selections[n]; ok && sel.Kind() != types.FieldVal {
+				// A method, whose receiver is inspected by its own
+				// classification.
+				return true
+			}
+			root, deref := rootOf(info, n)
+			if root != nil && deref && state[info.Uses[root]] && !writes[root] {
+				addOnce(&f.Reads, "r", root.Name)
+			}
+		case *ast.IndexExpr, *ast.StarExpr:
+			root, deref := rootOf(info, n.(ast.Expr))
+			if root != nil && deref && state[info.Uses[root]] && !writes[root] {
+				addOnce(&f.Reads, "r", root.Name)
+			}
+		case *ast.RangeStmt:
+			// Ranging over a pointer to an array, a slice or a map
+			// reads its elements.
+			root, deref := rootOf(info, n.X)
+			deref = deref || isReference(info.TypeOf(n.X))
+			if root != nil && deref && state[info.Uses[root]] && !writes[root] {
+				addOnce(&f.Reads, "r", root.Name)
+			}
+		}
+		return true
+	})
+	switch {
+	case len(f.WritesGlobals) != 0:
+		f.global = f.WritesGlobals[0]
+	case len(f.ReadsGlobals) != 0:
+		f.global = f.ReadsGlobals[0]
+	}
+	return f
+}
+
+// addCall records a call, the I/O it performs if it calls into an I/O
+// package, and the state written by builtins which modify their arguments.
+func (f *function) addCall(info *loader.PackageInfo, ce *ast.CallExpr, state map[types.Object]bool, write func(ast.Expr, bool)) {
+	fun := ast.Unparen(ce.Fun)
+	switch x := fun.(type) {
+	case *ast.IndexExpr:
+		fun = ast.Unparen(x.X)
+	case *ast.IndexListExpr:
+		fun = ast.Unparen(x.X)
+	}
+	if tv, ok := info.Types[fun]; ok && tv.IsType() {
+		// A conversion.
+		return
+	}
+	var ident *ast.Ident
+	var recv ast.Expr
+	switch x := fun.(type) {
+	case *ast.Ident:
+		ident = x
+	case *ast.SelectorExpr:
+		ident = x.Sel
+		if sel, ok := info.Selections[x]; ok {
+			if sel.Kind() != types.MethodVal || types.IsInterface(sel.Recv()) {
+				f.DynamicCalls++
+				return
+			}
+			recv = x.X
+		}
+	default:
+		f.DynamicCalls++
+		return
+	}
+	switch obj := info.Uses[ident].(type) {
+	case *types.Builtin:
+		switch obj.Name() {
+		case "delete", "clear", "copy":
+			if len(ce.Args) > 0 {
+				write(ce.Args[0], true)
+			}
+		}
+	case *types.Func:
+		obj = obj.Origin()
+		if f.IO == "" && isIO(obj) {
+			f.IO = obj.FullName()
+		}
+		onState := false
+		if recv != nil {
+			if root, _ := rootOf(info, recv); root != nil {
+				onState = state[info.Uses[root]]
+			}
+		}
+		f.calls = append(f.calls, call{callee: obj, onState: onState})
+	default:
+		f.DynamicCalls++
+	}
+}
+
+// rootOf returns the variable an expression selects, indexes or
+// dereferences, such as s in s.a.b[i], and whether the expression reaches
+// through a pointer, slice or map to do so, rather than only into the
+// variable's own value.
+func rootOf(info *loader.PackageInfo, e ast.Expr) (*ast.Ident, bool) {
+	deref := false
+	for {
+		switch x := ast.Unparen(e).(type) {
+		case *ast.Ident:
+			return x, deref
+		case *ast.SelectorExpr:
+			if _, ok := info.Selections[x]; !ok {
+				// A qualified identifier.
+				return x.Sel, deref
+			}
+			deref = deref || isReference(info.TypeOf(x.X))
+			e = x.X
+		case *ast.IndexExpr:
+			deref = deref || isReference(info.TypeOf(x.X))
+			e = x.X
+		case *ast.StarExpr:
+			deref = true
+			e = x.X
+		default:
+			return nil, false
+		}
+	}
+}
+
+// isReference reports whether values of a type share state when copied.
+func isReference(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// isGlobal reports whether an object is a package level variable.
+func isGlobal(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+}
+
+func globalName(obj types.Object) string {
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// isIO reports whether a function belongs to an I/O package or is one of
+// fmt's functions which print or scan.
+func isIO(f *types.Func) bool {
+	if f.Pkg() == nil {
+		return false
+	}
+	path := f.Pkg().Path()
+	if path == "fmt" {
+		name := f.Name()
+		return !strings.HasPrefix(name, "Sprint") && !strings.HasPrefix(name, "Sscan") && (strings.Contains(name, "Print") || strings.Contains(name, "Scan"))
+	}
+	for _, p := range ioPackages {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// printTable prints each function with its class, position and reason,
+// followed by the number of functions of each class.
+func printTable(w io.Writer, funcs []*function) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	counts := make(map[string]int)
+	for _, f := range funcs {
+		counts[f.Class]++
+		fmt.Fprintf(tw, "%s\t%s\t%s:%d\t%s\n", f.Class, f.Function, f.Filename, f.Line, f.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	var totals []string
+	for i := len(classes) - 1; i >= 0; i-- {
+		totals = append(totals, fmt.Sprintf("%d %s", counts[classes[i]], classes[i]))
+	}
+	_, err := fmt.Fprintf(w, "\n%d functions: %s\n", len(funcs), strings.Join(totals, ", "))
+	return err
+}
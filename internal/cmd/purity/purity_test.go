@@ -0,0 +1,97 @@
+package purity
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestClassify(t *testing.T) {
+	src := `package p
+
+import (
+	"fmt"
+	"os"
+)
+
+var count int
+
+type T struct {
+	n int
+	m map[string]int
+}
+
+func add(a, b int) int { return a + b }
+
+func label(n int) string { return fmt.Sprintf("%d", n) }
+
+func (t T) get() int { return t.n }
+
+func (t *T) size() int { return t.n }
+
+func (t *T) set(n int) { t.n = n }
+
+func (t *T) reset() { t.set(0) }
+
+func (t *T) drop(k string) { delete(t.m, k) }
+
+func total(xs []int) (n int) {
+	for _, x := range xs {
+		n += x
+	}
+	return n
+}
+
+func incr() { count++ }
+
+func current() int { return count }
+
+func report() int { return current() }
+
+func save(b []byte) error { return os.WriteFile("f", b, 0644) }
+
+func print(s string) { fmt.Println(s) }
+
+func flush() { save(nil) }
+
+func apply(f func(int) int) int { return f(1) }
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range classify(prog.Fset, prog.Created) {
+		got = append(got, fmt.Sprintf("%d: %s %s: %s (%d dynamic)", f.Line, f.Class, f.Function, f.Reason, f.DynamicCalls))
+	}
+	want := []string{
+		"15: pure p.add:  (0 dynamic)",
+		"17: pure p.label:  (0 dynamic)",
+		"19: pure (p.T).get:  (0 dynamic)",
+		"21: read-only (*p.T).size: reads through t (0 dynamic)",
+		"23: mutating (*p.T).set: writes through t (0 dynamic)",
+		"25: mutating (*p.T).reset: calls (*p.T).set (0 dynamic)",
+		"27: mutating (*p.T).drop: writes through t (0 dynamic)",
+		"29: read-only p.total: reads through xs (0 dynamic)",
+		"36: global p.incr: uses p.count (0 dynamic)",
+		"38: global p.current: uses p.count (0 dynamic)",
+		"40: global p.report: uses p.count via p.current (0 dynamic)",
+		"42: io p.save: calls os.WriteFile (0 dynamic)",
+		"44: io p.print: calls fmt.Println (0 dynamic)",
+		"46: io p.flush: calls os.WriteFile via p.save (0 dynamic)",
+		"48: pure p.apply:  (1 dynamic)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected functions:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
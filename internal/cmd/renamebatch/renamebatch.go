@@ -0,0 +1,506 @@
+// Package renamebatch implements gorename-batch, which renames many objects
+// listed in a mapping file in a single pass over a set of packages.
+package renamebatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gorename-batch [flags] <mapping file> [packages]
+
+gorename-batch renames many objects in a single pass over the provided
+packages.
+
+The mapping file holds pairs of qualified objects and their new names. CSV
+files hold one pair per line, separated by the last comma. Blank lines and
+lines beginning with # are ignored.
+
+	net.Dial,Connect
+	"github.com/org/repo/store".DB.Get,Fetch
+
+Files ending in .json hold an object of the same pairs.
+
+	{"net.Dial": "Connect"}
+
+Objects are written in the same form as gosearch expressions. Every rename is
+checked for conflicts before any file is modified: names which are already
+taken or would be shadowed, exported objects which would become unexported,
+and renamed methods which would stop a type implementing an interface it
+implements, unless the interface's method is renamed the same way.
+
+The command accepts the following flags:
+
+	-n	Dry run. Print a diff of the changes instead of writing files.
+
+	-t	Load and rename within *_test.go files.
+
+	-a	Allow build errors. Packages that fail to build will be omitted.
+`
+
+// Command runs gorename-batch as the rename-batch subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "rename-batch",
+	Tool:    "gorename-batch",
+	Summary: "rename many objects in a single pass from a mapping file",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gorename-batch with the provided arguments.
+func Run(args []string) {
+	var dryRun, importTests, allowErrors bool
+	fs := flag.NewFlagSet("gorename-batch", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&dryRun, "n", false, "")
+	fs.BoolVar(&importTests, "t", false, "")
+	fs.BoolVar(&allowErrors, "a", false, "")
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) == 0 {
+		fatal(help)
+	}
+	renames, err := readMapping(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	pkgs, err := load.GoList{}.List(args[1:]...)
+	if err != nil {
+		fatal(err)
+	}
+
+	conf := load.Config{AllowErrors: allowErrors, Tests: importTests}
+	var toLoad []string
+	for _, r := range renames {
+		toLoad = append(toLoad, r.target.Pkg)
+	}
+	prog, err := conf.Load(append(toLoad, pkgs...)...)
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, r := range renames {
+		if err := r.resolve(prog); err != nil {
+			fatal(fmt.Errorf("%s: %v", r.old, err))
+		}
+	}
+
+	// Rename within the provided packages and the packages which define
+	// the renamed objects.
+	var searched []*loader.PackageInfo
+	for _, info := range prog.Imported {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		searched = append(searched, info)
+	}
+	if importTests {
+		searched = append(searched, prog.Created...)
+	}
+
+	edits, conflicts := plan(prog, searched, renames)
+	if len(conflicts) != 0 {
+		for _, c := range conflicts {
+			fmt.Fprintln(os.Stderr, c)
+		}
+		os.Exit(1)
+	}
+	filenames := make([]string, 0, len(edits))
+	for filename := range edits {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if err := rewrite(filename, edits[filename], dryRun); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// rename is a single entry of the mapping file.
+type rename struct {
+	old string
+	new string
+
+	target *resolve.Target
+
+	obj types.Object
+	// owner is the type obj is a field or method of, if any.
+	owner types.Type
+}
+
+// resolve looks up the renamed object and the type it belongs to, if any.
+func (r *rename) resolve(prog *loader.Program) error {
+	info := prog.Imported[r.target.Pkg]
+	var err error
+	if r.obj, err = r.target.Lookup(info); err != nil {
+		return err
+	}
+	if n := len(r.target.Fields); n > 0 {
+		owner := *r.target
+		owner.Fields = owner.Fields[:n-1]
+		obj, err := owner.Lookup(info)
+		if err != nil {
+			return err
+		}
+		r.owner = obj.Type()
+	}
+	return nil
+}
+
+// readMapping parses a CSV or JSON mapping file.
+func readMapping(filename string) ([]*rename, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make(map[string]string)
+	var order []string
+	if strings.HasSuffix(filename, ".json") {
+		if err := json.Unmarshal(data, &pairs); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		for old := range pairs {
+			order = append(order, old)
+		}
+		sort.Strings(order)
+	} else {
+		// The new name is an identifier, so the last comma ends the
+		// object, whose package may be quoted.
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			comma := strings.LastIndex(line, ",")
+			if comma < 0 {
+				return nil, fmt.Errorf("%s:%d: expected object,name", filename, i+1)
+			}
+			old, name := strings.TrimSpace(line[:comma]), strings.TrimSpace(line[comma+1:])
+			if _, ok := pairs[old]; ok {
+				return nil, fmt.Errorf("%s: %s renamed more than once", filename, old)
+			}
+			pairs[old] = name
+			order = append(order, old)
+		}
+	}
+
+	renames := make([]*rename, len(order))
+	for i, old := range order {
+		r := &rename{old: old, new: pairs[old]}
+		if !isIdent(r.new) {
+			return nil, fmt.Errorf("%s: %q is not a valid identifier", old, r.new)
+		}
+		var err error
+		if r.target, err = resolve.Parse(old); err != nil {
+			return nil, fmt.Errorf("%s: %v", old, err)
+		}
+		if r.target.Kind != "" || r.target.Regexp != nil || r.target.Name == "" {
+			return nil, fmt.Errorf("%s: not an object", old)
+		}
+		renames[i] = r
+	}
+	return renames, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" || s == "_" || token.Lookup(s).IsKeyword() {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || r >= 0x80 {
+			continue
+		}
+		if i > 0 && '0' <= r && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// edit replaces the identifier at offset with a new name.
+type edit struct {
+	offset int
+	length int
+	text   string
+}
+
+// plan finds every identifier which refers to a renamed object and reports
+// any renames which would change the meaning of the program.
+func plan(prog *loader.Program, pkgs []*loader.PackageInfo, renames []*rename) (map[string][]edit, []string) {
+	fset := prog.Fset
+	byObj := make(map[types.Object]*rename, len(renames))
+	for _, r := range renames {
+		byObj[r.obj] = r
+	}
+
+	var conflicts []string
+	conflictf := func(pos token.Pos, format string, a ...interface{}) {
+		msg := fmt.Sprintf(format, a...)
+		if pos.IsValid() {
+			msg = fset.Position(pos).String() + ": " + msg
+		}
+		conflicts = append(conflicts, msg)
+	}
+
+	// Names which will exist in each scope or method set once all
+	// renames are applied.
+	taken := make(map[string]types.Object)
+	key := func(r *rename) string {
+		if r.owner != nil {
+			return r.owner.String() + "." + r.new
+		}
+		return r.obj.Pkg().Path() + "." + r.new
+	}
+	for _, r := range renames {
+		k := key(r)
+		if other, ok := taken[k]; ok {
+			conflictf(r.obj.Pos(), "%s and %s are both renamed to %s", byObj[other].old, r.old, r.new)
+			continue
+		}
+		taken[k] = r.obj
+
+		var existing types.Object
+		if r.owner != nil {
+			existing, _, _ = types.LookupFieldOrMethod(r.owner, true, r.obj.Pkg(), r.new)
+		} else {
+			existing = r.obj.Pkg().Scope().Lookup(r.new)
+		}
+		if existing != nil && existing != r.obj {
+			if _, renamed := byObj[existing]; !renamed {
+				conflictf(r.obj.Pos(), "renaming %s to %s conflicts with %s", r.old, r.new, existing)
+			}
+		}
+	}
+
+	for _, b := range brokenImplementations(prog, byObj) {
+		r := byObj[b.method]
+		conflictf(r.obj.Pos(), "renaming %s to %s would stop %s implementing %s", r.old, r.new, b.typ, b.iface)
+	}
+
+	edits := make(map[string][]edit)
+	seen := make(map[token.Pos]bool)
+	for _, info := range pkgs {
+		check := func(ident *ast.Ident, obj types.Object) {
+			r, ok := byObj[obj]
+			if !ok || seen[ident.Pos()] {
+				return
+			}
+			seen[ident.Pos()] = true
+			if obj.Pkg() != info.Pkg && !ast.IsExported(r.new) {
+				conflictf(ident.Pos(), "%s is used outside of package %s and cannot be unexported", r.old, obj.Pkg().Path())
+			}
+			if obj.Parent() == obj.Pkg().Scope() && obj.Pkg() == info.Pkg {
+				// Unqualified reference to a package level object. Ensure
+				// the new name isn't shadowed at this position.
+				if scope := innermost(info, ident.Pos()); scope != nil {
+					if _, shadow := scope.LookupParent(r.new, ident.Pos()); shadow != nil && shadow.Parent() != obj.Parent() {
+						conflictf(ident.Pos(), "renaming %s to %s would be shadowed by %s", r.old, r.new, shadow)
+					}
+				}
+			}
+			pos := fset.Position(ident.Pos())
+			edits[pos.Filename] = append(edits[pos.Filename], edit{pos.Offset, len(ident.Name), r.new})
+		}
+		for ident, obj := range info.Defs {
+			check(ident, obj)
+		}
+		for ident, obj := range info.Uses {
+			check(ident, obj)
+		}
+	}
+	sort.Strings(conflicts)
+	return edits, conflicts
+}
+
+// brokenImplementation is a type which would no longer implement an
+// interface because of a renamed method.
+type brokenImplementation struct {
+	typ, iface string
+	// method is the renamed method of the type or the interface.
+	method types.Object
+}
+
+// brokenImplementations returns the package level types which implement a
+// package level interface, where the interface or the type has a renamed
+// method and the other's method isn't renamed the same way.
+func brokenImplementations(prog *loader.Program, byObj map[types.Object]*rename) []brokenImplementation {
+	newName := func(obj types.Object) string {
+		if r, ok := byObj[obj]; ok {
+			return r.new
+		}
+		return obj.Name()
+	}
+	// Only pairs where the method set of the type or the interface has a
+	// renamed method, including promoted and embedded ones, are checked.
+	methods := false
+	for obj := range byObj {
+		if f, ok := obj.(*types.Func); ok && f.Type().(*types.Signature).Recv() != nil {
+			methods = true
+		}
+	}
+	if !methods {
+		return nil
+	}
+	renamed := func(t types.Type) bool {
+		mset := types.NewMethodSet(t)
+		for i := 0; i < mset.Len(); i++ {
+			if _, ok := byObj[mset.At(i).Obj()]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	var ifaces, concrete []*types.TypeName
+	hasRenamed := make(map[*types.TypeName]bool)
+	for pkg := range prog.AllPackages {
+		for _, name := range pkg.Scope().Names() {
+			tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.IsInterface(tn.Type()) {
+				ifaces = append(ifaces, tn)
+				hasRenamed[tn] = renamed(tn.Type())
+			} else {
+				concrete = append(concrete, tn)
+				hasRenamed[tn] = renamed(types.NewPointer(tn.Type()))
+			}
+		}
+	}
+	qualifier := func(p *types.Package) string { return p.Name() }
+
+	var broken []brokenImplementation
+	for _, iface := range ifaces {
+		it := iface.Type().Underlying().(*types.Interface)
+		if it.NumMethods() == 0 {
+			continue
+		}
+		for _, tn := range concrete {
+			if !hasRenamed[iface] && !hasRenamed[tn] {
+				continue
+			}
+			// A pointer implements the interfaces its type does.
+			for _, t := range []types.Type{tn.Type(), types.NewPointer(tn.Type())} {
+				if !types.Implements(t, it) {
+					continue
+				}
+				for i := 0; i < it.NumMethods(); i++ {
+					m := it.Method(i)
+					impl, _, _ := types.LookupFieldOrMethod(t, false, m.Pkg(), m.Name())
+					if impl == nil || newName(impl) == newName(m) {
+						continue
+					}
+					method := types.Object(m)
+					if _, ok := byObj[impl]; ok {
+						method = impl
+					}
+					broken = append(broken, brokenImplementation{
+						typ:    types.TypeString(t, qualifier),
+						iface:  types.TypeString(iface.Type(), qualifier),
+						method: method,
+					})
+				}
+				break
+			}
+		}
+	}
+	return broken
+}
+
+// innermost returns the innermost scope in the package containing pos.
+func innermost(info *loader.PackageInfo, pos token.Pos) *types.Scope {
+	for _, f := range info.Files {
+		if f.Pos() <= pos && pos <= f.End() {
+			if scope := info.Scopes[f]; scope != nil {
+				return scope.Innermost(pos)
+			}
+		}
+	}
+	return nil
+}
+
+// rewrite applies edits to a file, or prints a diff when dryRun is true.
+func rewrite(filename string, edits []edit, dryRun bool) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].offset < edits[j].offset })
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.offset < last || e.offset+e.length > len(data) {
+			return fmt.Errorf("%s: overlapping edits at offset %d", filename, e.offset)
+		}
+		buf.Write(data[last:e.offset])
+		buf.WriteString(e.text)
+		last = e.offset + e.length
+	}
+	buf.Write(data[last:])
+
+	if !dryRun {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filename, buf.Bytes(), fi.Mode())
+	}
+	return diff(os.Stdout, filename, buf.Bytes())
+}
+
+// diff writes a unified diff between a file and its new contents using the
+// system's diff command.
+func diff(w io.Writer, filename string, data []byte) error {
+	f, err := ioutil.TempFile("", "gorename-batch")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	name := filepath.ToSlash(filename)
+	cmd := exec.Command("diff", "-u", "--label", name, "--label", name, filename, f.Name())
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// diff exits with status 1 when the files differ.
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return err
+		}
+	}
+	return nil
+}
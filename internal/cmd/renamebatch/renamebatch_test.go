@@ -0,0 +1,133 @@
+package renamebatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+func TestReadMapping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorename-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		data string
+		want map[string]*resolve.Target
+		err  string
+	}{
+		{
+			name: "renames.csv",
+			data: "# Renames.\nnet.Dial,Connect\n\n\"github.com/org/repo/store\".DB.Get, Fetch\n",
+			want: map[string]*resolve.Target{
+				"Connect": {Pkg: "net", Name: "Dial"},
+				"Fetch":   {Pkg: "github.com/org/repo/store", Name: "DB", Fields: []string{"Get"}},
+			},
+		},
+		{
+			name: "renames.json",
+			data: `{"net.Dial": "Connect"}`,
+			want: map[string]*resolve.Target{"Connect": {Pkg: "net", Name: "Dial"}},
+		},
+		{name: "twice.csv", data: "net.Dial,Connect\nnet.Dial,Open\n", err: "renamed more than once"},
+		{name: "invalid.csv", data: "net.Dial,func\n", err: "not a valid identifier"},
+		{name: "label.csv", data: "label:retry,again\n", err: "not an object"},
+		{name: "missing.csv", data: "net.Dial\n", err: "expected object,name"},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.name)
+		if err := ioutil.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		renames, err := readMapping(filename)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := make(map[string]*resolve.Target)
+		for _, r := range renames {
+			got[r.new] = r.target
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPlanConflicts(t *testing.T) {
+	src := `package p
+
+type Closer interface{ Close() error }
+
+type File struct{}
+
+func (f *File) Close() error { return nil }
+
+func (f *File) Name() string { return "" }
+
+func (f *File) Path() string { return "" }
+
+var _ Closer = (*File)(nil)
+`
+	tests := []struct {
+		renames map[string]string
+		// conflict is contained by the only conflict, or empty if there
+		// are none.
+		conflict string
+	}{
+		{map[string]string{"p.File.Close": "Shutdown"}, "renaming p.File.Close to Shutdown would stop *p.File implementing p.Closer"},
+		{map[string]string{"p.Closer.Close": "Shutdown"}, "renaming p.Closer.Close to Shutdown would stop *p.File implementing p.Closer"},
+		{map[string]string{"p.File.Close": "Shutdown", "p.Closer.Close": "Shutdown"}, ""},
+		{map[string]string{"p.File.Name": "Path"}, "renaming p.File.Name to Path conflicts with"},
+		{map[string]string{"p.File.Name": "Base"}, ""},
+	}
+	for _, tt := range tests {
+		conf := loader.Config{}
+		f, err := conf.ParseFile("p.go", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles("p", f)
+		prog, err := conf.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := prog.Created[0]
+		prog.Imported["p"] = info
+
+		var renames []*rename
+		for old, name := range tt.renames {
+			target, err := resolve.Parse(old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &rename{old: old, new: name, target: target}
+			if err := r.resolve(prog); err != nil {
+				t.Fatal(err)
+			}
+			renames = append(renames, r)
+		}
+		_, conflicts := plan(prog, prog.Created, renames)
+		switch {
+		case tt.conflict == "" && len(conflicts) != 0:
+			t.Errorf("%v: expected no conflicts, got %q", tt.renames, conflicts)
+		case tt.conflict != "" && (len(conflicts) != 1 || !strings.Contains(conflicts[0], tt.conflict)):
+			t.Errorf("%v: expected a conflict containing %q, got %q", tt.renames, tt.conflict, conflicts)
+		}
+	}
+}
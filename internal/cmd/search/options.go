@@ -0,0 +1,551 @@
+package search
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+	"github.com/ericchiang/gotools/internal/resolve"
+)
+
+// options are the flags and arguments gosearch is run with, validated into
+// the config of a search by validate.
+type options struct {
+	// conf holds the flags which are set directly on the config.
+	conf config
+
+	showConstraints bool
+	jsonOutput      bool
+	quiet           bool
+	showBlame       bool
+	moduleOnly      bool
+	near            string
+	in              string
+	from            string
+	trimPrefix      string
+	htmlFile        string
+	construct       bool
+	assert          bool
+	convert         bool
+	call            bool
+	methodValue     bool
+	methodExpr      bool
+	inGo, inDefer   bool
+	closure         bool
+	exported        bool
+	onlyTests       bool
+	tags            string
+	platformList    string
+	vendor          bool
+	reads, writes   bool
+	anyReceiver     string
+	query           string
+	tag             string
+	pos             string
+	rank            bool
+	rankWeights     string
+	excludes        patternList
+	pluginName      string
+	summarizeDepth  int
+	trend, asCSV    bool
+	since, step     string
+
+	// args are the arguments following the flags, and set holds the names
+	// of the flags which were provided.
+	args []string
+	set  map[string]bool
+	// patterns are the package patterns to search, and platforms the
+	// platforms to search them for, set by validate.
+	patterns  []string
+	platforms []platform
+}
+
+// register defines the flags of the options in fs.
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.conf.importTests, "t", false, "")
+	fs.BoolVar(&o.conf.allowErrors, "a", false, "")
+	fs.StringVar(&o.tags, "tags", "", "")
+	fs.StringVar(&o.conf.goos, "goos", "", "")
+	fs.StringVar(&o.conf.goarch, "goarch", "", "")
+	fs.StringVar(&o.platformList, "platforms", "", "")
+	fs.BoolVar(&o.conf.searchDefs, "d", false, "")
+	fs.BoolVar(&o.conf.withDefs, "u", false, "")
+	fs.BoolVar(&o.conf.defsAndUses, "du", false, "")
+	fs.BoolVar(&o.conf.showDef, "show-def", false, "")
+	fs.BoolVar(&o.showConstraints, "constraints", false, "")
+	fs.BoolVar(&o.jsonOutput, "json", false, "")
+	fs.BoolVar(&o.quiet, "quiet", false, "")
+	fs.BoolVar(&o.showBlame, "blame", false, "")
+	fs.BoolVar(&o.moduleOnly, "module", false, "")
+	fs.BoolVar(&o.vendor, "vendor", false, "")
+	fs.BoolVar(&o.conf.depsReport, "include-deps-report", false, "")
+	fs.BoolVar(&o.conf.mergeVendored, "merge-vendored", false, "")
+	fs.BoolVar(&o.conf.assignedTo, "assigned-to", false, "")
+	fs.BoolVar(&o.conf.typed, "typed", false, "")
+	fs.BoolVar(&o.conf.impl, "impl", false, "")
+	fs.BoolVar(&o.conf.dispatch, "dispatch", false, "")
+	fs.BoolVar(&o.conf.embedded, "embedded", false, "")
+	fs.BoolVar(&o.conf.shadow, "shadow", false, "")
+	fs.BoolVar(&o.conf.asInterface, "as-interface", false, "")
+	fs.BoolVar(&o.conf.chanOps, "chan", false, "")
+	fs.BoolVar(&o.conf.indexOps, "index", false, "")
+	fs.BoolVar(&o.conf.errorChecks, "error-checks", false, "")
+	fs.BoolVar(&o.conf.dotImports, "dot-imports", false, "")
+	fs.BoolVar(&o.conf.deprecated, "deprecated", false, "")
+	fs.BoolVar(&o.conf.reflect, "reflect", false, "")
+	fs.BoolVar(&o.construct, "construct", false, "")
+	fs.BoolVar(&o.assert, "assert", false, "")
+	fs.BoolVar(&o.convert, "convert", false, "")
+	fs.BoolVar(&o.call, "call", false, "")
+	fs.BoolVar(&o.methodValue, "method-value", false, "")
+	fs.BoolVar(&o.methodExpr, "method-expr", false, "")
+	fs.BoolVar(&o.inGo, "go", false, "")
+	fs.BoolVar(&o.inDefer, "defer", false, "")
+	fs.BoolVar(&o.closure, "closure", false, "")
+	fs.BoolVar(&o.exported, "exported", false, "")
+	fs.BoolVar(&o.onlyTests, "only-tests", false, "")
+	fs.StringVar(&o.anyReceiver, "any-receiver", "", "")
+	fs.StringVar(&o.query, "query", "", "")
+	fs.StringVar(&o.conf.sig, "sig", "", "")
+	fs.StringVar(&o.tag, "tag", "", "")
+	fs.StringVar(&o.pos, "pos", "", "")
+	fs.BoolVar(&o.conf.fuzzy, "fuzzy", false, "")
+	fs.BoolVar(&o.conf.instances, "instances", false, "")
+	fs.BoolVar(&o.conf.fast, "fast", false, "")
+	fs.StringVar(&o.conf.typeArg, "type-arg", "", "")
+	fs.Var(&o.excludes, "x", "")
+	fs.Var(&o.excludes, "exclude", "")
+	fs.BoolVar(&o.rank, "rank", false, "")
+	fs.StringVar(&o.rankWeights, "rank-weights", "", "")
+	fs.StringVar(&o.conf.scope, "scope", "func", "")
+	fs.BoolVar(&o.reads, "r", false, "")
+	fs.BoolVar(&o.writes, "w", false, "")
+	fs.StringVar(&o.conf.pinFile, "pin", "", "")
+	fs.StringVar(&o.near, "near", "", "")
+	fs.StringVar(&o.in, "in", "", "")
+	fs.StringVar(&o.from, "from", "", "")
+	fs.StringVar(&o.trimPrefix, "trimprefix", "", "")
+	fs.StringVar(&o.htmlFile, "html", "", "")
+	fs.StringVar(&o.pluginName, "plugin", "", "")
+	fs.IntVar(&o.summarizeDepth, "summarize-by-dir", 0, "")
+	fs.BoolVar(&o.trend, "trend", false, "")
+	fs.StringVar(&o.since, "since", "", "")
+	fs.StringVar(&o.step, "step", "tag", "")
+	fs.BoolVar(&o.asCSV, "csv", false, "")
+}
+
+// parse parses the flags and arguments into the options.
+func (o *options) parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	o.args = fs.Args()
+	o.set = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { o.set[f.Name] = true })
+}
+
+// Modes replacing the expression, by their flags. Every argument is a package
+// in these modes.
+var modes = []string{"query", "any-receiver", "sig", "tag", "pos"}
+
+// mode returns the flag of the mode the options search in, or "expression".
+func (o *options) mode() string {
+	for _, mode := range modes {
+		if o.set[mode] {
+			return mode
+		}
+	}
+	return "expression"
+}
+
+// usages are short usage messages for each mode, printed with errors.
+var usages = map[string]string{
+	"expression": `usage: gosearch [flags] <expression> [packages]
+       gosearch [flags] <expression>... -- [packages]`,
+	"query":        `usage: gosearch -query '<expression> [and|or [not] <expression>]...' [-scope line|func|file] [flags] [packages]`,
+	"any-receiver": `usage: gosearch -any-receiver [package.]name [-d] [flags] [packages]`,
+	"sig":          `usage: gosearch -sig 'func(<parameters>) <results>' [flags] [packages]`,
+	"tag":          `usage: gosearch -tag key[:"value"] [flags] [packages]`,
+	"pos":          `usage: gosearch -pos file:line:column [flags] [packages]`,
+}
+
+// usageError is an error in the flags or arguments of a mode.
+type usageError struct {
+	mode string
+	msg  string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+// usage returns the error followed by the usage of its mode.
+func (e *usageError) usage() string {
+	return e.msg + "\n\n" + usages[e.mode] + "\n\nRun 'gosearch -h' for the full list of flags."
+}
+
+func (o *options) errorf(format string, a ...interface{}) error {
+	return &usageError{mode: o.mode(), msg: fmt.Sprintf(format, a...)}
+}
+
+// filterFlags limit matches of expressions to certain uses.
+var filterFlags = []string{"r", "w", "construct", "assert", "convert", "call", "method-value", "method-expr", "go", "defer"}
+
+// conflicts lists the flags each flag can't be used with.
+var conflicts = []struct {
+	flag   string
+	others []string
+}{
+	{"query", []string{"any-receiver", "sig", "tag", "pos"}},
+	{"pos", []string{"any-receiver", "sig", "tag", "dot-imports", "pin", "merge-vendored"}},
+	{"any-receiver", append([]string{"sig", "tag", "shadow", "as-interface", "chan", "index", "error-checks", "reflect", "dot-imports", "u", "show-def", "typed", "impl", "assigned-to", "dispatch", "instances", "pin", "merge-vendored", "include-deps-report"}, filterFlags...)},
+	{"sig", append([]string{"du", "tag", "shadow", "as-interface", "chan", "index", "error-checks", "reflect", "dot-imports", "u", "show-def", "d", "typed", "impl", "assigned-to", "dispatch", "instances", "pin", "merge-vendored", "include-deps-report"}, filterFlags...)},
+	{"tag", append([]string{"du", "shadow", "as-interface", "chan", "index", "error-checks", "reflect", "dot-imports", "u", "show-def", "d", "typed", "impl", "assigned-to", "dispatch", "instances", "pin", "merge-vendored", "include-deps-report"}, filterFlags...)},
+	{"html", []string{"json", "plugin"}},
+	{"assigned-to", []string{"d"}},
+	{"typed", []string{"assigned-to", "include-deps-report"}},
+	{"impl", []string{"typed", "assigned-to", "include-deps-report", "d"}},
+	{"dispatch", []string{"typed", "impl", "assigned-to", "d"}},
+	{"instances", []string{"d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"r", []string{"w", "d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"w", []string{"d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"construct", []string{"d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"assert", []string{"d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"convert", []string{"d", "typed", "impl", "assigned-to", "dispatch"}},
+	{"call", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances"}},
+	{"method-value", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances"}},
+	{"method-expr", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances"}},
+	{"go", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances"}},
+	{"defer", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances"}},
+	{"u", []string{"d", "typed", "impl", "assigned-to", "instances"}},
+	{"du", append([]string{"d", "u", "typed", "impl", "assigned-to", "instances", "shadow", "as-interface", "chan", "index", "error-checks"}, filterFlags...)},
+	{"embedded", []string{"d", "typed", "impl", "assigned-to", "instances"}},
+	{"shadow", append([]string{"query", "d", "u", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded"}, filterFlags...)},
+	{"as-interface", append([]string{"d", "u", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded", "shadow"}, filterFlags...)},
+	{"chan", append([]string{"d", "u", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded", "shadow", "as-interface"}, filterFlags...)},
+	{"index", append([]string{"d", "u", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded", "shadow", "as-interface", "chan"}, filterFlags...)},
+	{"error-checks", append([]string{"d", "u", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded", "shadow", "as-interface", "chan", "index"}, filterFlags...)},
+	{"dot-imports", append([]string{"u", "shadow"}, filterFlags...)},
+	{"deprecated", []string{"any-receiver", "sig", "tag", "pos", "dot-imports", "shadow", "typed", "impl", "assigned-to", "dispatch", "instances", "as-interface", "chan", "index", "error-checks", "reflect", "r", "w", "pin", "fast"}},
+	{"reflect", []string{"d", "typed", "impl", "assigned-to", "dispatch", "instances", "shadow", "as-interface", "chan", "index", "error-checks"}},
+	{"fuzzy", []string{"pin", "any-receiver", "sig", "tag", "pos"}},
+	{"vendor", []string{"module"}},
+	{"platforms", []string{"goos", "goarch", "rank", "include-deps-report"}},
+	{"fast", []string{"t", "only-tests", "include-deps-report", "merge-vendored", "dispatch"}},
+}
+
+// requires lists flags which require another flag.
+var requires = []struct{ flag, required string }{
+	{"rank-weights", "rank"},
+	{"type-arg", "instances"},
+	{"scope", "query"},
+	{"since", "trend"},
+	{"step", "trend"},
+	{"csv", "trend"},
+}
+
+// Flags which can't be used with syntax expressions, such as label:retry, or
+// expressions naming a package.
+var (
+	syntaxConflicts  = append([]string{"u", "show-def", "pin", "merge-vendored", "include-deps-report", "assigned-to", "typed", "impl", "dispatch", "instances", "embedded", "shadow", "as-interface", "chan", "index", "error-checks", "reflect", "dot-imports"}, filterFlags...)
+	packageConflicts = []string{"u", "du", "show-def", "d", "typed", "impl", "assigned-to", "dispatch", "instances", "embedded", "as-interface", "chan", "index", "error-checks", "reflect", "r", "w", "pin"}
+)
+
+// validateFlags checks the flags which don't depend on the arguments, so
+// they can be checked before -trend runs a search for each revision.
+func (o *options) validateFlags() error {
+	for _, c := range conflicts {
+		if !o.set[c.flag] {
+			continue
+		}
+		for _, other := range c.others {
+			if o.set[other] {
+				return o.errorf("-%s can't be used with -%s", c.flag, other)
+			}
+		}
+	}
+	for _, r := range requires {
+		if o.set[r.flag] && !o.set[r.required] {
+			return o.errorf("-%s requires -%s", r.flag, r.required)
+		}
+	}
+	if o.showBlame && !o.jsonOutput && o.pluginName == "" {
+		return o.errorf("-blame requires -json or -plugin")
+	}
+	return nil
+}
+
+// validate checks the options, returning the config of the search they
+// describe and setting the package patterns to search.
+func (o *options) validate() (*config, error) {
+	if err := o.validateFlags(); err != nil {
+		return nil, err
+	}
+	conf := &o.conf
+	args := o.args
+	var exprs []string
+	switch o.mode() {
+	case "query":
+		o.patterns = args
+		q, qexprs, err := parseQuery(o.query)
+		if err != nil {
+			return nil, o.errorf("%v", err)
+		}
+		positive := make(map[int]bool)
+		q.positive(false, positive)
+		if len(positive) == 0 {
+			return nil, o.errorf("-query must include an expression which isn't negated")
+		}
+		scopeOK := false
+		for _, scope := range queryScopes {
+			scopeOK = scopeOK || scope == conf.scope
+		}
+		if !scopeOK {
+			return nil, o.errorf("-scope must be one of %s, not %q", strings.Join(queryScopes, ", "), conf.scope)
+		}
+		conf.query = q
+		exprs = qexprs
+	case "tag":
+		o.patterns = args
+		conf.tagKey = o.tag
+		if i := strings.Index(o.tag, ":"); i >= 0 {
+			value, err := strconv.Unquote(o.tag[i+1:])
+			if err != nil {
+				return nil, o.errorf("-tag: invalid value %s, expected key or key:\"value\"", o.tag[i+1:])
+			}
+			conf.tagKey, conf.tagValue = o.tag[:i], value
+		}
+		if conf.tagKey == "" {
+			return nil, o.errorf("-tag: no key provided")
+		}
+	case "sig":
+		o.patterns = args
+	case "pos":
+		o.patterns = args
+		pos, err := resolve.ParsePos(o.pos)
+		if err != nil {
+			return nil, o.errorf("-pos %q: %v", o.pos, err)
+		}
+		conf.pos = pos
+		conf.importTests = conf.importTests || strings.HasSuffix(pos.Filename, "_test.go")
+	case "any-receiver":
+		o.patterns = args
+		conf.methodPkg, conf.methodName = "", o.anyReceiver
+		if i := strings.LastIndex(o.anyReceiver, "."); i >= 0 {
+			conf.methodPkg, conf.methodName = strings.Trim(o.anyReceiver[:i], `"`), o.anyReceiver[i+1:]
+		}
+		if !token.IsIdentifier(conf.methodName) {
+			return nil, o.errorf("-any-receiver: invalid method name %q", conf.methodName)
+		}
+	default:
+		if len(args) == 0 || args[0] == "" {
+			return nil, o.errorf("no expression provided")
+		}
+		// Expressions are separated from packages by "--" when there
+		// are several of them.
+		exprs, o.patterns = args[:1], args[1:]
+		for i, arg := range args {
+			if arg == "--" {
+				exprs, o.patterns = args[:i], args[i+1:]
+				break
+			}
+		}
+		if len(exprs) == 0 {
+			return nil, o.errorf("no expression provided before \"--\"")
+		}
+	}
+
+	for _, expr := range exprs {
+		if strings.HasPrefix(expr, ".") || strings.HasSuffix(expr, "/...") {
+			return nil, o.errorf("expression %q looks like a package pattern: the expression must come before the packages", expr)
+		}
+		target, err := resolve.Parse(expr)
+		if err != nil {
+			return nil, o.errorf("expression %q: %v", expr, err)
+		}
+		if hint := quoteHint(expr, target); hint != "" {
+			return nil, o.errorf("expression %q has a package path containing periods, which must be quoted: did you mean '%s'?", expr, hint)
+		}
+		conflicting, kind := packageConflicts, "a package"
+		if target.Kind != "" {
+			conflicting, kind = syntaxConflicts, "syntax"
+		}
+		// Deprecated objects of packages are searched for as objects.
+		if target.Kind != "" || (target.Name == "" && !conf.deprecated) {
+			for _, flag := range conflicting {
+				if o.set[flag] {
+					return nil, o.errorf("-%s can't be used with expression %q, which searches for %s", flag, expr, kind)
+				}
+			}
+		}
+		if conf.dotImports && target.Name != "" {
+			return nil, o.errorf("-dot-imports requires an expression naming a package, not %q", expr)
+		}
+		if conf.deprecated && (target.Kind != "" || target.Name != "") {
+			return nil, o.errorf("-deprecated requires an expression naming a package, not %q", expr)
+		}
+		conf.targets = append(conf.targets, target)
+	}
+	conf.exprs = exprs
+	if conf.pinFile != "" && (len(exprs) > 1 || conf.targets[0].Regexp != nil) {
+		return nil, o.errorf("-pin can't be used with several expressions or regular expressions")
+	}
+
+	if o.reads {
+		conf.filters = append(conf.filters, match.Reads)
+	}
+	if o.writes {
+		conf.filters = append(conf.filters, match.Writes)
+	}
+	conf.access = o.reads || o.writes
+	if o.construct {
+		conf.filters = append(conf.filters, match.Construct)
+	}
+	if o.assert {
+		conf.filters = append(conf.filters, match.Assert)
+	}
+	if o.convert {
+		conf.filters = append(conf.filters, match.Convert)
+	}
+	uses := 0
+	if o.call {
+		uses |= match.UseCall
+	}
+	if o.methodValue {
+		uses |= match.UseMethodValue
+	}
+	if o.methodExpr {
+		uses |= match.UseMethodExpr
+	}
+	if uses != 0 {
+		conf.filters = append(conf.filters, match.MethodUse(uses))
+	}
+	stmts := 0
+	if o.inGo {
+		stmts |= match.InGo
+	}
+	if o.inDefer {
+		stmts |= match.InDefer
+	}
+	if stmts != 0 {
+		conf.filters = append(conf.filters, match.Launched(stmts))
+	}
+	switch o.platformList {
+	case "":
+	case "all":
+		o.platforms = allPlatforms
+	default:
+		for _, p := range strings.Split(o.platformList, ",") {
+			i := strings.Index(p, "/")
+			if i <= 0 || i == len(p)-1 {
+				return nil, o.errorf("-platforms: invalid platform %q, expected os/arch", p)
+			}
+			o.platforms = append(o.platforms, platform{p[:i], p[i+1:]})
+		}
+	}
+	conf.tags = strings.FieldsFunc(o.tags, func(r rune) bool { return r == ',' || r == ' ' })
+	if o.onlyTests {
+		conf.importTests = true
+		conf.filters = append(conf.filters, match.TestFiles)
+	}
+	if o.exported {
+		conf.filters = append(conf.filters, match.Exported)
+	}
+	if o.closure {
+		conf.closure = &match.Closure{}
+		conf.filters = append(conf.filters, conf.closure)
+	}
+	if o.rank {
+		w := match.DefaultWeights
+		if o.rankWeights != "" {
+			var err error
+			if w, err = match.ParseWeights(o.rankWeights); err != nil {
+				return nil, o.errorf("-rank-weights: %v", err)
+			}
+		}
+		conf.rank = &w
+	}
+	if o.near != "" {
+		n, err := match.ParseNear(o.near)
+		if err != nil {
+			return nil, o.errorf("%v", err)
+		}
+		conf.filters = append(conf.filters, n)
+	}
+	if conf.withDefs {
+		conf.showDef = true
+	}
+	if o.in != "" {
+		target, err := resolve.Parse(o.in)
+		if err != nil {
+			return nil, o.errorf("-in %q: %v", o.in, err)
+		}
+		if target.Kind != "" || target.Regexp != nil || target.Name == "" {
+			return nil, o.errorf("-in %q must name a function, method or type", o.in)
+		}
+		conf.in = target
+	}
+	if o.from != "" {
+		target, err := resolve.Parse(o.from)
+		if err != nil {
+			return nil, o.errorf("-from %q: %v", o.from, err)
+		}
+		if target.Kind != "" || target.Regexp != nil || target.Name == "" {
+			return nil, o.errorf("-from %q must name a function or method", o.from)
+		}
+		conf.from = target
+	}
+	for _, x := range o.excludes {
+		o.patterns = append(o.patterns, "-"+x)
+	}
+	return conf, nil
+}
+
+// quoteHint returns the expression with its package quoted if it appears to
+// be a package path containing periods which wasn't quoted, such as
+// github.com/pkg/errors.New, which is parsed as the package "github".
+func quoteHint(expr string, target *resolve.Target) string {
+	if target.Kind != "" || strings.Contains(target.Pkg, "/") || strings.HasPrefix(expr, `"`) {
+		return ""
+	}
+	slash := strings.LastIndex(expr, "/")
+	if slash < 0 || target.Regexp != nil {
+		return ""
+	}
+	end := strings.Index(expr[slash:], ".")
+	if end < 0 {
+		return strconv.Quote(expr)
+	}
+	return strconv.Quote(expr[:slash+end]) + expr[slash+end:]
+}
+
+// missingPackage returns an error describing an expression naming a package
+// which can't be found, or nil if every package is found.
+func missingPackage(conf *config) error {
+	for i, t := range conf.targets {
+		if t.Kind != "" {
+			continue
+		}
+		if _, err := load.BuildContext().Import(t.Pkg, ".", build.FindOnly); err == nil {
+			continue
+		}
+		expr := conf.exprs[i]
+		if t.Name == "" && !strings.Contains(t.Pkg, "/") {
+			return fmt.Errorf("expression %q has no package qualifier and there's no package %s: did you mean 'pkg.%s'?", expr, t.Pkg, t.Pkg)
+		}
+		return fmt.Errorf("expression %q names package %q, which couldn't be found", expr, t.Pkg)
+	}
+	return nil
+}
+
+// fatalUsage prints an error, with the usage of its mode if it's a
+// usageError, and exits.
+func fatalUsage(err error) {
+	if e, ok := err.(*usageError); ok {
+		fmt.Fprintln(os.Stderr, e.usage())
+		os.Exit(2)
+	}
+	fatal(err)
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		args []string
+		// err is the expected error, or empty if the options are valid.
+		err string
+	}{
+		{[]string{"net.Dial", "./..."}, ""},
+		{[]string{"-r", "-w", "net.Dial"}, "-r can't be used with -w"},
+		{[]string{"-tag", "json", "-typed"}, "-tag can't be used with -typed"},
+		{[]string{"-query", "a.A", "-sig", "func()"}, "-query can't be used with -sig"},
+		{[]string{"-rank-weights", "exported=1", "net.Dial"}, "-rank-weights requires -rank"},
+		{[]string{"-scope", "line", "net.Dial"}, "-scope requires -query"},
+		{[]string{"-d", "encoding/json"}, `-d can't be used with expression "encoding/json", which searches for a package`},
+		{[]string{"-typed", "label:retry"}, `-typed can't be used with expression "label:retry", which searches for syntax`},
+		{[]string{"github.com/pkg/errors.New"}, `expression "github.com/pkg/errors.New" has a package path containing periods, which must be quoted: did you mean '"github.com/pkg/errors".New'?`},
+		{[]string{`"github.com/pkg/errors".New`}, ""},
+		{[]string{"-t"}, "no expression provided"},
+		{[]string{"./...", "net.Dial"}, `expression "./..." looks like a package pattern: the expression must come before the packages`},
+		{[]string{"-shadow", "-w", "net.Dial"}, "-shadow can't be used with -w"},
+		{[]string{"-shadow", "label:retry"}, `-shadow can't be used with expression "label:retry", which searches for syntax`},
+		{[]string{"-error-checks", "encoding/json"}, `-error-checks can't be used with expression "encoding/json", which searches for a package`},
+		{[]string{"-platforms", "all", "-goos", "windows", "net.Dial"}, "-platforms can't be used with -goos"},
+		{[]string{"-platforms", "linux/amd64,windows", "net.Dial"}, `-platforms: invalid platform "windows", expected os/arch`},
+		{[]string{"-dot-imports", "net.Dial"}, `-dot-imports requires an expression naming a package, not "net.Dial"`},
+		{[]string{"-pos", "conn.go:42", "./..."}, `-pos "conn.go:42": expected file:line:column`},
+		{[]string{"-pos", "conn.go:42:10", "-sig", "func()"}, "-pos can't be used with -sig"},
+		{[]string{"-fuzzy", "-pin", "api.pin", "net.Dail"}, "-fuzzy can't be used with -pin"},
+		{[]string{"-deprecated", "io/ioutil.ReadAll"}, `-deprecated requires an expression naming a package, not "io/ioutil.ReadAll"`},
+		{[]string{"-from", "label:retry", "net.Dial"}, `-from "label:retry" must name a function or method`},
+		{[]string{"-any-receiver", "io.1Close"}, `-any-receiver: invalid method name "1Close"`},
+	}
+	for _, tt := range tests {
+		o := &options{}
+		fs := flag.NewFlagSet("gosearch", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		o.register(fs)
+		o.parse(fs, tt.args)
+		_, err := o.validate()
+		switch {
+		case err == nil && tt.err != "":
+			t.Errorf("%q: expected error %q", tt.args, tt.err)
+		case err != nil && err.Error() != tt.err:
+			t.Errorf("%q: expected error %q, got %q", tt.args, tt.err, err)
+		}
+	}
+}
+
+func TestExcludes(t *testing.T) {
+	o := &options{}
+	fs := flag.NewFlagSet("gosearch", flag.ContinueOnError)
+	o.register(fs)
+	o.parse(fs, []string{"-x", "./vendor/...", "-exclude", "example.com/repo/gen/...", "net.Dial", "./..."})
+	if _, err := o.validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"./...", "-./vendor/...", "-example.com/repo/gen/..."}
+	if !reflect.DeepEqual(o.patterns, want) {
+		t.Errorf("expected patterns %q, got %q", want, o.patterns)
+	}
+}
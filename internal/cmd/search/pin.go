@@ -0,0 +1,25 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// checkPin compares the fingerprint of the resolved expression against the
+// one recorded in a pin file. If the file doesn't exist, the fingerprint is
+// recorded in it.
+func checkPin(filename, fingerprint string) error {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return ioutil.WriteFile(filename, []byte(fingerprint+"\n"), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	if pinned := string(bytes.TrimSpace(data)); pinned != fingerprint {
+		return fmt.Errorf("%s: expression resolves to %q, but is pinned to %q; remove the file to pin the new object", filename, fingerprint, pinned)
+	}
+	return nil
+}
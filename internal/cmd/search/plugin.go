@@ -0,0 +1,98 @@
+package search
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pluginDir returns the directory exporter plugins are run from.
+func pluginDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gotools", "plugins"), nil
+}
+
+// plugin is a running exporter plugin. Results are written to its standard
+// input in the same form as -json, and its output is passed through.
+type plugin struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// running is the plugin which has been started and not waited for, if any,
+// so fatal can stop it before exiting.
+var running *plugin
+
+// startPlugin runs the named executable within the plugin directory, passing
+// it the search arguments.
+func startPlugin(name string, args []string) (*plugin, error) {
+	dir, err := pluginDir()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid plugin name %q", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("plugin %q not found in %s%s", name, dir, availablePlugins(dir))
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %v", name, err)
+	}
+	running = &plugin{cmd, stdin}
+	return running, nil
+}
+
+func (p *plugin) Write(b []byte) (int, error) { return p.stdin.Write(b) }
+
+// Wait closes the plugin's input and waits for it to exit.
+func (p *plugin) Wait() error {
+	running = nil
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("plugin %s: %v", filepath.Base(p.cmd.Path), err)
+	}
+	return nil
+}
+
+// Kill stops the plugin without waiting for it to read its input, and waits
+// for it to exit.
+func (p *plugin) Kill() {
+	running = nil
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// availablePlugins lists the plugins within a directory for error messages.
+func availablePlugins(dir string) string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return " (available: " + strings.Join(names, ", ") + ")"
+}
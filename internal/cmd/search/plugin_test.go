@@ -0,0 +1,51 @@
+package search
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPluginKill(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("plugin directory is only set through XDG_CONFIG_HOME on linux")
+	}
+	dir, err := ioutil.TempDir("", "gosearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	plugins := filepath.Join(dir, "gotools", "plugins")
+	if err := os.MkdirAll(plugins, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The plugin never reads its input, so it'd run until killed.
+	script := "#!/bin/sh\nexec sleep 60\n"
+	if err := ioutil.WriteFile(filepath.Join(plugins, "sink"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := startPlugin("sink", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running != p {
+		t.Fatal("expected the started plugin to be running")
+	}
+	start := time.Now()
+	p.Kill()
+	if running != nil {
+		t.Errorf("expected no running plugin after Kill")
+	}
+	if p.cmd.ProcessState == nil {
+		t.Errorf("expected the plugin to be waited for")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Kill took %s", elapsed)
+	}
+}
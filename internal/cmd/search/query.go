@@ -0,0 +1,261 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+// query is a boolean combination of expressions, as parsed from -query.
+type query struct {
+	// op is "and", "or" or "not", or empty for an expression.
+	op   string
+	args []*query
+	// expr is the index of the expression among the query's expressions.
+	expr int
+}
+
+// parseQuery parses a query such as
+//
+//	'"database/sql".DB.Query and not (context.Context or context.TODO)'
+//
+// returning it and the expressions it's made of. "not" binds tighter than
+// "and", which binds tighter than "or". Operators may be upper case.
+func parseQuery(s string) (*query, []string, error) {
+	p := &queryParser{}
+	if err := p.tokenize(s); err != nil {
+		return nil, nil, err
+	}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, fmt.Errorf("-query: unexpected %q", p.tokens[p.pos])
+	}
+	return q, p.exprs, nil
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+	exprs  []string
+}
+
+// tokenize splits a query into parentheses, operators and expressions.
+// Expressions end at whitespace or a parenthesis outside of quotes.
+func (p *queryParser) tokenize(s string) error {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			p.tokens = append(p.tokens, s[i:i+1])
+			i++
+		default:
+			start, quoted := i, false
+			for ; i < len(s); i++ {
+				c := s[i]
+				if c == '"' {
+					quoted = !quoted
+				}
+				if !quoted && (c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')') {
+					break
+				}
+			}
+			if quoted {
+				return errors.New("-query: unterminated quote")
+			}
+			p.tokens = append(p.tokens, s[start:i])
+		}
+	}
+	return nil
+}
+
+// next returns the next token if it's the operator, case insensitively.
+func (p *queryParser) next(op string) bool {
+	if p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (*query, error) {
+	return p.parseBinary("or", p.parseAnd)
+}
+
+func (p *queryParser) parseAnd() (*query, error) {
+	return p.parseBinary("and", p.parseNot)
+}
+
+func (p *queryParser) parseBinary(op string, parse func() (*query, error)) (*query, error) {
+	q, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	for p.next(op) {
+		r, err := parse()
+		if err != nil {
+			return nil, err
+		}
+		if q.op == op {
+			q.args = append(q.args, r)
+			continue
+		}
+		q = &query{op: op, args: []*query{q, r}}
+	}
+	return q, nil
+}
+
+func (p *queryParser) parseNot() (*query, error) {
+	if p.next("not") {
+		q, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &query{op: "not", args: []*query{q}}, nil
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("-query: expected an expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case tok == "(":
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.next(")") {
+			return nil, errors.New("-query: missing )")
+		}
+		return q, nil
+	case tok == ")" || strings.EqualFold(tok, "and") || strings.EqualFold(tok, "or"):
+		return nil, fmt.Errorf("-query: unexpected %q", tok)
+	}
+	if _, err := resolve.Parse(tok); err != nil {
+		return nil, fmt.Errorf("-query: %s: %v", tok, err)
+	}
+	for i, expr := range p.exprs {
+		if expr == tok {
+			return &query{expr: i}, nil
+		}
+	}
+	p.exprs = append(p.exprs, tok)
+	return &query{expr: len(p.exprs) - 1}, nil
+}
+
+// eval reports whether the query holds given the expressions present.
+func (q *query) eval(present func(expr int) bool) bool {
+	switch q.op {
+	case "and":
+		for _, arg := range q.args {
+			if !arg.eval(present) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, arg := range q.args {
+			if arg.eval(present) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !q.args[0].eval(present)
+	}
+	return present(q.expr)
+}
+
+// positive returns the expressions which aren't negated, whose matches are
+// reported.
+func (q *query) positive(negated bool, exprs map[int]bool) {
+	switch q.op {
+	case "":
+		if !negated {
+			exprs[q.expr] = true
+		}
+	case "not":
+		q.args[0].positive(!negated, exprs)
+	default:
+		for _, arg := range q.args {
+			arg.positive(negated, exprs)
+		}
+	}
+}
+
+// Scopes the query is evaluated within.
+var queryScopes = []string{"line", "func", "file"}
+
+// scopeKey identifies the scope containing a position.
+type scopeKey struct {
+	filename string
+	// start is the line for line scopes, or the offset of the top level
+	// declaration for function scopes.
+	start int
+}
+
+// evalQuery returns the matches of the query's expressions which aren't
+// negated, within scopes where the query holds. found holds the matches of
+// each expression.
+func evalQuery(prog *loader.Program, searched []*loader.PackageInfo, q *query, scope string, found [][]*ast.Ident) []*ast.Ident {
+	files := make(map[*token.File]*ast.File)
+	for _, info := range searched {
+		for _, f := range info.Files {
+			files[prog.Fset.File(f.Pos())] = f
+		}
+	}
+	key := func(ident *ast.Ident) scopeKey {
+		p := prog.Fset.Position(ident.Pos())
+		k := scopeKey{filename: p.Filename, start: -1}
+		switch scope {
+		case "line":
+			k.start = p.Line
+		case "func":
+			if f, ok := files[prog.Fset.File(ident.Pos())]; ok {
+				for _, decl := range f.Decls {
+					if decl.Pos() <= ident.Pos() && ident.Pos() < decl.End() {
+						k.start = prog.Fset.Position(decl.Pos()).Offset
+						break
+					}
+				}
+			}
+		}
+		return k
+	}
+
+	present := make([]map[scopeKey]bool, len(found))
+	for i, idents := range found {
+		present[i] = make(map[scopeKey]bool)
+		for _, ident := range idents {
+			present[i][key(ident)] = true
+		}
+	}
+	positive := make(map[int]bool)
+	q.positive(false, positive)
+	seen := make(map[*ast.Ident]bool)
+	var idents []*ast.Ident
+	for i, matches := range found {
+		if !positive[i] {
+			continue
+		}
+		for _, ident := range matches {
+			if seen[ident] {
+				continue
+			}
+			k := key(ident)
+			if q.eval(func(expr int) bool { return present[expr][k] }) {
+				seen[ident] = true
+				idents = append(idents, ident)
+			}
+		}
+	}
+	return idents
+}
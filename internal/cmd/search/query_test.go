@@ -0,0 +1,53 @@
+package search
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		exprs []string
+		// present lists the expressions present, by index, and want
+		// whether the query holds.
+		present []int
+		want    bool
+	}{
+		{"a.A", []string{"a.A"}, []int{0}, true},
+		{"a.A and not b.B", []string{"a.A", "b.B"}, []int{0}, true},
+		{"a.A and not b.B", []string{"a.A", "b.B"}, []int{0, 1}, false},
+		{"a.A AND NOT b.B", []string{"a.A", "b.B"}, []int{0, 1}, false},
+		{"a.A or b.B and c.C", []string{"a.A", "b.B", "c.C"}, []int{0}, true},
+		{"(a.A or b.B) and c.C", []string{"a.A", "b.B", "c.C"}, []int{0}, false},
+		{`"x/y.z".T.M and not(a.A)`, []string{`"x/y.z".T.M`, "a.A"}, []int{0}, true},
+		{"a.A and a.A", []string{"a.A"}, []int{0}, true},
+	}
+	for _, tt := range tests {
+		q, exprs, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if len(exprs) != len(tt.exprs) {
+			t.Errorf("parseQuery(%q): expected expressions %q, got %q", tt.query, tt.exprs, exprs)
+			continue
+		}
+		for i := range exprs {
+			if exprs[i] != tt.exprs[i] {
+				t.Errorf("parseQuery(%q): expected expressions %q, got %q", tt.query, tt.exprs, exprs)
+				break
+			}
+		}
+		present := make(map[int]bool)
+		for _, i := range tt.present {
+			present[i] = true
+		}
+		if got := q.eval(func(i int) bool { return present[i] }); got != tt.want {
+			t.Errorf("parseQuery(%q): expected %t with %v present, got %t", tt.query, tt.want, tt.present, got)
+		}
+	}
+
+	for _, query := range []string{"", "a.A and", "(a.A", "a.A b.B", "or a.A", `"a.A`, "a."} {
+		if _, _, err := parseQuery(query); err == nil {
+			t.Errorf("parseQuery(%q): expected error", query)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package search
+
+import "syscall"
+
+// raiseFileLimit raises the soft limit on open files to the hard limit, since
+// loading large programs opens many source files.
+func raiseFileLimit() {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return
+	}
+	if lim.Cur < lim.Max {
+		lim.Cur = lim.Max
+		syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+	}
+}
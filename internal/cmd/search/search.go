@@ -0,0 +1,1577 @@
+package search
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+	"github.com/ericchiang/gotools/internal/render"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gosearch [flags] <expression> [packages]
+
+gosearch performs a type aware search on a list of provided packages. If no
+packages are provided, every package of the module containing the current
+directory is searched, or of every module of the go.work workspace containing
+it, so uses in sibling modules are found. Packages are resolved within the
+workspace, as by the go tool.
+
+The expression is a package followed by a top level type.
+
+	gosearch 'net.Listen' net/http/...
+
+Or a field on a type.
+
+	gosearch 'bytes.Buffer.String' text/template
+
+Package names must be quoted if they contain a period.
+
+	gosearch '"golang.org/x/tools/go/loader".Config.Import' .
+
+Or a package, reporting its imports and every reference to it, followed by
+the member referred to.
+
+	gosearch 'encoding/json' ./...
+
+Several expressions may be searched for with a single load of the packages
+by separating them from the packages with "--". Each match is labeled with
+the expressions it matched.
+
+	gosearch 'net.Dial' 'net.DialTimeout' -- ./...
+
+The name may be a regular expression between slashes, searching for uses of
+every top level name of the package it matches. Each match is labeled with
+the name it matched.
+
+	gosearch 'net/http./^Handle/' ./...
+
+Expressions prefixed by one of the following kinds search for syntax instead
+of uses of an object.
+
+	label:name		goto, break and continue statements using a
+				label, or with -d, the labeled statements.
+	tagkey:key		Struct field tags containing a key, such as
+				tagkey:json.
+	importalias:name	Imports renamed to a name, such as
+				importalias:_.
+
+Directories and Go files may be provided instead of packages, such as
+./internal/db or ./server/conn.go. A file searches the package in its
+directory, only reporting matches within the files provided, and with a
+*_test.go file, loads the package's tests as with -t.
+
+	gosearch 'net.Dial' ./server/conn.go ./internal/db
+
+Packages prefixed by "-" are excluded from the search, such as every package
+but the vendored and generated ones:
+
+	gosearch 'net.Dial' ./... -./vendor/... -./gen/...
+
+The command accepts the following flags:
+
+	-t	Load and search *_test.go files for use of the expression. 
+
+	-a	Allow build errors. Packages that fail to build with be omitted from the search. 
+
+	-goos os, -goarch arch
+		Select files for the operating system and architecture instead
+		of the host's, such as -goos windows to search *_windows.go
+		files, as with the GOOS and GOARCH environment variables of the
+		go tool. GOPACKAGESDRIVER is ignored.
+
+	-platforms list
+		Search each platform in a comma separated list of os/arch pairs,
+		such as linux/amd64,windows/arm64, or "all" for the common
+		ports, and report the matches found by any of them, sorted by
+		position. Each platform loads the packages separately, so this
+		is slower. Can't be used with -goos, -goarch, -rank or
+		-include-deps-report.
+
+	-tags 'tag list'
+		Build tags to consider satisfied when selecting files, separated
+		by spaces or commas, as with the go tool, such as
+		-tags 'integration linux'. Otherwise files guarded by build
+		constraints for other tags aren't searched.
+
+	-d	Search for declarations of expressions instead of uses.
+
+	-query query
+		Instead of an expression, search for a boolean combination of
+		expressions using and, or, not and parentheses, evaluated within
+		each scope given by -scope. Matches of the expressions which
+		aren't negated are reported within scopes where the query holds.
+		Every argument is a package to search. For example, to find
+		queries in functions which don't use a context:
+
+		gosearch -query '"database/sql".DB.Query and not context.Context'
+
+	-scope line|func|file
+		The scope -query is evaluated within: a line, a top level
+		declaration such as a function (default), or a file.
+
+	-tag key[:"value"]
+		Instead of an expression, search for struct fields whose tags
+		contain the key, such as -tag db, followed by the key and value.
+		If a value is provided, such as -tag 'json:"id"', only fields with
+		the value, or whose value starts with it followed by a comma,
+		such as json:"id,omitempty", are reported. Every argument is a
+		package to search. Unlike tagkey: expressions, the field is
+		reported rather than the tag.
+
+	-sig signature
+		Instead of an expression, search for declarations of functions
+		and methods with a signature identical to a function type, such
+		as 'func(context.Context) error'. Parameter and result names are
+		ignored, and since function types are unnamed, these are the
+		functions assignable to it. Packages are named as they're
+		declared, and must be loaded by the search, as any package a
+		matching function uses is. Every argument is a package to search.
+
+	-pos file:line:column
+		Instead of an expression, search for the object declared or used
+		by the identifier at a position, as reported by the compiler,
+		such as -pos ./server/conn.go:42:10. This names objects which
+		expressions can't, such as local variables, function literals
+		assigned to them and labels. The file must belong to one of the
+		searched packages. Every argument is a package to search.
+
+	-any-receiver [package.]name
+		Instead of an expression, search for uses of any method with the
+		name, such as Close, or with -d, their declarations. Every
+		argument is a package to search. If the name is qualified by a
+		package, such as net/http.Close, only methods of types declared
+		in that package are matched. Matches are grouped by the receiver
+		declaring the method, which labels each.
+
+	-r, -w	Only report where a variable or field is read, or written.
+		Writes are assignments, including compound assignments such as
+		+=, increments and decrements, range loops, keyed composite
+		literals and taking its address with &. Assigning to a field or
+		element of a struct or array value writes the variable holding
+		it. Compound assignments, increments, decrements and taking the
+		address also read, and are reported by both.
+
+	-rank	Order matches by likely relevance instead of position: matches
+		outside of tests first, then outside of generated files, then
+		within exported declarations. Matches with the same rank are
+		ordered by position.
+
+	-rank-weights production=N,handwritten=N,exported=N
+		The score given to matches outside of *_test.go files, outside
+		of generated files and within exported declarations, which -rank
+		orders by, highest first. Weights which aren't provided are zero.
+		Defaults to production=4,handwritten=2,exported=1.
+
+	-constraints
+		Annotate each match with the build constraints of its file, as
+		implied by //go:build lines and GOOS/GOARCH file name suffixes.
+
+	-in function|method|type
+		Only report matches within the declaration of a function, method
+		or type, written in the same form as expressions, such as
+		mypkg.Server.ServeHTTP. The declaration of a type includes the
+		methods declared on it.
+
+	-from function|method
+		Only report matches within functions reachable from an entry
+		point, such as mypkg/cmd/server.main: those it calls or
+		references, such as callbacks, and so on. A call through an
+		interface reaches the methods of every type implementing it.
+		Only functions declared in the searched packages and the entry
+		point's package are followed.
+
+	-near construct[:lines]
+		Only report matches in the same top level declaration as a syntax
+		construct, or within the provided number of lines of it. Constructs
+		are panic(), recover(), goto, label, defer, go, select and
+		fallthrough. For example: -near 'recover()'
+
+	-construct
+		Only report where a type is constructed: as the type of a
+		composite literal, including slice, array and map literals with
+		elements eliding the type, or the argument of new.
+
+	-assert	Only report type assertions to a type, such as x.(*pkg.T), and
+		type switch cases matching it.
+
+	-convert
+		Only report explicit conversions to a type, such as pkg.T(x).
+		Conversions to pointers to the type aren't included.
+
+	-call, -method-value, -method-expr
+		Only report functions and methods used in the ways provided:
+		calls, such as t.Method(), method values which aren't called,
+		such as t.Method passed as a callback, and method expressions,
+		such as T.Method or (*T).Method, whether or not they're called.
+		May be combined, such as -method-value -method-expr for every
+		use which isn't a call.
+
+	-go, -defer
+		Only report functions called by go or defer statements, such as
+		cleanup in defer cleanup(), to find fire-and-forget goroutines
+		or deferred calls. Calls within a function literal launched by
+		the statement, such as go func() { serve() }(), are included.
+		Both may be provided to report either.
+
+	-only-tests
+		Load tests as with -t, and only report matches within *_test.go
+		files. An object whose matches are the same with and without
+		-only-tests is only used by tests, and effectively dead in
+		production code.
+
+	-exported
+		Only report matches within the declarations of exported
+		functions, exported methods of exported types, and exported
+		types, including their signatures and bodies, to measure how
+		much of a package's API depends on an internal object.
+
+	-closure
+		Only report identifiers used within function literals, printed
+		with the function enclosing the literal, such as "in closure in
+		Server.Serve". Variables of the enclosing function used by the
+		literal are printed as captured, to find shared state captured
+		by the closures of goroutines.
+
+	-x pattern, -exclude pattern
+		Exclude packages matching the pattern from the search, the same
+		as a "-" prefixed package, such as
+		-exclude 'github.com/org/repo/gen/...'. The pattern is expanded
+		by the go tool after the packages to search, so matches the
+		same packages any other pattern would. May be provided several
+		times, and allows excluding packages when no others are
+		provided, in which case the module containing the current
+		directory is searched.
+
+	-fast	Load dependencies from the export data the go tool compiles for
+		them, instead of type checking their source, only loading the
+		searched packages from source. This is much faster when the
+		expression names an object in a large dependency, such as a
+		third-party module, but GOPACKAGESDRIVER is ignored and -t,
+		-include-deps-report, -merge-vendored and -dispatch can't be used.
+
+	-vendor	Also search the dependencies of the packages outside of the
+		standard library, whether vendored or in the module cache, to
+		find uses within third-party code. Loading their function
+		bodies makes this slower. Can't be used with -module.
+
+	-module	Only search packages belonging to the module containing the
+		current directory.
+
+	-trend	Instead of printing matches, print the number of matches at each
+		revision of the enclosing git repository, by searching a temporary
+		worktree of each. Revisions are controlled by:
+
+		-since rev	The first revision to search.
+		-step tag|commit
+				Search every tag (default), or every commit on the
+				first parent chain, from -since up to HEAD.
+		-csv		Print CSV instead of a table.
+
+	-json	Print each match as a JSON object.
+
+	-quiet	Don't print the summary following the matches: the number of
+		matches, files searched, packages requested, loaded including
+		dependencies, and skipped due to errors, and the time taken.
+		The summary is only printed when the output is a terminal, or
+		to an HTML report. With -json, the summary is an object with
+		Requested, Loaded, Skipped, Files, Matches and Elapsed fields,
+		in seconds.
+
+	-html file
+		Instead of printing matches, write them to a self-contained HTML
+		report grouped by directory and file, showing each match with
+		the surrounding lines syntax highlighted. Each match has an
+		anchor of the form #file:line.
+
+	-trimprefix prefix
+		Remove a prefix from the filenames of matches, such as the
+		directory of a checkout.
+
+	-show-def
+		Before the matches, print the declaration and position of each
+		object the expressions resolved to, to check the intended object
+		was found.
+
+	-fuzzy	When a name in an expression isn't found, such as a misspelled
+		field or method, search for the closest name instead if only one
+		is close, printing the expression used to stderr. Without -fuzzy,
+		the error suggests the closest names.
+
+	-u	Report the declarations of the objects the expressions resolve to
+		along with their uses, printing the declarations first as with
+		-show-def.
+
+	-du	Report the declarations of the objects the expressions resolve to
+		along with their uses, labeling each match def or use, such as
+		"def: func Dial(...)". Unlike -u, the declarations are reported
+		in place, and syntax expressions and -any-receiver are allowed.
+
+	-pin file
+		Record a fingerprint of the object the expression resolves to in
+		the file, or if the file exists, exit with an error when the
+		expression no longer resolves to the recorded object. The
+		fingerprint is the package path, the names leading to the object
+		including any embedded fields, the kind of object and its type, so
+		changing a function's signature or a type's definition also fails
+		the check.
+
+	-merge-vendored
+		Treat every loaded copy of the target package, such as vendored
+		copies and the original, as the same package, and report matches
+		of the expression in any of them.
+
+	-include-deps-report
+		After the matches, print the number of uses within each loaded
+		dependency outside of the searched packages, without listing them.
+		Standard library packages aren't counted. Function bodies of
+		dependencies are otherwise not type checked, so this is slower.
+
+	-plugin name
+		Instead of printing matches, run the named executable within
+		$XDG_CONFIG_HOME/gotools/plugins (~/.config/gotools/plugins by
+		default) with the search arguments, and write each result to
+		its standard input as a JSON object, as printed by -json. The
+		plugin's output is passed through, and gosearch fails if the
+		plugin does.
+
+	-typed	When the expression names a type, report every identifier whose
+		type is the type or a pointer to it, instead of uses of the type
+		name: variables, parameters, results and fields, both where
+		they're declared and used, and conversions to the type. With -d,
+		only declarations are reported.
+
+	-summarize-by-dir N
+		Before the matches, print the number of matches within each
+		directory, truncated to N path elements, such as services/auth
+		for N=2. With -json, each directory is printed as an object with
+		Dir and Matches fields.
+
+	-impl	When the expression names an interface, report the declarations
+		of types in the searched packages which implement it, followed
+		by the methods which satisfy it. Methods promoted from embedded
+		fields name the type declaring them, and if only a pointer to
+		the type implements the interface, the receiver is shown.
+
+	-dispatch
+		When the expression names a method of a concrete type, also
+		report calls, method values and method expressions through
+		interfaces which may dispatch to it, followed by the interface.
+		A call may dispatch to the method if any type in the loaded
+		packages whose method set includes it, such as a type embedding
+		the receiver, implements the interface.
+
+	-embedded
+		Uses of fields and methods promoted through embedded fields,
+		such as d.Close where Derived embeds Base, are reported when
+		searching for mypkg.Base.Close, since they refer to the same
+		object. With -embedded, each is followed by the embedded fields
+		it's promoted through, such as Derived.Base, and uses promoted
+		from instantiations of generic types, such as Derived embedding
+		Base[int], are also reported.
+
+	-reflect
+		When the expression names a type, also report references to it
+		through reflection, which are otherwise missed: values of the
+		type, or pointers, slices or arrays of them, passed to
+		reflect.TypeOf or reflect.ValueOf, marshaled by their struct
+		tags, such as by json.Marshal or (*gob.Encoder).Encode, passed
+		to a function registering them, such as gob.Register, or passed
+		along with a string naming the type, such as to a registry of
+		plugins. Each is followed by how the type is referenced. The
+		checks are heuristics, so may report too much or too little.
+
+	-shadow	Instead of uses, report local declarations which shadow the
+		package level object the expression names where it would
+		otherwise be visible, followed by the kind of declaration and
+		the function declaring it, such as "parameter of Parse". When
+		the expression names a package, report declarations shadowing
+		the name it's imported as, such as a variable named url in a
+		file importing net/url.
+
+	-as-interface
+		When the expression names a concrete type, instead of uses
+		report values of the type, or a pointer to it, which are
+		converted to an interface, such as where it escapes behind
+		io.Writer or error: assignments, composite literal elements,
+		arguments, results, channel sends and explicit conversions.
+		Each is followed by how it's converted, such as "passed as
+		io.Writer". Values which aren't identifiers are matched at
+		their start.
+
+	-chan	When the expression names a type, instead of uses report the
+		operations on channels whose element type is the type or a
+		pointer to it, such as chan mypkg.Event, followed by the kind
+		of operation: send, receive, close or range, and "in select"
+		for the cases of select statements. Each operation is matched
+		at its channel.
+
+	-index	When the expression names a map, slice or array type, instead
+		of uses report index and key operations on values of the type,
+		such as to audit direct access before wrapping the type in
+		methods. Each is followed by the kind of operation: read, write,
+		comma-ok read, delete or slice, and matched at the value
+		indexed.
+
+	-dot-imports
+		When the expression names a package, instead of references to
+		it report the files which dot import it, such as
+		import . "example.com/pkg". Uses of its members through dot
+		imports are unqualified, so are reported with the member
+		followed by "via dot import" when searching for the package.
+
+	-deprecated
+		When the expression names a package, instead of references to
+		it report uses of its deprecated objects, those whose doc
+		comments include a paragraph beginning with "Deprecated: ", and
+		if the package itself is deprecated, its imports. Each is
+		followed by the object and the first sentence of its notice,
+		producing a migration worklist. With several packages separated
+		by "--", such as 'io/ioutil' 'math/rand' -- ./..., each match is
+		labeled with its package. With -d, the deprecated objects are
+		listed instead. Can't be used with -fast, since the package's
+		doc comments are needed.
+
+	-error-checks
+		When the expression names a sentinel error variable or an error
+		type, instead of uses report where errors are checked against
+		it, such as to verify callers handle an error before changing
+		it. Each is followed by the kind of check: errors.Is,
+		errors.As, == or != comparison, switch case, type switch case
+		or type assertion. errors.As is matched at the variable the
+		error is stored in.
+
+	-instances
+		When the expression names a generic function or type, report
+		where it's instantiated instead of uses, followed by the type
+		arguments, whether they're explicit or inferred.
+
+	-type-arg type
+		With -instances, only report instantiations with a type argument
+		which is the type, or is composed of it, such as a slice of it.
+		Types are written as they're printed, such as mypkg.MyType,
+		finding uses of slices.SortFunc sorting a []mypkg.MyType.
+
+	-assigned-to
+		Instead of uses, report the assignments to a variable or field
+		of interface type, including variable declarations and
+		composite literals, followed by the type of the value assigned.
+
+	-blame	Include the author, commit and date of each matched line as
+		reported by git blame. Requires -json or -plugin.
+
+Filenames within the workspace or module containing the current directory are
+printed relative to its root, or relative to the current directory outside
+of a module, so output is the same across machines and checkouts. Filenames
+within GOROOT, GOPATH and the module cache are printed relative to them, such
+as $GOROOT/src/io/io.go.
+
+If the GOPACKAGESDRIVER environment variable names a go/packages driver, or
+a gopackagesdriver binary is found in PATH, package metadata is read from
+the driver instead of the go tool. Set GOPACKAGESDRIVER=off to disable this.
+`
+
+// fileScopes replaces the Go files among the patterns with the directories
+// containing them, returning the absolute paths of the files. Matches within
+// those directories are limited to the files, unless the directory is also
+// provided.
+func fileScopes(patterns []string) ([]string, match.Files, error) {
+	files := make(match.Files)
+	dirs := make(map[string]bool)
+	var scoped []string
+	for _, p := range patterns {
+		info, err := os.Stat(p)
+		if strings.HasPrefix(p, "-") || err != nil {
+			scoped = append(scoped, p)
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			dirs[abs] = true
+			scoped = append(scoped, p)
+			continue
+		}
+		if filepath.Ext(p) != ".go" {
+			return nil, nil, fmt.Errorf("%s isn't a Go file", p)
+		}
+		files[abs] = true
+		dir := filepath.Dir(p)
+		if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, ".") {
+			dir = "." + string(filepath.Separator) + dir
+		}
+		scoped = append(scoped, dir)
+	}
+	for name := range files {
+		if dirs[filepath.Dir(name)] {
+			delete(files, name)
+		}
+	}
+	return scoped, files, nil
+}
+
+// patternList is a flag which may be provided several times.
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, " ") }
+
+func (p *patternList) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// fatal prints the provided arguments to stderr and exits, stopping any
+// running plugin.
+func fatal(a ...interface{}) {
+	if running != nil {
+		running.Kill()
+	}
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+var showColors = isatty.IsTerminal(os.Stdout.Fd()) && render.EnableColors(os.Stdout.Fd())
+
+// Command runs gosearch as the search subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "search",
+	Tool:    "gosearch",
+	Summary: "type aware search for uses of an identifier",
+	Help:    help,
+	Run:     Run,
+}
+
+// Run runs gosearch with the provided arguments.
+func Run(args []string) {
+	start := time.Now()
+	raiseFileLimit()
+
+	o := &options{}
+	fs := flag.NewFlagSet("gosearch", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	o.register(fs)
+	o.parse(fs, args)
+	args = o.args
+	if len(args) == 0 && len(o.set) == 0 {
+		fatal(help)
+	}
+	if o.trend {
+		if err := o.validateFlags(); err != nil {
+			fatalUsage(err)
+		}
+		if err := runTrend(os.Stdout, fs, o.since, o.step, o.asCSV, args); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	conf, err := o.validate()
+	if err != nil {
+		fatalUsage(err)
+	}
+	patterns, files, err := fileScopes(o.patterns)
+	if err != nil {
+		fatal(err)
+	}
+	if len(files) != 0 {
+		conf.filters = append(conf.filters, files)
+		for name := range files {
+			conf.importTests = conf.importTests || strings.HasSuffix(name, "_test.go")
+		}
+	}
+	include, _ := load.SplitPatterns(patterns)
+	if len(include) == 0 && !o.moduleOnly {
+		_, mods, err := load.FindWorkspace(".")
+		if err != nil {
+			fatal(err)
+		}
+		for _, m := range mods {
+			pattern, err := load.ModulePattern(m.Dir)
+			if err != nil {
+				fatal(err)
+			}
+			patterns = append(patterns, pattern)
+			include = append(include, pattern)
+		}
+	}
+	var modPath string
+	if len(include) == 0 || o.moduleOnly {
+		root, path, err := load.FindModule(".")
+		switch {
+		case err == nil:
+			modPath = path
+			if len(include) == 0 {
+				pattern, err := load.ModulePattern(root)
+				if err != nil {
+					fatal(err)
+				}
+				patterns = append(patterns, pattern)
+			}
+		case o.moduleOnly:
+			fatal(err)
+		}
+	}
+
+	// Each platform is searched separately, reading files through the same
+	// snapshot, and their matches are merged.
+	conf.files = new(load.Snapshot)
+	platforms := o.platforms
+	if len(platforms) == 0 {
+		platforms = []platform{{conf.goos, conf.goarch}}
+	}
+	var (
+		results     []result
+		definitions []match.Definition
+		footer      render.Footer
+	)
+	seen := make(map[string]bool)
+	defined := make(map[match.Definition]bool)
+	for _, pl := range platforms {
+		pc := conf
+		if len(o.platforms) != 0 {
+			c := *conf
+			c.goos, c.goarch = pl.goos, pl.goarch
+			pc = &c
+		}
+		fset, idents := searchPlatform(o, pc, patterns, modPath)
+		for _, d := range pc.definitions {
+			if !defined[d] {
+				defined[d] = true
+				definitions = append(definitions, d)
+			}
+		}
+		// Report the platform which searched the most files.
+		if pc.footer.Files >= footer.Files {
+			footer = pc.footer
+		}
+		if len(platforms) == 1 {
+			if pc.rank == nil {
+				sort.Sort(match.ByPos(idents))
+			}
+			if pc.methodName != "" && pc.rank == nil {
+				// Group methods by receiver.
+				sort.SliceStable(idents, func(i, j int) bool {
+					return pc.labels[idents[i]] < pc.labels[idents[j]]
+				})
+			}
+		}
+		for _, ident := range idents {
+			res := result{pc, fset, ident}
+			// Report matches found by several platforms once.
+			key := res.pos().String() + "\x00" + res.label()
+			if len(platforms) == 1 || !seen[key] {
+				seen[key] = true
+				results = append(results, res)
+			}
+		}
+	}
+	if len(platforms) > 1 {
+		sort.SliceStable(results, func(i, j int) bool {
+			a, b := results[i].pos(), results[j].pos()
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
+			}
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Column < b.Column
+		})
+		if conf.methodName != "" {
+			sort.SliceStable(results, func(i, j int) bool {
+				return results[i].label() < results[j].label()
+			})
+		}
+	}
+
+	paths := displayPaths(o.trimPrefix)
+	var r render.Renderer = &render.Text{W: os.Stdout, Color: showColors, Paths: paths}
+	if o.jsonOutput {
+		r = render.NewJSON(os.Stdout, paths)
+	}
+	var report *render.HTML
+	if o.htmlFile != "" {
+		f, err := os.Create(o.htmlFile)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		report = render.NewHTML(f, "gosearch "+strings.Join(args, " "), paths)
+		report.ReadFrom(conf.files)
+		r = report
+	}
+	var p *plugin
+	if o.pluginName != "" {
+		if p, err = startPlugin(o.pluginName, args); err != nil {
+			fatal(err)
+		}
+		r = render.NewJSON(p, paths)
+	}
+	if conf.showDef {
+		for _, d := range definitions {
+			if err := r.Definition(d); err != nil {
+				fatal(err)
+			}
+		}
+	}
+	if o.summarizeDepth > 0 {
+		filenames := make([]string, len(results))
+		for i, res := range results {
+			filenames[i] = res.pos().Filename
+		}
+		if err := r.Summary(render.SummarizeByDir(filenames, paths, o.summarizeDepth)); err != nil {
+			fatal(err)
+		}
+	}
+	constraints := make(match.ConstraintCache)
+	blames := make(match.BlameCache)
+	reader := &match.Reader{Files: conf.files}
+	for _, res := range results {
+		conf, ident := res.conf, res.ident
+		m, err := reader.Read(res.fset, ident)
+		if err != nil {
+			fatal(err)
+		}
+		if o.showConstraints {
+			m.Constraints = constraints.Lookup(m.Filename)
+		}
+		if t, ok := conf.assigned[ident]; ok {
+			m.Detail = types.TypeString(t, match.PackageName)
+		}
+		if d, ok := conf.details[ident]; ok {
+			m.Detail = d
+		}
+		m.Target = conf.labels[ident]
+		m.Kind = conf.kinds[ident]
+		if o.showBlame {
+			if m.Blame, err = blames.Lookup(m.Filename, m.Line); err != nil {
+				fatal(err)
+			}
+		}
+		if err := r.Match(m); err != nil {
+			fatal(err)
+		}
+	}
+	for _, d := range conf.deps {
+		if err := r.Deps(d); err != nil {
+			fatal(err)
+		}
+	}
+	// The summary is only printed to terminals and reports, keeping the
+	// output scripts parse to one match per line.
+	if !o.quiet && (report != nil || p != nil || isatty.IsTerminal(os.Stdout.Fd())) {
+		footer.Matches = len(results)
+		footer.Elapsed = time.Since(start)
+		if err := r.Footer(footer); err != nil {
+			fatal(err)
+		}
+	}
+	if report != nil {
+		if err := report.Close(); err != nil {
+			fatal(err)
+		}
+	}
+	if p != nil {
+		if err := p.Wait(); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// platform is a GOOS and GOARCH to search.
+type platform struct {
+	goos, goarch string
+}
+
+// allPlatforms are the platforms searched by -platforms all, covering the
+// files for each operating system and architecture commonly suffixed.
+var allPlatforms = []platform{
+	{"linux", "amd64"}, {"linux", "arm64"}, {"linux", "386"}, {"linux", "arm"},
+	{"darwin", "amd64"}, {"darwin", "arm64"},
+	{"windows", "amd64"}, {"windows", "arm64"}, {"windows", "386"},
+	{"freebsd", "amd64"}, {"openbsd", "amd64"}, {"netbsd", "amd64"},
+	{"js", "wasm"}, {"wasip1", "wasm"},
+}
+
+// result is a match of a search, with the search it was found by.
+type result struct {
+	conf  *config
+	fset  *token.FileSet
+	ident *ast.Ident
+}
+
+func (r result) pos() token.Position { return r.fset.Position(r.ident.Pos()) }
+
+func (r result) label() string { return r.conf.labels[r.ident] }
+
+// searchPlatform lists the packages matching the patterns and searches them
+// for the platform of the config.
+func searchPlatform(o *options, conf *config, patterns []string, modPath string) (*token.FileSet, []*ast.Ident) {
+	var lister load.Lister = load.GoList{Tags: conf.tags, GOOS: conf.goos, GOARCH: conf.goarch}
+	// The driver's metadata is for the host, so it's only used for it.
+	if path := load.DriverPath(); path != "" && !conf.fast && conf.goos == "" && conf.goarch == "" {
+		conf.driver = load.NewDriver(path, conf.importTests, conf.tags)
+		lister = conf.driver
+	}
+	pkgs, err := load.ListExcluding(lister, patterns...)
+	if err != nil {
+		fatal(err)
+	}
+	for _, target := range conf.targets {
+		if conf.driver != nil && target.Kind == "" && !conf.driver.Has(target.Pkg) {
+			if _, err := conf.driver.List(target.Pkg); err != nil {
+				fatal(err)
+			}
+		}
+	}
+	if o.moduleOnly {
+		var filtered []string
+		for _, pkg := range pkgs {
+			if load.InModule(pkg, modPath) {
+				filtered = append(filtered, pkg)
+			}
+		}
+		if len(filtered) == 0 {
+			fatal("no packages matched within module " + modPath)
+		}
+		pkgs = filtered
+	}
+	if o.vendor {
+		deps, err := load.GoList{Tags: conf.tags, GOOS: conf.goos, GOARCH: conf.goarch}.Deps(pkgs...)
+		if err != nil {
+			fatal(err)
+		}
+		pkgs = append(pkgs, deps...)
+	}
+	conf.packages = pkgs
+
+	fset, idents, err := conf.search()
+	if err != nil {
+		if conf.driver == nil {
+			if missing := missingPackage(conf); missing != nil {
+				fatal(missing)
+			}
+		}
+		fatal(err)
+	}
+	return fset, idents
+}
+
+type config struct {
+	// targets are the parsed expressions to search for.
+	targets []*resolve.Target
+	// exprs are the expressions as provided, which label matches when
+	// there are several.
+	exprs []string
+	// labels is set by search to the expressions each match matched
+	// when there are several targets, or to the receiver of the method
+	// when searching for methods by name.
+	labels map[*ast.Ident]string
+	// methodName, if set, searches for any method with the name instead
+	// of targets, restricted to the types of methodPkg if it's set.
+	methodName, methodPkg string
+	// query, if set, combines the targets, and is evaluated within each
+	// scope: "line", "func" or "file".
+	query *query
+	scope string
+	// rank, if set, orders matches by relevance instead of position.
+	rank *match.Weights
+	// fast loads dependencies from export data instead of source.
+	fast bool
+	// instances reports instantiations of generic targets, limited to
+	// those with typeArg as a type argument if it's set.
+	instances bool
+	typeArg   string
+	// tagKey, if set, searches for struct fields with tags containing the
+	// key, and tagValue if it's set, instead of targets.
+	tagKey, tagValue string
+	// sig, if set, searches for declarations of functions with the
+	// signature instead of targets.
+	sig string
+	// fuzzy replaces names of targets which aren't found by the only
+	// close match.
+	fuzzy bool
+	// pos, if set, searches for the object declared or used at the
+	// position instead of targets.
+	pos *resolve.Pos
+
+	packages    []string
+	tags        []string
+	allowErrors bool
+	importTests bool
+	searchDefs  bool
+	depsReport  bool
+
+	// goos and goarch, if set, select files for that platform instead of
+	// the host's.
+	goos, goarch string
+
+	// pinFile, if set, records the fingerprint of the resolved object or
+	// checks it against a recorded one.
+	pinFile string
+
+	// mergeVendored treats copies of the target package with the same
+	// import path, once any vendor directory is removed, as one package.
+	mergeVendored bool
+
+	// typed searches for identifiers whose type is the target instead of
+	// uses of it.
+	typed bool
+	// assignedTo searches for assignments to the target instead of uses.
+	assignedTo bool
+	// assigned is set by search to the type of the value assigned at each
+	// match when assignedTo is true.
+	assigned map[*ast.Ident]types.Type
+
+	// impl searches for types implementing the target interface.
+	impl bool
+	// access is true if matches are filtered to reads or writes, which
+	// requires the target to be a variable or field.
+	access bool
+	// dispatch also searches for interface method calls which may dispatch
+	// to the target method.
+	dispatch bool
+	// withDefs reports the declarations of the targets along with their
+	// uses.
+	withDefs bool
+	// defsAndUses reports the declarations of the targets along with
+	// their uses, labeling each match. kinds is set by search to the
+	// label of each match, "def" or "use".
+	defsAndUses bool
+	kinds       map[*ast.Ident]string
+	// showDef reports the objects the targets resolved to before the
+	// matches. definitions is set by search to them.
+	showDef     bool
+	definitions []match.Definition
+	// embedded also reports fields and methods promoted from
+	// instantiations of generic types, and describes the embedded fields
+	// each promoted match is reached through.
+	embedded bool
+	// shadow searches for local declarations shadowing the targets, or
+	// the names of the target packages, instead of uses.
+	shadow bool
+	// asInterface searches for values of the target types converted to
+	// interfaces instead of uses.
+	asInterface bool
+	// chanOps searches for operations on channels of the target types
+	// instead of uses.
+	chanOps bool
+	// indexOps searches for index and key operations on values of the
+	// target types instead of uses.
+	indexOps bool
+	// deprecated searches for uses of the objects of the target packages
+	// with deprecation notices instead of references to the packages.
+	deprecated bool
+	// dotImports searches for dot imports of the target package instead
+	// of references to it.
+	dotImports bool
+	// errorChecks searches for checks of errors against the target
+	// sentinel errors or error types instead of uses.
+	errorChecks bool
+	// reflect also reports references to the target types through
+	// reflection, and describes them.
+	reflect bool
+	// details is set by search to describe each match when impl,
+	// dispatch, embedded, reflect, shadow, asInterface, chanOps,
+	// indexOps or errorChecks is true.
+	details map[*ast.Ident]string
+
+	// deps is set by search to the uses in unsearched dependencies when
+	// depsReport is true.
+	deps []match.DepUses
+	// footer is set by search to the number of packages and files
+	// searched.
+	footer render.Footer
+	// files is set by search to the contents of the files loaded, so
+	// matches are printed from the source which was analyzed.
+	files *load.Snapshot
+
+	// driver, if non-nil, locates packages instead of the go tool.
+	driver *load.Driver
+	// filters are applied to matches in order.
+	filters []match.Filter
+	// in, if set, limits matches to the declaration of the function,
+	// method or type it names.
+	in *resolve.Target
+	// from, if set, limits matches to functions reachable from the
+	// function it names.
+	from *resolve.Target
+	// closure, if set, is the filter limiting matches to function
+	// literals, whose details are added to those of the matches.
+	closure *match.Closure
+}
+
+func (c *config) search() (*token.FileSet, []*ast.Ident, error) {
+	if c.files == nil {
+		c.files = new(load.Snapshot)
+	}
+	lc := load.Config{Comments: c.deprecated, AllowErrors: c.allowErrors, Tests: c.importTests, Driver: c.driver, Snapshot: c.files, Tags: c.tags, GOOS: c.goos, GOARCH: c.goarch}
+	if !c.depsReport {
+		// Only the package level declarations of dependencies are needed
+		// to resolve the targets, so skip checking their function bodies.
+		searched := make(map[string]bool)
+		for _, pkg := range c.packages {
+			searched[pkg] = true
+		}
+		if c.from != nil {
+			searched[c.from.Pkg] = true
+		}
+		lc.Bodies = func(path string) bool {
+			return searched[strings.TrimSuffix(path, "_test")]
+		}
+	}
+
+	// Load and evaluate the types of the target packages and all packages
+	// which import them. Syntax targets don't name a package to resolve.
+	var targetPkgs []string
+	for _, t := range c.targets {
+		if t.Kind == "" {
+			targetPkgs = append(targetPkgs, t.Pkg)
+		}
+	}
+	if c.in != nil {
+		targetPkgs = append(targetPkgs, c.in.Pkg)
+	}
+	if c.from != nil {
+		targetPkgs = append(targetPkgs, c.from.Pkg)
+	}
+	loadProgram := lc.Load
+	if c.fast {
+		loadProgram = func(pkgs ...string) (*loader.Program, error) {
+			return lc.LoadFast(pkgs, c.packages)
+		}
+	}
+	prog, err := loadProgram(append(targetPkgs, c.packages...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.assigned = make(map[*ast.Ident]types.Type)
+	c.details = make(map[*ast.Ident]string)
+	c.labels = make(map[*ast.Ident]string)
+	searched := c.searched(prog)
+	c.footer = render.Footer{
+		Requested: len(c.packages),
+		Loaded:    len(prog.AllPackages),
+		Skipped:   len(c.packages),
+	}
+	for _, info := range searched {
+		c.footer.Files += len(info.Files)
+		if prog.Imported[info.Pkg.Path()] == info {
+			c.footer.Skipped--
+		}
+	}
+	var idents []*ast.Ident
+	if c.methodName != "" {
+		idents, c.labels = match.FindMethods(searched, c.methodName, c.methodPkg, c.searchDefs)
+		if c.defsAndUses {
+			defs, labels := match.FindMethods(searched, c.methodName, c.methodPkg, true)
+			idents = append(idents, defs...)
+			for ident, l := range labels {
+				c.labels[ident] = l
+			}
+		}
+	}
+	if c.tagKey != "" {
+		idents, c.details = match.FindTags(searched, c.tagKey, c.tagValue)
+	}
+	if c.sig != "" {
+		sig, err := resolve.Signature(prog, c.sig)
+		if err != nil {
+			return nil, nil, err
+		}
+		idents = match.FindSignature(searched, sig)
+	}
+	depObjs := make(map[types.Object]bool)
+	if c.pos != nil {
+		obj, err := c.pos.Object(prog.Fset, searched)
+		if err != nil {
+			return nil, nil, err
+		}
+		objs := map[types.Object]bool{obj: true}
+		if idents, err = c.searchObjects(prog, searched, objs); err != nil {
+			return nil, nil, err
+		}
+		depObjs[obj] = true
+		c.definitions = append(c.definitions, match.Define(prog.Fset, obj))
+	}
+	// Regular expressions are expanded into a target for each name they
+	// match, labeled by the package and name.
+	type expanded struct {
+		target *resolve.Target
+		// expr is the index of the expression the target came from.
+		expr  int
+		label string
+	}
+	var targets []expanded
+	regexps := false
+	for i, t := range c.targets {
+		if t.Regexp == nil {
+			targets = append(targets, expanded{target: t, expr: i})
+			continue
+		}
+		regexps = true
+		info := prog.Imported[t.Pkg]
+		names, err := t.Expand(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range names {
+			targets = append(targets, expanded{name, i, info.Pkg.Name() + "." + name.Name})
+		}
+	}
+	byTarget := make([][]*ast.Ident, len(c.targets))
+	for _, t := range targets {
+		found, objs, err := c.searchTarget(prog, searched, t.target)
+		if err != nil {
+			return nil, nil, err
+		}
+		byTarget[t.expr] = append(byTarget[t.expr], found...)
+		var defs []match.Definition
+		for obj := range objs {
+			depObjs[obj] = true
+			defs = append(defs, match.Define(prog.Fset, obj))
+		}
+		sort.Slice(defs, func(i, j int) bool {
+			if defs[i].Filename != defs[j].Filename {
+				return defs[i].Filename < defs[j].Filename
+			}
+			return defs[i].Line < defs[j].Line
+		})
+		c.definitions = append(c.definitions, defs...)
+		for _, ident := range found {
+			if len(targets) == 1 && !regexps {
+				idents = append(idents, ident)
+				continue
+			}
+			// Label each match with the targets it matched, reporting
+			// identifiers matched by several targets once.
+			label := t.label
+			if label == "" {
+				label = c.exprs[t.expr]
+			}
+			if l, ok := c.labels[ident]; ok {
+				c.labels[ident] = l + ", " + label
+				continue
+			}
+			c.labels[ident] = label
+			idents = append(idents, ident)
+		}
+	}
+	if c.query != nil {
+		idents = evalQuery(prog, searched, c.query, c.scope, byTarget)
+	}
+	filters := c.filters
+	if c.in != nil {
+		objs, err := c.in.Resolve(prog, c.mergeVendored)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-in: %v", err)
+		}
+		for obj := range objs {
+			switch obj.(type) {
+			case *types.Func, *types.TypeName:
+			default:
+				return nil, nil, fmt.Errorf("-in: %s isn't a function, method or type", obj.Name())
+			}
+		}
+		filters = append([]match.Filter{match.Within(objs)}, filters...)
+	}
+	if c.from != nil {
+		roots, err := c.from.Resolve(prog, c.mergeVendored)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-from: %v", err)
+		}
+		for obj := range roots {
+			if _, ok := obj.(*types.Func); !ok {
+				return nil, nil, fmt.Errorf("-from: %s isn't a function or method", obj.Name())
+			}
+		}
+		// Follow calls from the entry point's package when it isn't
+		// searched, such as a main package calling into the searched
+		// packages.
+		pkgs := searched
+		if info := prog.Imported[c.from.Pkg]; info != nil {
+			found := false
+			for _, s := range searched {
+				found = found || s == info
+			}
+			if !found {
+				pkgs = append([]*loader.PackageInfo{info}, searched...)
+			}
+		}
+		filters = append([]match.Filter{match.Within(match.Reachable(pkgs, roots))}, filters...)
+	}
+	for _, f := range filters {
+		idents = f.Filter(prog, searched, idents)
+	}
+	if c.defsAndUses {
+		c.kinds = make(map[*ast.Ident]string, len(idents))
+		for _, ident := range idents {
+			c.kinds[ident] = "use"
+			for _, info := range searched {
+				if info.Defs[ident] != nil {
+					c.kinds[ident] = "def"
+					break
+				}
+			}
+		}
+	}
+	if c.closure != nil {
+		for ident, d := range c.closure.Details {
+			if prev, ok := c.details[ident]; ok {
+				d = prev + ", " + d
+			}
+			c.details[ident] = d
+		}
+	}
+	if c.rank != nil {
+		match.Rank(prog, searched, idents, *c.rank)
+	}
+	if c.depsReport {
+		c.deps = match.CountDeps(prog, depObjs, c.packages, c.searchDefs)
+	}
+	return prog.Fset, idents, nil
+}
+
+// searchTarget returns the matches of a single target within the searched
+// packages, and the objects it resolved to.
+func (c *config) searchTarget(prog *loader.Program, searched []*loader.PackageInfo, target *resolve.Target) ([]*ast.Ident, map[types.Object]bool, error) {
+	if target.Kind != "" {
+		idents := match.FindSyntax(searched, target, c.searchDefs)
+		if c.defsAndUses {
+			idents = append(idents, match.FindSyntax(searched, target, true)...)
+		}
+		return idents, nil, nil
+	}
+
+	if target.Name == "" {
+		if _, ok := prog.Imported[target.Pkg]; !ok {
+			return nil, nil, fmt.Errorf("package %s wasn't loaded", target.Pkg)
+		}
+		if c.deprecated {
+			return c.searchDeprecated(prog, searched, prog.Imported[target.Pkg])
+		}
+		find := match.FindPackage
+		if c.shadow {
+			find = match.FindPackageShadows
+		}
+		if c.dotImports {
+			find = match.FindDotImports
+		}
+		idents, details := find(searched, target.Pkg, c.mergeVendored)
+		for ident, d := range details {
+			c.details[ident] = d
+		}
+		return idents, nil, nil
+	}
+
+	// Determine the type of the provided expression. With -fuzzy, a name
+	// which isn't found is replaced by the only close match, until every
+	// name is found, and the target is updated so it's reported once.
+	objs, err := target.Resolve(prog, c.mergeVendored)
+	for c.fuzzy && err != nil {
+		lerr, ok := err.(*resolve.LookupError)
+		if !ok || lerr.Unambiguous() == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "gosearch: using %s instead of %s\n", lerr.Unambiguous(), target)
+		*target = *lerr.Unambiguous()
+		objs, err = target.Resolve(prog, c.mergeVendored)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.pinFile != "" {
+		fingerprint, err := target.Fingerprint(prog.Imported[target.Pkg])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkPin(c.pinFile, fingerprint); err != nil {
+			return nil, nil, err
+		}
+	}
+	idents, err := c.searchObjects(prog, searched, objs)
+	return idents, objs, err
+}
+
+// searchDeprecated returns the matches of the objects of a package with
+// deprecation notices, and if the package is deprecated, its imports. Each is
+// detailed by the deprecated object and its notice.
+func (c *config) searchDeprecated(prog *loader.Program, searched []*loader.PackageInfo, info *loader.PackageInfo) ([]*ast.Ident, map[types.Object]bool, error) {
+	deprecated := match.Deprecations(info)
+	objs := make(map[types.Object]bool, len(deprecated))
+	for obj := range deprecated {
+		objs[obj] = true
+	}
+	idents, err := c.searchObjects(prog, searched, objs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range searched {
+		for _, m := range []map[*ast.Ident]types.Object{pkg.Uses, pkg.Defs} {
+			for ident, obj := range m {
+				if _, ok := c.details[ident]; !ok && deprecated[obj] != "" {
+					c.details[ident] = deprecated[obj]
+				}
+			}
+		}
+	}
+	if notice := match.PackageDeprecation(info); notice != "" && !c.searchDefs {
+		imports, details := match.FindPackage(searched, info.Pkg.Path(), c.mergeVendored)
+		for _, ident := range imports {
+			if strings.HasSuffix(details[ident], "import") {
+				idents = append(idents, ident)
+				c.details[ident] = info.Pkg.Name() + ": " + notice
+			}
+		}
+	}
+	return idents, objs, nil
+}
+
+// searchObjects returns the matches of the objects within the searched
+// packages, as selected by the mode.
+func (c *config) searchObjects(prog *loader.Program, searched []*loader.PackageInfo, objs map[types.Object]bool) ([]*ast.Ident, error) {
+	if c.access {
+		for obj := range objs {
+			if _, ok := obj.(*types.Var); !ok {
+				return nil, errors.New("-r and -w require an expression naming a variable or field")
+			}
+		}
+	}
+
+	// Search for uses of that type.
+	var idents []*ast.Ident
+	var details map[*ast.Ident]string
+	if c.assignedTo {
+		for obj := range objs {
+			if _, ok := obj.(*types.Var); !ok || !types.IsInterface(obj.Type()) {
+				return nil, errors.New("-assigned-to requires a variable or field of interface type")
+			}
+		}
+		var assigned map[*ast.Ident]types.Type
+		idents, assigned = match.FindAssignments(searched, objs)
+		for ident, t := range assigned {
+			c.assigned[ident] = t
+		}
+	} else if c.impl {
+		for obj := range objs {
+			if _, ok := obj.(*types.TypeName); !ok || !types.IsInterface(obj.Type()) {
+				return nil, errors.New("-impl requires an expression naming an interface")
+			}
+		}
+		idents, details = match.FindImplementers(searched, objs)
+	} else if c.instances {
+		for obj := range objs {
+			if !isGeneric(obj) {
+				return nil, errors.New("-instances requires an expression naming a generic function or type")
+			}
+		}
+		idents, details = match.FindInstances(searched, objs, c.typeArg)
+	} else if c.shadow {
+		for obj := range objs {
+			if obj.Parent() != obj.Pkg().Scope() {
+				return nil, errors.New("-shadow requires an expression naming a package or a package level object")
+			}
+		}
+		idents, details = match.FindShadows(searched, objs)
+	} else if c.asInterface {
+		for obj := range objs {
+			if _, ok := obj.(*types.TypeName); !ok || types.IsInterface(obj.Type()) {
+				return nil, errors.New("-as-interface requires an expression naming a concrete type")
+			}
+		}
+		idents, details = match.FindInterfaceConversions(searched, objs)
+	} else if c.chanOps {
+		for obj := range objs {
+			if _, ok := obj.(*types.TypeName); !ok {
+				return nil, errors.New("-chan requires an expression naming a type")
+			}
+		}
+		idents, details = match.FindChanOps(searched, objs)
+	} else if c.indexOps {
+		for obj := range objs {
+			ok := false
+			if _, isType := obj.(*types.TypeName); isType {
+				switch obj.Type().Underlying().(type) {
+				case *types.Map, *types.Slice, *types.Array:
+					ok = true
+				}
+			}
+			if !ok {
+				return nil, errors.New("-index requires an expression naming a map, slice or array type")
+			}
+		}
+		idents, details = match.FindIndexOps(searched, objs)
+	} else if c.errorChecks {
+		for obj := range objs {
+			if !isError(obj) {
+				return nil, errors.New("-error-checks requires an expression naming a package level error variable or error type")
+			}
+		}
+		idents, details = match.FindErrorChecks(searched, objs)
+	} else if c.typed {
+		for obj := range objs {
+			if _, ok := obj.(*types.TypeName); !ok {
+				return nil, errors.New("-typed requires an expression naming a type")
+			}
+		}
+		idents = match.FindTyped(searched, objs, c.searchDefs)
+	} else {
+		idents = match.Find(searched, objs, c.searchDefs)
+		if c.withDefs || c.defsAndUses {
+			idents = append(idents, match.Find(searched, objs, true)...)
+		}
+		if c.dispatch {
+			for obj := range objs {
+				if !isConcreteMethod(obj) {
+					return nil, errors.New("-dispatch requires an expression naming a method of a concrete type")
+				}
+			}
+			var all []*loader.PackageInfo
+			for _, info := range prog.AllPackages {
+				all = append(all, info)
+			}
+			var dispatched []*ast.Ident
+			dispatched, details = match.FindDispatch(all, searched, objs)
+			idents = append(idents, dispatched...)
+		}
+		if c.embedded {
+			found := make(map[*ast.Ident]bool, len(idents))
+			for _, ident := range idents {
+				found[ident] = true
+			}
+			promoted, promotedDetails := match.FindPromoted(searched, objs)
+			for _, ident := range promoted {
+				if !found[ident] {
+					idents = append(idents, ident)
+				}
+				c.details[ident] = promotedDetails[ident]
+			}
+		}
+		if c.reflect {
+			for obj := range objs {
+				if _, ok := obj.(*types.TypeName); !ok {
+					return nil, errors.New("-reflect requires an expression naming a type")
+				}
+			}
+			found := make(map[*ast.Ident]bool, len(idents))
+			for _, ident := range idents {
+				found[ident] = true
+			}
+			reflected, reflectDetails := match.FindReflect(searched, objs)
+			for _, ident := range reflected {
+				if !found[ident] {
+					idents = append(idents, ident)
+				}
+				c.details[ident] = reflectDetails[ident]
+			}
+		}
+	}
+	for ident, d := range details {
+		c.details[ident] = d
+	}
+	return idents, nil
+}
+
+// searched returns the packages to search which loaded without errors. When
+// tests are loaded, the external test packages of the packages, such as
+// package foo_test, are included.
+func (c *config) searched(prog *loader.Program) []*loader.PackageInfo {
+	var searched []*loader.PackageInfo
+	isSearched := make(map[string]bool, len(c.packages))
+	for _, pkg := range c.packages {
+		isSearched[pkg] = true
+		if info := prog.Imported[pkg]; len(info.Errors) == 0 {
+			searched = append(searched, info)
+		}
+	}
+	if c.importTests {
+		for _, info := range prog.Created {
+			if isSearched[strings.TrimSuffix(info.Pkg.Path(), "_test")] && len(info.Errors) == 0 {
+				searched = append(searched, info)
+			}
+		}
+	}
+	return searched
+}
+
+// isError reports whether obj is a package level variable of a type
+// implementing error, or a type which implements error or whose pointer does.
+func isError(obj types.Object) bool {
+	errorType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+	switch obj := obj.(type) {
+	case *types.Var:
+		return obj.Parent() == obj.Pkg().Scope() && types.Implements(obj.Type(), errorType)
+	case *types.TypeName:
+		return types.Implements(obj.Type(), errorType) || types.Implements(types.NewPointer(obj.Type()), errorType)
+	}
+	return false
+}
+
+// isGeneric reports whether obj is a generic function or type.
+func isGeneric(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Type().(*types.Signature).TypeParams().Len() > 0
+	case *types.TypeName:
+		named, ok := obj.Type().(*types.Named)
+		return ok && named.TypeParams().Len() > 0
+	}
+	return false
+}
+
+// isConcreteMethod reports whether obj is a method whose receiver isn't an
+// interface.
+func isConcreteMethod(obj types.Object) bool {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := f.Type().(*types.Signature).Recv()
+	return recv != nil && !types.IsInterface(recv.Type())
+}
+
+// displayPaths returns how filenames should be printed: relative to the
+// workspace root, module root or current directory, or to GOROOT, GOPATH and
+// the module cache outside of them.
+func displayPaths(trimPrefix string) render.Paths {
+	paths := render.Paths{TrimPrefix: trimPrefix}
+	if root, mods, _ := load.FindWorkspace("."); len(mods) != 0 {
+		paths.Dir = root
+	} else if root, _, err := load.FindModule("."); err == nil {
+		paths.Dir = root
+	} else {
+		paths.Dir, _ = os.Getwd()
+	}
+	ctxt := load.BuildContext()
+	if ctxt.GOROOT != "" {
+		paths.Roots = append(paths.Roots, render.Root{Name: "$GOROOT", Dir: ctxt.GOROOT})
+	}
+	gopath := filepath.SplitList(ctxt.GOPATH)
+	for _, dir := range gopath {
+		paths.Roots = append(paths.Roots, render.Root{Name: "$GOPATH", Dir: dir})
+	}
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" && len(gopath) > 0 {
+		modCache = filepath.Join(gopath[0], "pkg", "mod")
+	}
+	if modCache != "" {
+		paths.Roots = append(paths.Roots, render.Root{Name: "$GOMODCACHE", Dir: modCache})
+	}
+	return paths
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+	"github.com/ericchiang/gotools/internal/resolve"
+)
+
+func TestSearchExternalTests(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "gosearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	files := map[string]string{
+		"a.go":      "package a\n\nfunc Foo() {}\n",
+		"a_test.go": "package a\n\nfunc init() { Foo() }\n",
+		"x_test.go": "package a_test\n\nimport \"ex/a\"\n\nfunc init() { a.Foo() }\n",
+	}
+	dir := filepath.Join(gopath, "src", "ex", "a")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(gopath string) { build.Default.GOPATH = gopath }(build.Default.GOPATH)
+	build.Default.GOPATH = gopath
+
+	conf := config{
+		targets:     []*resolve.Target{{Pkg: "ex/a", Name: "Foo"}},
+		packages:    []string{"ex/a"},
+		importTests: true,
+		filters:     []match.Filter{match.TestFiles},
+	}
+	fset, idents, err := conf.search()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ident := range idents {
+		got = append(got, filepath.Base(fset.Position(ident.Pos()).Filename))
+	}
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "a_test.go" || got[1] != "x_test.go" {
+		t.Errorf("expected matches in a_test.go and x_test.go, got %q", got)
+	}
+	if conf.footer.Skipped != 0 {
+		t.Errorf("expected no skipped packages, got %d", conf.footer.Skipped)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	stdLib, err := load.GoList{}.List("std")
+	if err != nil {
+		b.Fatal(err)
+	}
+	config := config{
+		targets:  []*resolve.Target{{Pkg: "net", Name: "Dial"}},
+		packages: stdLib,
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := config.search(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
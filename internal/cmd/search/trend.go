@@ -0,0 +1,233 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericchiang/gotools/internal/cli"
+)
+
+// trendFlags are consumed by -trend and not passed to the search run at each
+// revision.
+var trendFlags = map[string]bool{
+	"trend": true, "since": true, "step": true, "csv": true,
+	"json": true, "blame": true, "plugin": true,
+}
+
+// trendPoint is the number of matches at a single revision.
+type trendPoint struct {
+	rev   string
+	date  string
+	count int
+}
+
+// runTrend runs the search at each revision since the provided one and
+// prints the number of matches at each.
+func runTrend(w io.Writer, fs *flag.FlagSet, since, step string, asCSV bool, args []string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("-trend: could not find git in PATH")
+	}
+	root, err := git("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	realCwd, err := filepath.EvalSymlinks(cwd)
+	if err != nil {
+		return err
+	}
+	subdir, err := filepath.Rel(root, realCwd)
+	if err != nil {
+		return err
+	}
+	importPath := gopathImportPath(cwd, subdir)
+	revs, err := trendRevisions(since, step)
+	if err != nil {
+		return err
+	}
+	self, err := cli.Self()
+	if err != nil {
+		return err
+	}
+
+	// Pass along every flag which was set, except for those which control
+	// the trend itself, and request JSON without the footer so matches
+	// are easy to count.
+	childArgs := []string{"-json", "-quiet"}
+	fs.Visit(func(f *flag.Flag) {
+		if !trendFlags[f.Name] {
+			childArgs = append(childArgs, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	childArgs = append(childArgs, args...)
+
+	var points []trendPoint
+	for _, rev := range revs {
+		p, err := trendAt(root, subdir, importPath, rev, self, childArgs)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rev, err)
+		}
+		points = append(points, p)
+	}
+
+	if asCSV {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"revision", "date", "uses"})
+		for _, p := range points {
+			cw.Write([]string{p.rev, p.date, strconv.Itoa(p.count)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tDATE\tUSES")
+	for _, p := range points {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", p.rev, p.date, p.count)
+	}
+	return tw.Flush()
+}
+
+// trendRevisions lists the revisions to search, oldest first, ending with
+// HEAD. step is either "tag", for every tag, or "commit", for every commit
+// on the first parent chain.
+func trendRevisions(since, step string) ([]string, error) {
+	var revs []string
+	switch step {
+	case "tag":
+		out, err := git("", "tag", "--merged", "HEAD", "--sort=creatordate")
+		if err != nil {
+			return nil, err
+		}
+		tags := strings.Fields(out)
+		start := 0
+		if since != "" {
+			start = -1
+			for i, tag := range tags {
+				if tag == since {
+					start = i
+				}
+			}
+			if start < 0 {
+				return nil, fmt.Errorf("-since: no tag %q reachable from HEAD", since)
+			}
+		}
+		revs = tags[start:]
+	case "commit":
+		if since == "" {
+			return nil, errors.New("-step commit requires -since")
+		}
+		out, err := git("", "rev-list", "--first-parent", "--reverse", since+"..HEAD")
+		if err != nil {
+			return nil, err
+		}
+		commits := strings.Fields(out)
+		if len(commits) > 0 {
+			// The last commit is HEAD, which is added below.
+			commits = commits[:len(commits)-1]
+		}
+		revs = []string{since}
+		for _, c := range commits {
+			revs = append(revs, c[:12])
+		}
+	default:
+		return nil, fmt.Errorf("-step: expected tag or commit, got %q", step)
+	}
+	if len(revs) == 0 || revs[len(revs)-1] != "HEAD" {
+		revs = append(revs, "HEAD")
+	}
+	return revs, nil
+}
+
+// gopathImportPath returns the import path of the repository root if the
+// current directory is within a GOPATH, or an empty string otherwise.
+func gopathImportPath(cwd, subdir string) string {
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		rel, err := filepath.Rel(filepath.Join(gopath, "src"), cwd)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if subdir != "." {
+			rel = strings.TrimSuffix(rel, "/"+filepath.ToSlash(subdir))
+		}
+		return rel
+	}
+	return ""
+}
+
+// trendAt checks out a revision into a temporary worktree and counts the
+// matches found by running gosearch within it. When importPath is set, the
+// worktree is placed at that path within a temporary GOPATH entry.
+func trendAt(root, subdir, importPath, rev string, self, args []string) (trendPoint, error) {
+	p := trendPoint{rev: rev}
+	date, err := git("", "log", "-1", "--format=%cs", rev)
+	if err != nil {
+		return p, err
+	}
+	p.date = date
+
+	dir, err := ioutil.TempDir("", "gosearch-trend")
+	if err != nil {
+		return p, err
+	}
+	defer os.RemoveAll(dir)
+	worktree := filepath.Join(dir, filepath.Base(root))
+	if importPath != "" {
+		worktree = filepath.Join(dir, "src", filepath.FromSlash(importPath))
+		if err := os.MkdirAll(filepath.Dir(worktree), 0755); err != nil {
+			return p, err
+		}
+	}
+	if _, err := git(root, "worktree", "add", "--detach", worktree, rev); err != nil {
+		return p, err
+	}
+	defer git(root, "worktree", "remove", "--force", worktree)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(self[0], append(self[1:], args...)...)
+	cmd.Dir = filepath.Join(worktree, subdir)
+	if importPath != "" {
+		gopath := dir + string(filepath.ListSeparator) + build.Default.GOPATH
+		cmd.Env = append(os.Environ(), "GOPATH="+gopath, "PWD="+cmd.Dir)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return p, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	s := bufio.NewScanner(&stdout)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		p.count++
+	}
+	return p, s.Err()
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
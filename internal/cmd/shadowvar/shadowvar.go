@@ -0,0 +1,249 @@
+// Package shadowvar implements goshadowvar, which reports local variable
+// declarations shadowing another variable in the ways most likely to hide a
+// bug.
+package shadowvar
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: goshadowvar [flags] <list of packages>
+
+goshadowvar reports local variable declarations which shadow another
+variable in one of the ways most likely to hide a bug:
+
+	result	The shadowed variable is a named result of the function, so
+		a bare return doesn't return the new value.
+	type	The new variable has a different type than the one it shadows.
+	err	An error variable is shadowed within an if, for, switch or
+		block statement, and the outer variable is used after it, so
+		errors assigned in the inner scope may be lost.
+
+Declarations of the form "x := x" are ignored.
+
+Flags:
+
+	-json	Print each shadowed variable as a JSON object.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+goshadowvar exits with status 1 if any shadowed variables are found.
+`
+
+// Command runs goshadowvar as the shadowvar subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "shadowvar",
+	Tool:    "goshadowvar",
+	Summary: "report shadowed variables likely to hide bugs",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs goshadowvar with the provided arguments.
+func Run(args []string) {
+	jsonOutput := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("goshadowvar", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+	var diags []render.Diagnostic
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		for _, s := range findShadows(prog.Fset, info) {
+			diags = append(diags, s)
+		}
+	}
+	if err := render.PrintProblems(os.Stdout, jsonOutput, diags); err != nil {
+		fatal(err)
+	}
+	if len(diags) != 0 {
+		os.Exit(1)
+	}
+}
+
+// Kinds of shadowing, in order of precedence.
+const (
+	kindResult = "result"
+	kindType   = "type"
+	kindErr    = "err"
+)
+
+// shadow is a variable declaration which shadows another variable.
+type shadow struct {
+	render.Problem
+	Kind     string
+	Name     string
+	Shadowed string // position of the shadowed declaration
+}
+
+// findShadows returns the high risk shadowed variables declared within the
+// package.
+func findShadows(fset *token.FileSet, info *loader.PackageInfo) []*shadow {
+	// Named results of every function, and the positions each local
+	// variable is used at.
+	results := make(map[*types.Var]bool)
+	uses := make(map[*types.Var][]token.Pos)
+	for ident, obj := range info.Uses {
+		if v, ok := obj.(*types.Var); ok && !v.IsField() {
+			uses[v] = append(uses[v], ident.Pos())
+		}
+	}
+
+	var shadows []*shadow
+	check := func(ident *ast.Ident, rhs ast.Expr) {
+		v, ok := info.Defs[ident].(*types.Var)
+		if !ok || ident.Name == "_" {
+			return
+		}
+		if id, ok := rhs.(*ast.Ident); ok && id.Name == ident.Name {
+			// x := x
+			return
+		}
+		scope := v.Parent()
+		if scope == nil || scope.Parent() == nil {
+			return
+		}
+		_, obj := scope.Parent().LookupParent(ident.Name, ident.Pos())
+		outer, ok := obj.(*types.Var)
+		if !ok || outer.Parent() == nil || outer.Parent() == types.Universe || outer.Parent().Parent() == types.Universe {
+			// Only local variables are considered.
+			return
+		}
+
+		kind := ""
+		switch {
+		case results[outer]:
+			kind = kindResult
+		case !types.Identical(v.Type(), outer.Type()):
+			kind = kindType
+		case isError(v.Type()) && usedAfter(uses[outer], scope.End()):
+			kind = kindErr
+		default:
+			return
+		}
+		p := fset.Position(ident.Pos())
+		s := &shadow{
+			Problem:  render.Problem{Filename: p.Filename, Line: p.Line, Column: p.Column},
+			Kind:     kind,
+			Name:     ident.Name,
+			Shadowed: fset.Position(outer.Pos()).String(),
+		}
+		qual := types.RelativeTo(info.Pkg)
+		switch kind {
+		case kindResult:
+			s.Message = fmt.Sprintf("declaration of %s shadows named result declared at line %d", ident.Name, fset.Position(outer.Pos()).Line)
+		case kindType:
+			s.Message = fmt.Sprintf("declaration of %s (%s) shadows %s of type %s declared at line %d", ident.Name,
+				types.TypeString(v.Type(), qual), ident.Name, types.TypeString(outer.Type(), qual), fset.Position(outer.Pos()).Line)
+		case kindErr:
+			s.Message = fmt.Sprintf("declaration of %s shadows error declared at line %d, which is used after this scope", ident.Name, fset.Position(outer.Pos()).Line)
+		}
+		shadows = append(shadows, s)
+	}
+
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				addResults(info, n.Type, results)
+			case *ast.FuncLit:
+				addResults(info, n.Type, results)
+			case *ast.AssignStmt:
+				if n.Tok != token.DEFINE {
+					return true
+				}
+				for i, lhs := range n.Lhs {
+					id, ok := lhs.(*ast.Ident)
+					if !ok {
+						continue
+					}
+					var rhs ast.Expr
+					if len(n.Rhs) == len(n.Lhs) {
+						rhs = n.Rhs[i]
+					}
+					check(id, rhs)
+				}
+			case *ast.ValueSpec:
+				for i, id := range n.Names {
+					var rhs ast.Expr
+					if len(n.Values) == len(n.Names) {
+						rhs = n.Values[i]
+					}
+					check(id, rhs)
+				}
+			}
+			return true
+		})
+	}
+	return shadows
+}
+
+// addResults records the named results of a function.
+func addResults(info *loader.PackageInfo, ft *ast.FuncType, results map[*types.Var]bool) {
+	if ft.Results == nil {
+		return
+	}
+	for _, field := range ft.Results.List {
+		for _, name := range field.Names {
+			if v, ok := info.Defs[name].(*types.Var); ok {
+				results[v] = true
+			}
+		}
+	}
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func isError(t types.Type) bool {
+	return types.Identical(t, errorType)
+}
+
+func usedAfter(uses []token.Pos, end token.Pos) bool {
+	for _, pos := range uses {
+		if pos > end {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package shadowvar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindShadows(t *testing.T) {
+	src := `package p
+
+import (
+	"errors"
+	"strconv"
+)
+
+func result(s string) (n int, err error) {
+	if s != "" {
+		n, err := strconv.Atoi(s)
+		_, _ = n, err
+	}
+	return
+}
+
+func typ(s string) {
+	if s != "" {
+		s := len(s)
+		_ = s
+	}
+}
+
+func lost() error {
+	err := errors.New("a")
+	if err != nil {
+		err := errors.New("b")
+		_ = err
+	}
+	return err
+}
+
+func unused() {
+	err := errors.New("a")
+	_ = err
+	{
+		err := errors.New("b")
+		_ = err
+	}
+}
+
+func copies(s string) (err error) {
+	go func() {
+		err := err
+		_ = err
+	}()
+	if s != "" {
+		var s = "t"
+		_ = s
+	}
+	return
+}
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range findShadows(prog.Fset, prog.Created[0]) {
+		got = append(got, fmt.Sprintf("%d:%d: %s: %s", s.Line, s.Column, s.Kind, s.Message))
+	}
+	want := []string{
+		"10:3: result: declaration of n shadows named result declared at line 8",
+		"10:6: result: declaration of err shadows named result declared at line 8",
+		"18:3: type: declaration of s (int) shadows s of type string declared at line 16",
+		"26:3: err: declaration of err shadows error declared at line 24, which is used after this scope",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected shadows:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,332 @@
+// Package stubgen implements gostubgen, which adds the methods a type is
+// missing to implement an interface.
+package stubgen
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gostubgen [flags] <interface> <type> <file>
+
+gostubgen adds stubs of the methods a type is missing to implement an
+interface to the end of a file of the package declaring the type. Each stub
+has the signature of the interface's method and a body which panics.
+
+	gostubgen 'io.ReadWriteCloser' MyType file.go
+
+The interface is written in the same form as gosearch expressions. Imports
+needed by the signatures are added to the file. Receivers are named and
+declared as pointers or values the same way as the type's existing methods,
+or as pointers if it has none.
+
+The command accepts the following flags:
+
+	-n	Dry run. Print the stubs instead of writing the file.
+
+	-a	Allow build errors, such as from methods already missing.
+`
+
+// Command runs gostubgen as the stubgen subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "stubgen",
+	Tool:    "gostubgen",
+	Summary: "add the methods a type is missing to implement an interface",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gostubgen with the provided arguments.
+func Run(args []string) {
+	dryRun := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gostubgen", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.BoolVar(&dryRun, "n", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) != 3 {
+		fatal(help)
+	}
+	target, err := resolve.Parse(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	if target.Kind != "" || target.Regexp != nil {
+		fatal("the interface must name an object")
+	}
+	typeName, filename := args[1], args[2]
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		fatal(err)
+	}
+	pkgs, err := load.GoList{}.List(filepath.Dir(abs))
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(target.Pkg, pkgs[0])
+	if err != nil {
+		fatal(err)
+	}
+
+	info := prog.Imported[pkgs[0]]
+	var file *ast.File
+	for _, f := range info.Files {
+		if prog.Fset.File(f.Pos()).Name() == abs {
+			file = f
+		}
+	}
+	if file == nil {
+		fatal(fmt.Sprintf("%s isn't part of package %s", filename, info.Pkg.Path()))
+	}
+
+	g, n, err := generate(prog, info, file, target, typeName)
+	if err != nil {
+		fatal(err)
+	}
+	if n == 0 {
+		fmt.Fprintf(os.Stderr, "%s already implements %s\n", typeName, args[0])
+		return
+	}
+	if dryRun {
+		os.Stdout.Write(g.buf.Bytes())
+		return
+	}
+	if err := g.write(prog.Fset, abs); err != nil {
+		fatal(err)
+	}
+}
+
+// generate writes stubs of the methods the type named typeName, declared in
+// the package, is missing to implement the interface the target names,
+// returning the generator holding them and the number written.
+func generate(prog *loader.Program, info *loader.PackageInfo, file *ast.File, target *resolve.Target, typeName string) (*generator, int, error) {
+	obj, err := target.Lookup(prog.Imported[target.Pkg])
+	if err != nil {
+		return nil, 0, err
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if _, isType := obj.(*types.TypeName); !isType || !ok {
+		return nil, 0, fmt.Errorf("%s isn't an interface", target)
+	}
+	named, ok := info.Pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok || types.IsInterface(named.Type()) {
+		return nil, 0, fmt.Errorf("no type %s which isn't an interface in package %s", typeName, info.Pkg.Path())
+	}
+	g := newGenerator(info.Pkg, file, named)
+	n, err := g.stubs(iface, obj.Pkg().Name()+"."+obj.Name())
+	return g, n, err
+}
+
+// generator writes method stubs for a type.
+type generator struct {
+	pkg   *types.Package
+	file  *ast.File
+	named *types.TypeName
+
+	recvName string
+	// recvType is the type of the receiver, such as *T or *G[E] for a
+	// generic type.
+	recvType string
+	pointer  bool
+	// imports maps the paths of packages imported by the file to the
+	// names they're imported by, and added holds paths which must be
+	// imported for the stubs.
+	imports map[string]string
+	added   map[string]bool
+
+	buf bytes.Buffer
+}
+
+func newGenerator(pkg *types.Package, file *ast.File, named *types.TypeName) *generator {
+	g := &generator{
+		pkg:     pkg,
+		file:    file,
+		named:   named,
+		pointer: true,
+		imports: make(map[string]string),
+		added:   make(map[string]bool),
+	}
+	r := []rune(named.Name())
+	g.recvName = string(unicode.ToLower(r[0]))
+	g.recvType = named.Name()
+	if t, ok := named.Type().(*types.Named); ok {
+		if t.NumMethods() > 0 {
+			recv := t.Method(0).Type().(*types.Signature).Recv()
+			_, g.pointer = recv.Type().(*types.Pointer)
+			if recv.Name() != "" {
+				g.recvName = recv.Name()
+			}
+		}
+		if tparams := t.TypeParams(); tparams.Len() > 0 {
+			var names []string
+			for i := 0; i < tparams.Len(); i++ {
+				names = append(names, tparams.At(i).Obj().Name())
+			}
+			g.recvType += "[" + strings.Join(names, ", ") + "]"
+		}
+	}
+	if g.pointer {
+		g.recvType = "*" + g.recvType
+	}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			g.imports[path] = spec.Name.Name
+		}
+	}
+	return g
+}
+
+// qualifier names packages as the file imports them, recording packages the
+// file must import.
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	if name, ok := g.imports[p.Path()]; ok {
+		return name
+	}
+	imported := false
+	for _, spec := range g.file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == p.Path() {
+			imported = true
+		}
+	}
+	if !imported {
+		g.added[p.Path()] = true
+	}
+	return p.Name()
+}
+
+// stubs writes a stub for each method of the interface the type is missing,
+// returning the number written. An error is returned if the type has a field
+// or method with the name of a method which can't implement it.
+func (g *generator) stubs(iface *types.Interface, ifaceName string) (int, error) {
+	// Methods are looked up on a pointer to the type, whose method set
+	// includes those declared with either receiver, so stubs aren't written
+	// for methods declared with the other one.
+	n := 0
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(g.named.Type()), false, g.pkg, m.Name())
+		if obj != nil {
+			f, ok := obj.(*types.Func)
+			if !ok || !types.Identical(f.Type(), sig) {
+				return 0, fmt.Errorf("%s.%s doesn't match the signature of %s.%s", g.named.Name(), m.Name(), ifaceName, m.Name())
+			}
+			if _, ptr := f.Type().(*types.Signature).Recv().Type().(*types.Pointer); ptr && !g.pointer {
+				name := g.named.Name()
+				return 0, fmt.Errorf("(*%s).%s has a pointer receiver, but stubs would have value receivers like %s's first method, so only *%s would implement %s: declare its methods with the same receiver", name, m.Name(), name, name, ifaceName)
+			}
+			continue
+		}
+		if !m.Exported() && m.Pkg() != g.pkg {
+			return 0, fmt.Errorf("%s has unexported method %s, which can't be implemented outside of package %s", ifaceName, m.Name(), m.Pkg().Path())
+		}
+
+		recvName := g.recvName
+		for _, t := range []*types.Tuple{sig.Params(), sig.Results()} {
+			for j := 0; j < t.Len(); j++ {
+				if t.At(j).Name() == recvName {
+					recvName = "_"
+				}
+			}
+		}
+		fmt.Fprintf(&g.buf, "\n// %s implements %s.\nfunc (%s %s) %s", m.Name(), ifaceName, recvName, g.recvType, m.Name())
+		types.WriteSignature(&g.buf, sig, g.qualifier)
+		fmt.Fprintf(&g.buf, " {\n\tpanic(\"TODO: implement %s\")\n}\n", m.Name())
+		n++
+	}
+	return n, nil
+}
+
+// write adds the stubs and any missing imports to the file.
+func (g *generator) write(fset *token.FileSet, filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for path := range g.added {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	offset := 0
+	if len(paths) > 0 {
+		// Add imports to the end of the first import block, or after the
+		// package clause if there isn't one.
+		var lines []string
+		for _, path := range paths {
+			lines = append(lines, strconv.Quote(path))
+		}
+		insert := "\n\nimport (\n" + strings.Join(lines, "\n") + "\n)"
+		if len(lines) == 1 {
+			insert = "\n\nimport " + lines[0]
+		}
+		offset = fset.Position(g.file.Name.End()).Offset
+		for _, decl := range g.file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.IMPORT {
+				continue
+			}
+			if gen.Rparen.IsValid() {
+				offset = fset.Position(gen.Rparen).Offset
+				insert = strings.Join(lines, "\n") + "\n"
+				if offset > 0 && data[offset-1] != '\n' {
+					insert = "\n" + insert
+				}
+			} else {
+				offset = fset.Position(gen.End()).Offset
+				insert = "\n" + "import " + strings.Join(lines, "\nimport ")
+			}
+			break
+		}
+		buf.Write(data[:offset])
+		buf.WriteString(insert)
+	}
+	buf.Write(data[offset:])
+	buf.Write(g.buf.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.New("formatting stubs: " + err.Error())
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, src, fi.Mode())
+}
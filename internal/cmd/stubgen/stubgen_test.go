@@ -0,0 +1,99 @@
+package stubgen
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// stub adds the stubs of the interface to a copy of the input file in
+// testdata, returning the file's new contents.
+func stub(t *testing.T, input, iface, typeName string) ([]byte, error) {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", input+".input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "gostubgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(filename, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := resolve.Parse(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := loader.Config{}
+	f, err := conf.ParseFile(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	conf.Import(target.Pkg)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _, err := generate(prog, prog.Created[0], f, target, typeName)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.write(prog.Fset, filename); err != nil {
+		t.Fatal(err)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name, input, iface, typeName string
+	}{
+		// Imports are added to the file's import block.
+		{"imports", "imports", "io/fs.File", "T"},
+		// Renamed imports are used by their names.
+		{"renamed", "imports", "io.ReaderFrom", "T"},
+		// Receivers are named and declared as the type's first method.
+		{"receivers", "receivers", "io.ReadWriteCloser", "V"},
+		{"generic", "generic", "io.Closer", "G"},
+	}
+	for _, tt := range tests {
+		got, err := stub(t, tt.input, tt.iface, tt.typeName)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		golden := filepath.Join("testdata", tt.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tt.name, want, got)
+		}
+	}
+}
+
+func TestPointerReceiverMismatch(t *testing.T) {
+	_, err := stub(t, "pointer", "io.ReadWriteCloser", "V")
+	want := "(*V).Read has a pointer receiver, but stubs would have value receivers like V's first method, so only *V would implement io.ReadWriteCloser: declare its methods with the same receiver"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
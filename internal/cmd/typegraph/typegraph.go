@@ -0,0 +1,374 @@
+// Package typegraph implements gotypegraph, which prints a graph of the named
+// types declared in a set of packages and the types they refer to.
+package typegraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+)
+
+var help = `usage: gotypegraph [flags] <list of packages>
+
+gotypegraph prints a graph of the named types declared in the provided
+packages. An edge from type A to type B means A refers to B through a
+field, an embedded field, a method signature, or its underlying type.
+
+Flags:
+
+	-format	Output format. One of "dot" (default), "graphml" or "json".
+
+	-external
+		Include types declared outside of the provided packages which are
+		referred to by types within them.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+`
+
+// Command runs gotypegraph as the typegraph subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "typegraph",
+	Tool:    "gotypegraph",
+	Summary: "graph the references between named types",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gotypegraph with the provided arguments.
+func Run(args []string) {
+	format := "dot"
+	external := false
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gotypegraph", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.StringVar(&format, "format", "dot", "")
+	fs.BoolVar(&external, "external", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	var write func(io.Writer, *graph) error
+	switch format {
+	case "dot":
+		write = writeDOT
+	case "graphml":
+		write = writeGraphML
+	case "json":
+		write = writeJSON
+	default:
+		fatal(fmt.Sprintf("unknown format %q", format), help)
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+
+	g := newGraph()
+	for _, pkg := range pkgs {
+		info := prog.Imported[pkg]
+		if len(info.Errors) != 0 {
+			continue
+		}
+		scope := info.Pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if named, ok := tn.Type().(*types.Named); ok {
+				g.addType(named)
+			}
+		}
+	}
+	if !external {
+		g.prune()
+	}
+	if err := write(os.Stdout, g); err != nil {
+		fatal(err)
+	}
+}
+
+// Kinds of references between types.
+const (
+	kindField      = "field"
+	kindEmbed      = "embed"
+	kindSignature  = "signature"
+	kindUnderlying = "underlying"
+)
+
+type node struct {
+	ID      string
+	Package string
+	Name    string
+
+	declared bool // declared in one of the provided packages
+}
+
+type edge struct {
+	From string
+	To   string
+	Kind string
+}
+
+type graph struct {
+	nodes map[string]*node
+	edges map[edge]bool
+}
+
+func newGraph() *graph {
+	return &graph{nodes: make(map[string]*node), edges: make(map[edge]bool)}
+}
+
+func nodeID(obj *types.TypeName) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+func (g *graph) node(obj *types.TypeName) *node {
+	id := nodeID(obj)
+	n, ok := g.nodes[id]
+	if !ok {
+		n = &node{ID: id, Name: obj.Name()}
+		if obj.Pkg() != nil {
+			n.Package = obj.Pkg().Path()
+		}
+		g.nodes[id] = n
+	}
+	return n
+}
+
+// addType adds a declared type and its references to the graph.
+func (g *graph) addType(named *types.Named) {
+	from := g.node(named.Obj())
+	from.declared = true
+
+	link := func(kind string) func(*types.Named) {
+		return func(to *types.Named) {
+			if to.Obj().Pkg() == nil {
+				// Predeclared types such as error.
+				return
+			}
+			g.edges[edge{from.ID, g.node(to.Obj()).ID, kind}] = true
+		}
+	}
+
+	switch u := named.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			kind := kindField
+			if f.Anonymous() {
+				kind = kindEmbed
+			}
+			walk(f.Type(), link(kind))
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			walk(u.EmbeddedType(i), link(kindEmbed))
+		}
+		for i := 0; i < u.NumExplicitMethods(); i++ {
+			walk(u.ExplicitMethod(i).Type(), link(kindSignature))
+		}
+	default:
+		walk(u, link(kindUnderlying))
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		sig := named.Method(i).Type().(*types.Signature)
+		walk(sig.Params(), link(kindSignature))
+		walk(sig.Results(), link(kindSignature))
+	}
+}
+
+// walk calls f for every named type referenced by t, without descending
+// into the named types themselves.
+func walk(t types.Type, f func(*types.Named)) {
+	switch t := types.Unalias(t).(type) {
+	case *types.Named:
+		f(t)
+		if args := t.TypeArgs(); args != nil {
+			for i := 0; i < args.Len(); i++ {
+				walk(args.At(i), f)
+			}
+		}
+	case *types.Pointer:
+		walk(t.Elem(), f)
+	case *types.Slice:
+		walk(t.Elem(), f)
+	case *types.Array:
+		walk(t.Elem(), f)
+	case *types.Chan:
+		walk(t.Elem(), f)
+	case *types.Map:
+		walk(t.Key(), f)
+		walk(t.Elem(), f)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			walk(t.At(i).Type(), f)
+		}
+	case *types.Signature:
+		walk(t.Params(), f)
+		walk(t.Results(), f)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			walk(t.Field(i).Type(), f)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			walk(t.Method(i).Type(), f)
+		}
+	}
+}
+
+// prune removes types which weren't declared in the provided packages.
+func (g *graph) prune() {
+	for e := range g.edges {
+		if !g.nodes[e.From].declared || !g.nodes[e.To].declared {
+			delete(g.edges, e)
+		}
+	}
+	for id, n := range g.nodes {
+		if !n.declared {
+			delete(g.nodes, id)
+		}
+	}
+}
+
+func (g *graph) sortedNodes() []*node {
+	nodes := make([]*node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+func (g *graph) sortedEdges() []edge {
+	edges := make([]edge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+	return edges
+}
+
+func writeDOT(w io.Writer, g *graph) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph types {\n")
+	for _, n := range g.sortedNodes() {
+		fmt.Fprintf(&buf, "\t%q;\n", n.ID)
+	}
+	for _, e := range g.sortedEdges() {
+		fmt.Fprintf(&buf, "\t%q -> %q [label=%q];\n", e.From, e.To, e.Kind)
+	}
+	buf.WriteString("}\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func writeJSON(w io.Writer, g *graph) error {
+	out := struct {
+		Nodes []*node
+		Edges []edge
+	}{g.sortedNodes(), g.sortedEdges()}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(out)
+}
+
+func writeGraphML(w io.Writer, g *graph) error {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type xmlNode struct {
+		ID   string `xml:"id,attr"`
+		Data []data `xml:"data"`
+	}
+	type xmlEdge struct {
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+		Data   []data `xml:"data"`
+	}
+	type key struct {
+		ID       string `xml:"id,attr"`
+		For      string `xml:"for,attr"`
+		AttrName string `xml:"attr.name,attr"`
+		AttrType string `xml:"attr.type,attr"`
+	}
+	type xmlGraph struct {
+		ID          string    `xml:"id,attr"`
+		EdgeDefault string    `xml:"edgedefault,attr"`
+		Nodes       []xmlNode `xml:"node"`
+		Edges       []xmlEdge `xml:"edge"`
+	}
+	type graphML struct {
+		XMLName xml.Name `xml:"graphml"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Keys    []key    `xml:"key"`
+		Graph   xmlGraph `xml:"graph"`
+	}
+
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []key{
+			{"package", "node", "package", "string"},
+			{"name", "node", "name", "string"},
+			{"kind", "edge", "kind", "string"},
+		},
+		Graph: xmlGraph{ID: "types", EdgeDefault: "directed"},
+	}
+	for _, n := range g.sortedNodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, xmlNode{
+			ID:   n.ID,
+			Data: []data{{"package", n.Package}, {"name", n.Name}},
+		})
+	}
+	for _, e := range g.sortedEdges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, xmlEdge{
+			Source: e.From,
+			Target: e.To,
+			Data:   []data{{"kind", e.Kind}},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,128 @@
+package typegraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// loadGraph type checks the source as package p and returns the graph of its
+// named types.
+func loadGraph(t *testing.T, src string) *graph {
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGraph()
+	scope := prog.Created[0].Pkg.Scope()
+	for _, name := range scope.Names() {
+		if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+			g.addType(tn.Type().(*types.Named))
+		}
+	}
+	return g
+}
+
+const src = `package p
+
+import "io"
+
+type Server struct {
+	Config
+	handlers map[string][]*Handler
+	log      io.Writer
+}
+
+type Config struct{ Addr Addr }
+
+type Addr string
+
+type Handler interface {
+	io.Closer
+	Serve(*Request) error
+}
+
+type Request struct{}
+
+type Handlers []Handler
+
+func (s *Server) Handle(r Request) {}
+`
+
+func TestGraph(t *testing.T) {
+	g := loadGraph(t, src)
+	got := g.sortedEdges()
+	want := []edge{
+		{"p.Config", "p.Addr", kindField},
+		{"p.Handler", "io.Closer", kindEmbed},
+		{"p.Handler", "p.Request", kindSignature},
+		{"p.Handlers", "p.Handler", kindUnderlying},
+		{"p.Server", "io.Writer", kindField},
+		{"p.Server", "p.Config", kindEmbed},
+		{"p.Server", "p.Handler", kindField},
+		{"p.Server", "p.Request", kindSignature},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected edges %v, got %v", want, got)
+	}
+
+	// Without -external, io's types and the edges to them are removed.
+	g.prune()
+	if _, ok := g.nodes["io.Writer"]; ok {
+		t.Errorf("external type io.Writer wasn't pruned")
+	}
+	if n := len(g.sortedEdges()); n != 6 {
+		t.Errorf("expected 6 edges after pruning, got %d", n)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := loadGraph(t, "package p\n\ntype A struct{ B *B }\n\ntype B []A\n")
+	var buf bytes.Buffer
+	if err := writeDOT(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph types {
+	"p.A";
+	"p.B";
+	"p.A" -> "p.B" [label="field"];
+	"p.B" -> "p.A" [label="underlying"];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := loadGraph(t, "package p\n\ntype A struct{ B *B }\n\ntype B []A\n")
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Nodes []node
+		Edges []edge
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	wantNodes := []node{{ID: "p.A", Package: "p", Name: "A"}, {ID: "p.B", Package: "p", Name: "B"}}
+	if !reflect.DeepEqual(got.Nodes, wantNodes) {
+		t.Errorf("expected nodes %v, got %v", wantNodes, got.Nodes)
+	}
+	wantEdges := []edge{{"p.A", "p.B", kindField}, {"p.B", "p.A", kindUnderlying}}
+	if !reflect.DeepEqual(got.Edges, wantEdges) {
+		t.Errorf("expected edges %v, got %v", wantEdges, got.Edges)
+	}
+}
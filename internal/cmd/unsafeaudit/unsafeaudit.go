@@ -0,0 +1,479 @@
+// Package unsafeaudit implements gounsafeaudit, which inventories the uses of
+// unsafe, cgo and reflection that bypass the type system and classifies each
+// by risk.
+package unsafeaudit
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/cli"
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+var help = `usage: gounsafeaudit [flags] <list of packages>
+
+gounsafeaudit lists the uses of unsafe, cgo and reflection in the provided
+packages which bypass the type system, classified by the pattern of use:
+
+	arithmetic	high	unsafe.Pointer computed from uintptr arithmetic,
+				or unsafe.Add.
+	uintptr		high	unsafe.Pointer converted to or from uintptr
+				outside of arithmetic.
+	cgo		high	A call to a C function.
+	reflect-unsafe	high	reflect.NewAt, Value.UnsafeAddr, UnsafePointer
+				and Pointer, and the SliceHeader and StringHeader
+				types.
+	cast		medium	A pointer converted to another pointer type
+				through unsafe.Pointer.
+	slice-string	medium	unsafe.Slice, String, SliceData and StringData.
+	finalizer	medium	runtime.SetFinalizer and AddCleanup.
+	pointer		medium	Any other use of unsafe.Pointer, such as a
+				variable of that type or a conversion passed to
+				a function.
+	reflect-field	low	Access to struct fields by reflection, such as
+				Value.FieldByName.
+	sizeof		low	unsafe.Sizeof, Alignof and Offsetof.
+
+Each use is printed with its position, risk, pattern and expression.
+
+Flags:
+
+	-risk level
+		Only report uses with at least this risk: low, medium or high.
+		Defaults to low.
+
+	-json	Print each use as a JSON object with Filename, Line, Column,
+		Message, Package, Kind, Risk and Expr fields.
+
+	-csv	Print a CSV report with a header row and the columns
+		filename, line, column, package, kind, risk and expr.
+
+	-t	Load and check *_test.go files.
+
+	-a	Allow errors when loading packages. Packages with errors will be omitted from results.
+
+gounsafeaudit exits with status 1 if any uses are reported.
+`
+
+// Command runs gounsafeaudit as the unsafe-audit subcommand of gotools.
+var Command = &cli.Command{
+	Name:    "unsafe-audit",
+	Tool:    "gounsafeaudit",
+	Summary: "inventory uses of unsafe, cgo and reflection by risk",
+	Help:    help,
+	Run:     Run,
+}
+
+func fatal(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
+
+// Run runs gounsafeaudit with the provided arguments.
+func Run(args []string) {
+	var (
+		jsonOutput bool
+		csvOutput  bool
+		minRisk    string
+	)
+	conf := load.Config{}
+	fs := flag.NewFlagSet("gounsafeaudit", flag.ExitOnError)
+	fs.Usage = func() {
+		fatal(help)
+	}
+	fs.StringVar(&minRisk, "risk", riskLow, "")
+	fs.BoolVar(&jsonOutput, "json", false, "")
+	fs.BoolVar(&csvOutput, "csv", false, "")
+	fs.BoolVar(&conf.Tests, "t", false, "")
+	fs.BoolVar(&conf.AllowErrors, "a", false, "")
+	fs.Parse(args)
+
+	if _, ok := riskLevels[minRisk]; !ok {
+		fatal("-risk must be low, medium or high")
+	}
+	if jsonOutput && csvOutput {
+		fatal("-json and -csv can't be used together")
+	}
+
+	pkgs, err := load.GoList{}.List(fs.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+	prog, err := conf.Load(pkgs...)
+	if err != nil {
+		fatal(err)
+	}
+	var searched []*loader.PackageInfo
+	for _, pkg := range pkgs {
+		searched = append(searched, prog.Imported[pkg])
+	}
+	if conf.Tests {
+		searched = append(searched, prog.Created...)
+	}
+
+	var diags []render.Diagnostic
+	for _, info := range searched {
+		if len(info.Errors) != 0 {
+			continue
+		}
+		for _, u := range findUses(prog.Fset, info) {
+			if riskLevels[u.Risk] >= riskLevels[minRisk] {
+				diags = append(diags, u)
+			}
+		}
+	}
+	if csvOutput {
+		err = printCSV(os.Stdout, diags)
+	} else {
+		err = render.PrintProblems(os.Stdout, jsonOutput, diags)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if len(diags) != 0 {
+		os.Exit(1)
+	}
+}
+
+// Risk levels.
+const (
+	riskLow    = "low"
+	riskMedium = "medium"
+	riskHigh   = "high"
+)
+
+var riskLevels = map[string]int{riskLow: 0, riskMedium: 1, riskHigh: 2}
+
+// Kinds of uses, and the risk of each.
+const (
+	kindArithmetic    = "arithmetic"
+	kindUintptr       = "uintptr"
+	kindCgo           = "cgo"
+	kindReflectUnsafe = "reflect-unsafe"
+	kindCast          = "cast"
+	kindSliceString   = "slice-string"
+	kindFinalizer     = "finalizer"
+	kindPointer       = "pointer"
+	kindReflectField  = "reflect-field"
+	kindSizeof        = "sizeof"
+)
+
+var kindRisks = map[string]string{
+	kindArithmetic:    riskHigh,
+	kindUintptr:       riskHigh,
+	kindCgo:           riskHigh,
+	kindReflectUnsafe: riskHigh,
+	kindCast:          riskMedium,
+	kindSliceString:   riskMedium,
+	kindFinalizer:     riskMedium,
+	kindPointer:       riskMedium,
+	kindReflectField:  riskLow,
+	kindSizeof:        riskLow,
+}
+
+// unsafeKinds classifies the functions of package unsafe.
+var unsafeKinds = map[string]string{
+	"Add":        kindArithmetic,
+	"Slice":      kindSliceString,
+	"String":     kindSliceString,
+	"SliceData":  kindSliceString,
+	"StringData": kindSliceString,
+	"Sizeof":     kindSizeof,
+	"Alignof":    kindSizeof,
+	"Offsetof":   kindSizeof,
+}
+
+// reflectKinds classifies the functions, methods and types of package
+// reflect.
+var reflectKinds = map[string]string{
+	"NewAt":           kindReflectUnsafe,
+	"UnsafeAddr":      kindReflectUnsafe,
+	"UnsafePointer":   kindReflectUnsafe,
+	"Pointer":         kindReflectUnsafe,
+	"SliceHeader":     kindReflectUnsafe,
+	"StringHeader":    kindReflectUnsafe,
+	"Field":           kindReflectField,
+	"FieldByIndex":    kindReflectField,
+	"FieldByIndexErr": kindReflectField,
+	"FieldByName":     kindReflectField,
+	"FieldByNameFunc": kindReflectField,
+}
+
+// runtimeKinds classifies the functions of package runtime.
+var runtimeKinds = map[string]string{
+	"SetFinalizer": kindFinalizer,
+	"AddCleanup":   kindFinalizer,
+}
+
+// use is a use of unsafe, cgo or reflection.
+type use struct {
+	render.Problem
+	Package string
+	Kind    string
+	Risk    string
+	// Expr is the source of the expression, such as
+	// "unsafe.Pointer(uintptr(p) + off)".
+	Expr string
+}
+
+// findUses classifies the uses within a package. Conversions which are part
+// of a larger pattern, such as the conversions to and from uintptr in
+// pointer arithmetic, are only reported as that pattern.
+func findUses(fset *token.FileSet, info *loader.PackageInfo) []*use {
+	var uses []*use
+	// handled holds the nodes already reported as part of another use.
+	handled := make(map[ast.Node]bool)
+	report := func(n ast.Expr, kind string) {
+		p := fset.Position(n.Pos())
+		if generated(p.Filename) {
+			return
+		}
+		expr := types.ExprString(n)
+		if kind == kindCgo {
+			// The arguments of calls rewritten by cgo refer to
+			// generated variables, so only name the function.
+			expr = cgoName(ast.Unparen(n.(*ast.CallExpr).Fun).(*ast.Ident).Name)
+		}
+		uses = append(uses, &use{
+			Problem: render.Problem{
+				Filename: p.Filename, Line: p.Line, Column: p.Column,
+				Message: kindRisks[kind] + " " + kind + ": " + expr,
+			},
+			Package: info.Pkg.Path(),
+			Kind:    kind,
+			Risk:    kindRisks[kind],
+			Expr:    expr,
+		})
+	}
+	unsafePointer := types.Unsafe.Scope().Lookup("Pointer")
+
+	for _, file := range info.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if handled[n] {
+				return true
+			}
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if len(n.Args) == 1 && isConversion(info, n) {
+					if kind := classifyConversion(info, n, handled); kind != "" {
+						report(n, kind)
+					}
+					return true
+				}
+				if ident, ok := ast.Unparen(n.Fun).(*ast.Ident); ok && cgoName(ident.Name) != "" {
+					report(n, kindCgo)
+					return true
+				}
+				if obj := calledObject(info, n.Fun); obj != nil {
+					if kind := classifyObject(obj); kind != "" {
+						handled[n.Fun] = true
+						if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+							handled[sel.Sel] = true
+						}
+						report(n, kind)
+					}
+				}
+			case *ast.SelectorExpr:
+				// The unsafe.Pointer type, method values, references to
+				// functions and reflect's header types.
+				obj := info.Uses[n.Sel]
+				if obj == nil || handled[n.Sel] {
+					return true
+				}
+				kind := classifyObject(obj)
+				if obj == unsafePointer {
+					kind = kindPointer
+				}
+				if kind != "" {
+					handled[n.Sel] = true
+					report(n, kind)
+				}
+			}
+			return true
+		})
+	}
+	return uses
+}
+
+// isConversion reports whether a call expression is a conversion.
+func isConversion(info *loader.PackageInfo, call *ast.CallExpr) bool {
+	tv, ok := info.Types[call.Fun]
+	return ok && tv.IsType()
+}
+
+// classifyConversion classifies conversions involving unsafe.Pointer or
+// uintptr, marking the conversions nested within the pattern as handled.
+// It returns an empty string for other conversions.
+func classifyConversion(info *loader.PackageInfo, conv *ast.CallExpr, handled map[ast.Node]bool) string {
+	to := info.TypeOf(conv)
+	arg := ast.Unparen(conv.Args[0])
+	from := info.TypeOf(arg)
+	// markFun marks a conversion and the type it converts to as handled,
+	// along with a conversion of its argument to or from unsafe.Pointer.
+	var markFun func(call *ast.CallExpr)
+	markFun = func(call *ast.CallExpr) {
+		handled[call] = true
+		ast.Inspect(call.Fun, func(n ast.Node) bool {
+			handled[n] = true
+			return true
+		})
+		inner, ok := ast.Unparen(call.Args[0]).(*ast.CallExpr)
+		if ok && len(inner.Args) == 1 && isConversion(info, inner) && (isUnsafePointer(info.TypeOf(inner)) || isUnsafePointer(info.TypeOf(inner.Args[0]))) {
+			markFun(inner)
+		}
+	}
+	switch {
+	case isUnsafePointer(to) && isUintptr(from):
+		if inner := arithmetic(info, arg); inner != nil {
+			for _, c := range inner {
+				markFun(c)
+			}
+			markFun(conv)
+			return kindArithmetic
+		}
+		markFun(conv)
+		return kindUintptr
+	case isUintptr(to) && isUnsafePointer(from):
+		markFun(conv)
+		return kindUintptr
+	case isPointer(to) && isUnsafePointer(from):
+		if inner, ok := arg.(*ast.CallExpr); ok && len(inner.Args) == 1 && isConversion(info, inner) {
+			if isUintptr(info.TypeOf(inner.Args[0])) {
+				// Reported as arithmetic or uintptr by the inner
+				// conversion.
+				return ""
+			}
+		}
+		markFun(conv)
+		return kindCast
+	case isUnsafePointer(to):
+		markFun(conv)
+		return kindPointer
+	}
+	return ""
+}
+
+// arithmetic returns the conversions of unsafe.Pointer to uintptr within a
+// uintptr expression computed with binary operators, or nil if the
+// expression isn't arithmetic.
+func arithmetic(info *loader.PackageInfo, e ast.Expr) []*ast.CallExpr {
+	bin, ok := e.(*ast.BinaryExpr)
+	if !ok {
+		return nil
+	}
+	convs := []*ast.CallExpr{}
+	ast.Inspect(bin, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if ok && len(call.Args) == 1 && isConversion(info, call) &&
+			isUintptr(info.TypeOf(call)) && isUnsafePointer(info.TypeOf(call.Args[0])) {
+			convs = append(convs, call)
+		}
+		return true
+	})
+	return convs
+}
+
+// calledObject returns the function or method called, or nil for calls of
+// function values.
+func calledObject(info *loader.PackageInfo, fun ast.Expr) types.Object {
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		return info.Uses[f]
+	case *ast.SelectorExpr:
+		return info.Uses[f.Sel]
+	}
+	return nil
+}
+
+// classifyObject classifies references to functions, methods and types of
+// packages unsafe, reflect and runtime, returning an empty string for other
+// objects.
+func classifyObject(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return ""
+	}
+	if types.Unsafe.Scope().Lookup(obj.Name()) == obj {
+		return unsafeKinds[obj.Name()]
+	}
+	switch obj.Pkg().Path() {
+	case "reflect":
+		switch obj.(type) {
+		case *types.Func, *types.TypeName:
+			return reflectKinds[obj.Name()]
+		}
+	case "runtime":
+		if _, ok := obj.(*types.Func); ok {
+			return runtimeKinds[obj.Name()]
+		}
+	}
+	return ""
+}
+
+// cgoName returns the name of the C function an identifier produced by cgo
+// refers to, such as "C.puts" for "_Cfunc_puts", or an empty string.
+func cgoName(name string) string {
+	for _, prefix := range []string{"_Cfunc_", "_C2func_"} {
+		if strings.HasPrefix(name, prefix) {
+			return "C." + strings.TrimPrefix(name, prefix)
+		}
+	}
+	return ""
+}
+
+// generated reports whether a position is within code cgo generates rather
+// than code mapped back to the package's source by line directives.
+func generated(filename string) bool {
+	return !strings.HasSuffix(filename, ".go") || filepath.Base(filename) == "_cgo_gotypes.go"
+}
+
+func isUnsafePointer(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := types.Unalias(t).(*types.Basic)
+	return ok && b.Kind() == types.UnsafePointer
+}
+
+func isUintptr(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uintptr
+}
+
+func isPointer(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+// printCSV prints the uses as a CSV report.
+func printCSV(w io.Writer, diags []render.Diagnostic) error {
+	render.SortProblems(diags)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"filename", "line", "column", "package", "kind", "risk", "expr"})
+	for _, d := range diags {
+		u := d.(*use)
+		cw.Write([]string{
+			u.Filename, strconv.Itoa(u.Line), strconv.Itoa(u.Column),
+			u.Package, u.Kind, u.Risk, u.Expr,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
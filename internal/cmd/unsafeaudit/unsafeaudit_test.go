@@ -0,0 +1,94 @@
+package unsafeaudit
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/render"
+	"golang.org/x/tools/go/loader"
+)
+
+func TestFindUses(t *testing.T) {
+	src := `package p
+
+import (
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+type T struct{ a, b int64 }
+
+// _Cfunc_puts stands in for a call rewritten by cgo.
+func _Cfunc_puts(s *byte) int32 { return 0 }
+
+func f(t *T, s []byte, str string) {
+	_ = unsafe.Pointer(uintptr(unsafe.Pointer(t)) + unsafe.Offsetof(t.b))
+	_ = unsafe.Add(unsafe.Pointer(t), 8)
+	_ = uintptr(unsafe.Pointer(t))
+	_Cfunc_puts(&s[0])
+	_ = reflect.ValueOf(t).Elem().UnsafeAddr()
+	_ = (*[2]int64)(unsafe.Pointer(t))
+	_ = unsafe.String(unsafe.SliceData(s), len(s))
+	runtime.SetFinalizer(t, nil)
+	var p unsafe.Pointer
+	_ = p
+	_ = reflect.ValueOf(*t).FieldByName("a")
+	_ = unsafe.Sizeof(*t)
+}
+`
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uses := findUses(prog.Fset, prog.Created[0])
+
+	var got []string
+	for _, u := range uses {
+		got = append(got, fmt.Sprintf("%d:%d: %s %s: %s", u.Line, u.Column, u.Risk, u.Kind, u.Expr))
+	}
+	want := []string{
+		"15:6: high arithmetic: unsafe.Pointer(uintptr(unsafe.Pointer(t)) + unsafe.Offsetof(t.b))",
+		"15:50: low sizeof: unsafe.Offsetof(t.b)",
+		"16:6: high arithmetic: unsafe.Add(unsafe.Pointer(t), 8)",
+		"16:17: medium pointer: unsafe.Pointer(t)",
+		"17:6: high uintptr: uintptr(unsafe.Pointer(t))",
+		"18:2: high cgo: C.puts",
+		"19:6: high reflect-unsafe: reflect.ValueOf(t).Elem().UnsafeAddr()",
+		"20:6: medium cast: (*[2]int64)(unsafe.Pointer(t))",
+		"21:6: medium slice-string: unsafe.String(unsafe.SliceData(s), len(s))",
+		"21:20: medium slice-string: unsafe.SliceData(s)",
+		"22:2: medium finalizer: runtime.SetFinalizer(t, nil)",
+		"23:8: medium pointer: unsafe.Pointer",
+		"25:6: low reflect-field: reflect.ValueOf(*t).FieldByName(\"a\")",
+		"26:6: low sizeof: unsafe.Sizeof(*t)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected uses:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	var diags []render.Diagnostic
+	for _, u := range uses[:2] {
+		diags = append(diags, u)
+	}
+	var buf bytes.Buffer
+	if err := printCSV(&buf, diags); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := `filename,line,column,package,kind,risk,expr
+p.go,15,6,p,arithmetic,high,unsafe.Pointer(uintptr(unsafe.Pointer(t)) + unsafe.Offsetof(t.b))
+p.go,15,50,p,sizeof,low,unsafe.Offsetof(t.b)
+`
+	if buf.String() != wantCSV {
+		t.Errorf("expected CSV:\n%s\ngot:\n%s", wantCSV, buf.String())
+	}
+}
@@ -0,0 +1,341 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DriverPath returns the path to an external go/packages driver, or an empty
+// string if the go tool should be used instead. This mirrors the lookup
+// done by golang.org/x/tools/go/packages.
+func DriverPath() string {
+	path := os.Getenv("GOPACKAGESDRIVER")
+	switch path {
+	case "off":
+		return ""
+	case "":
+		path, _ = exec.LookPath("gopackagesdriver")
+	}
+	return path
+}
+
+// driverRequest and driverResponse are the JSON messages exchanged with a
+// go/packages driver over stdin and stdout.
+type driverRequest struct {
+	Mode       int               `json:"mode"`
+	Env        []string          `json:"env"`
+	BuildFlags []string          `json:"build_flags"`
+	Tests      bool              `json:"tests"`
+	Overlay    map[string][]byte `json:"overlay"`
+}
+
+type driverResponse struct {
+	NotHandled bool
+	Roots      []string `json:",omitempty"`
+	Packages   []*driverPackage
+}
+
+type driverPackage struct {
+	ID              string
+	Name            string
+	PkgPath         string
+	Errors          []struct{ Msg string }
+	GoFiles         []string
+	CompiledGoFiles []string
+	Imports         map[string]string
+}
+
+// The subset of go/packages.LoadMode bits the loader requires: NeedName,
+// NeedFiles, NeedCompiledGoFiles, NeedImports and NeedDeps.
+const driverMode = 1 | 2 | 4 | 8 | 16
+
+// Driver holds the package metadata reported by a go/packages driver, or by
+// 'go list', and resolves imports for the loader from it instead of
+// go/build, which can't find packages of other modules.
+type Driver struct {
+	path  string
+	tests bool
+	tags  []string
+	// goos and goarch, if set, select files for that platform when
+	// listing packages with the go tool.
+	goos, goarch string
+	// query returns the metadata of the packages matching the patterns
+	// and their dependencies.
+	query func(patterns []string) (*driverResponse, error)
+
+	// pkgs holds the non-test variant of each package by package path.
+	pkgs map[string]*build.Package
+	// errs holds the errors of packages which couldn't be listed, such as
+	// missing packages, by package path.
+	errs map[string]string
+	// aliases maps import paths as written in source to package paths
+	// when the two differ, such as for vendored packages.
+	aliases map[string]string
+}
+
+// NewDriver returns a Driver which invokes the driver binary at path. If
+// tests is true, test files are included. Files are selected using the build
+// tags.
+func NewDriver(path string, tests bool, tags []string) *Driver {
+	d := &Driver{
+		path:    path,
+		tests:   tests,
+		tags:    tags,
+		pkgs:    make(map[string]*build.Package),
+		errs:    make(map[string]string),
+		aliases: make(map[string]string),
+	}
+	d.query = d.runDriver
+	return d
+}
+
+// NewGoListDriver returns a Driver which reads package metadata from
+// 'go list -json -deps', so imports are resolved by the go tool through the
+// main modules, a go.work workspace and the module cache. Other arguments
+// are as for NewDriver, and goos and goarch, if set, select the platform.
+func NewGoListDriver(tests bool, tags []string, goos, goarch string) *Driver {
+	d := NewDriver("go list", tests, tags)
+	d.goos, d.goarch = goos, goarch
+	d.query = d.goList
+	return d
+}
+
+// List invokes the driver with the provided patterns, records the metadata
+// of every returned package, and returns the package paths of the roots.
+func (d *Driver) List(patterns ...string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	resp, err := d.query(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*driverPackage, len(resp.Packages))
+	for _, p := range resp.Packages {
+		byID[p.ID] = p
+	}
+	var variants []*driverPackage
+	for _, p := range resp.Packages {
+		if isTestVariant(p) {
+			variants = append(variants, p)
+			continue
+		}
+		if strings.HasSuffix(p.ID, ".test") {
+			// Generated test main package.
+			continue
+		}
+		if len(p.Errors) != 0 && len(goFiles(p)) == 0 {
+			d.errs[p.PkgPath] = p.Errors[0].Msg
+			continue
+		}
+		d.pkgs[p.PkgPath] = buildPackage(p)
+		for path, id := range p.Imports {
+			if dep, ok := byID[id]; ok && dep.PkgPath != path {
+				d.aliases[path] = dep.PkgPath
+			}
+		}
+	}
+	for _, p := range variants {
+		d.addTestFiles(p)
+	}
+
+	var roots []string
+	for _, id := range resp.Roots {
+		p, ok := byID[id]
+		if !ok || isTestVariant(p) || strings.HasSuffix(id, ".test") {
+			continue
+		}
+		roots = append(roots, p.PkgPath)
+	}
+	if len(roots) == 0 {
+		return nil, errors.New(d.path + " returned no packages for " + strings.Join(patterns, " "))
+	}
+	return roots, nil
+}
+
+// runDriver invokes the driver binary with the patterns.
+func (d *Driver) runDriver(patterns []string) (*driverResponse, error) {
+	req, err := json.Marshal(&driverRequest{
+		Mode:       driverMode,
+		Env:        os.Environ(),
+		Tests:      d.tests,
+		BuildFlags: tagsFlag(d.tags),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(d.path, patterns...)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", d.path, err, stderr.String())
+	}
+	var resp driverResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%s: decoding response: %v", d.path, err)
+	}
+	if resp.NotHandled {
+		return nil, fmt.Errorf("%s: driver did not handle request", d.path)
+	}
+	return &resp, nil
+}
+
+// goList lists the packages matching the patterns and their dependencies
+// with the go tool, converting its output to a driver's response. Test
+// variants are named as by go/packages, such as "foo [foo.test]", and
+// listed packages are the roots.
+func (d *Driver) goList(patterns []string) (*driverResponse, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, errors.New("could not find the go tool in PATH")
+	}
+	args := append([]string{"list", "-e", "-json", "-compiled", "-deps"}, tagsFlag(d.tags)...)
+	if d.tests {
+		args = append(args, "-test")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", append(args, patterns...)...)
+	cmd.Env = platformEnv(d.goos, d.goarch)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New(stderr.String())
+	}
+	resp := new(driverResponse)
+	dec := json.NewDecoder(&stdout)
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: decoding output: %v", d.path, err)
+		}
+		dp := &driverPackage{
+			ID:              p.ImportPath,
+			Name:            p.Name,
+			PkgPath:         p.ImportPath,
+			GoFiles:         absFiles(p.Dir, p.GoFiles),
+			CompiledGoFiles: absFiles(p.Dir, p.CompiledGoFiles),
+			Imports:         make(map[string]string, len(p.Imports)),
+		}
+		if i := strings.Index(dp.PkgPath, " ["); i >= 0 {
+			dp.PkgPath = dp.PkgPath[:i]
+		}
+		if p.Error != nil {
+			dp.Errors = append(dp.Errors, struct{ Msg string }{p.Error.Err})
+		}
+		for _, path := range p.Imports {
+			dp.Imports[path] = path
+		}
+		for path, id := range p.ImportMap {
+			dp.Imports[path] = id
+		}
+		resp.Packages = append(resp.Packages, dp)
+		if !p.DepOnly {
+			resp.Roots = append(resp.Roots, dp.ID)
+		}
+	}
+	return resp, nil
+}
+
+// absFiles joins the names of files relative to the package directory to it.
+func absFiles(dir string, names []string) []string {
+	var files []string
+	for _, name := range names {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// isTestVariant reports if the package is a package recompiled for its tests,
+// such as "foo [foo.test]" or "foo_test [foo.test]".
+func isTestVariant(p *driverPackage) bool {
+	return strings.HasSuffix(p.ID, ".test]")
+}
+
+// addTestFiles records the files of a test variant as the test files of the
+// package it was compiled for.
+func (d *Driver) addTestFiles(p *driverPackage) {
+	path := strings.TrimSuffix(p.PkgPath, "_test")
+	bp, ok := d.pkgs[path]
+	if !ok {
+		return
+	}
+	if path != p.PkgPath {
+		bp.XTestGoFiles = goFiles(p)
+		return
+	}
+	seen := make(map[string]bool, len(bp.GoFiles))
+	for _, f := range bp.GoFiles {
+		seen[f] = true
+	}
+	for _, f := range goFiles(p) {
+		if !seen[f] {
+			bp.TestGoFiles = append(bp.TestGoFiles, f)
+		}
+	}
+}
+
+// buildPackage converts driver metadata to the form the loader expects.
+func buildPackage(p *driverPackage) *build.Package {
+	bp := &build.Package{
+		ImportPath: p.PkgPath,
+		Name:       p.Name,
+		GoFiles:    goFiles(p),
+	}
+	if len(bp.GoFiles) > 0 {
+		bp.Dir = filepath.Dir(bp.GoFiles[0])
+	}
+	for path := range p.Imports {
+		bp.Imports = append(bp.Imports, path)
+	}
+	return bp
+}
+
+// goFiles returns the files which should be type checked. Compiled files
+// are preferred since they include the output of cgo and other generators.
+func goFiles(p *driverPackage) []string {
+	if len(p.CompiledGoFiles) > 0 {
+		return p.CompiledGoFiles
+	}
+	return p.GoFiles
+}
+
+// FindPackage implements loader.Config.FindPackage. Despite the parameter
+// names of that field, the loader passes the import path before the
+// directory, matching (*build.Context).Import.
+func (d *Driver) FindPackage(ctxt *build.Context, importPath, fromDir string, mode build.ImportMode) (*build.Package, error) {
+	if importPath == "unsafe" {
+		return &build.Package{ImportPath: "unsafe", Name: "unsafe"}, nil
+	}
+	if path, ok := d.aliases[importPath]; ok {
+		importPath = path
+	}
+	bp, ok := d.pkgs[importPath]
+	if !ok {
+		if msg, ok := d.errs[importPath]; ok {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("package %q not reported by %s", importPath, d.path)
+	}
+	return bp, nil
+}
+
+// Has reports if the driver has reported the package.
+func (d *Driver) Has(pkg string) bool {
+	_, ok := d.pkgs[pkg]
+	return ok
+}
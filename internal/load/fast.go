@@ -0,0 +1,194 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// listedPackage is the subset of 'go list -json' output used by LoadFast and
+// the go list Driver.
+type listedPackage struct {
+	ImportPath      string
+	Name            string
+	Dir             string
+	Export          string
+	DepOnly         bool
+	GoFiles         []string
+	CompiledGoFiles []string
+	Imports         []string
+	ImportMap       map[string]string
+	Error           *struct{ Err string }
+}
+
+// LoadFast loads the source of the searched packages, type checking them
+// against the compiled export data of their dependencies instead of loading
+// the dependencies from source, as built by 'go list -export'. The other
+// packages are only loaded from export data, so they have no files and only
+// their Pkg is set. Files are parsed with comments if c.Comments is set, and
+// c.AllowErrors is respected. Other fields of c are ignored.
+//
+// Since dependencies are compiled by the go tool, which caches its results,
+// this is much faster than loading them from source when they're large,
+// though only their exported API is available.
+func (c *Config) LoadFast(pkgs []string, searched []string) (*loader.Program, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, errors.New("could not find the go tool in PATH")
+	}
+	args := append([]string{"list", "-e", "-export", "-compiled", "-deps", "-json"}, tagsFlag(c.Tags)...)
+	args = append(args, pkgs...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Env = platformEnv(c.GOOS, c.GOARCH)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New(stderr.String())
+	}
+	listed := make(map[string]*listedPackage)
+	dec := json.NewDecoder(&stdout)
+	for {
+		p := new(listedPackage)
+		if err := dec.Decode(p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		listed[p.ImportPath] = p
+	}
+
+	fset := token.NewFileSet()
+	prog := &loader.Program{
+		Fset:        fset,
+		Imported:    make(map[string]*loader.PackageInfo),
+		AllPackages: make(map[*types.Package]*loader.PackageInfo),
+	}
+	exports := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		p, ok := listed[path]
+		if !ok || p.Export == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(p.Export)
+	})
+	isSearched := make(map[string]bool, len(searched))
+	for _, path := range searched {
+		isSearched[path] = true
+	}
+
+	// Searched packages are checked from source, in dependency order, so
+	// packages importing each other share objects.
+	checked := make(map[string]*loader.PackageInfo)
+	var load func(path string) (*types.Package, error)
+	check := func(p *listedPackage) (*loader.PackageInfo, error) {
+		if p.Error != nil && !c.AllowErrors {
+			return nil, errors.New(p.Error.Err)
+		}
+		info := &loader.PackageInfo{
+			Importable: true,
+			Info: types.Info{
+				Types:      make(map[ast.Expr]types.TypeAndValue),
+				Defs:       make(map[*ast.Ident]types.Object),
+				Uses:       make(map[*ast.Ident]types.Object),
+				Implicits:  make(map[ast.Node]types.Object),
+				Scopes:     make(map[ast.Node]*types.Scope),
+				Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			},
+		}
+		mode := parser.Mode(0)
+		if c.Comments {
+			mode = parser.ParseComments
+		}
+		for _, name := range p.CompiledGoFiles {
+			if !strings.HasPrefix(name, "/") {
+				name = p.Dir + string(os.PathSeparator) + name
+			}
+			var src interface{}
+			if c.Snapshot != nil {
+				data, err := c.Snapshot.ReadFile(name)
+				if err != nil {
+					info.Errors = append(info.Errors, err)
+					continue
+				}
+				src = data
+			}
+			f, err := parser.ParseFile(fset, name, src, mode)
+			if err != nil {
+				info.Errors = append(info.Errors, err)
+				if f == nil {
+					continue
+				}
+			}
+			info.Files = append(info.Files, f)
+		}
+		conf := types.Config{
+			Importer: importerFunc(func(path string) (*types.Package, error) {
+				if mapped, ok := p.ImportMap[path]; ok {
+					path = mapped
+				}
+				return load(path)
+			}),
+			Error: func(err error) { info.Errors = append(info.Errors, err) },
+		}
+		if c.GOARCH != "" {
+			conf.Sizes = types.SizesFor("gc", c.GOARCH)
+		}
+		info.Pkg, _ = conf.Check(p.ImportPath, fset, info.Files, &info.Info)
+		if len(info.Errors) != 0 && !c.AllowErrors {
+			return nil, info.Errors[0]
+		}
+		info.TransitivelyErrorFree = len(info.Errors) == 0
+		return info, nil
+	}
+	load = func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		if !isSearched[path] {
+			return exports.Import(path)
+		}
+		if info, ok := checked[path]; ok {
+			return info.Pkg, nil
+		}
+		p, ok := listed[path]
+		if !ok {
+			return nil, fmt.Errorf("package %s wasn't listed", path)
+		}
+		info, err := check(p)
+		if err != nil {
+			return nil, err
+		}
+		checked[path] = info
+		prog.AllPackages[info.Pkg] = info
+		return info.Pkg, nil
+	}
+
+	for _, path := range pkgs {
+		pkg, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		info, ok := checked[path]
+		if !ok {
+			info = &loader.PackageInfo{Pkg: pkg, Importable: true, TransitivelyErrorFree: true}
+			prog.AllPackages[pkg] = info
+		}
+		prog.Imported[path] = info
+	}
+	return prog, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -0,0 +1,85 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrTooManyFiles is returned when the process runs out of file descriptors
+// even after waiting for other files to be closed.
+var ErrTooManyFiles = errors.New("too many open files; raise the limit with 'ulimit -n' and try again")
+
+// OpenFile opens a file for reading. If the process is out of file
+// descriptors it waits for others to be closed before giving up.
+func OpenFile(name string) (*os.File, error) {
+	backoff := 10 * time.Millisecond
+	for i := 0; ; i++ {
+		f, err := os.Open(name)
+		if err == nil || !errors.Is(err, syscall.EMFILE) {
+			return f, err
+		}
+		if i == 6 {
+			return nil, fmt.Errorf("%s: %v", name, ErrTooManyFiles)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// BuildContext returns the build context used by the loader. Files are
+// opened with OpenFile so large programs degrade gracefully when they
+// approach the open file limit.
+func BuildContext() *build.Context {
+	ctxt := build.Default
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		return OpenFile(path)
+	}
+	return &ctxt
+}
+
+// Snapshot holds the contents of the files read while loading a program, so
+// results can be printed from the source which was analyzed even if files
+// change on disk afterwards, such as by an editor's save hook. The zero value
+// is empty and ready to use.
+type Snapshot struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// ReadFile returns the contents of a file as first read through the snapshot,
+// reading it with OpenFile if it hasn't been.
+func (s *Snapshot) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	data, ok := s.files[name]
+	s.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	f, err := OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err = ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.files[name]; ok {
+		// Read concurrently, keep the first.
+		return prev, nil
+	}
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	s.files[name] = data
+	return data, nil
+}
@@ -0,0 +1,294 @@
+// Package load lists packages and loads them from source with type
+// information.
+package load
+
+import (
+	"bytes"
+	"errors"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Lister expands package patterns into package paths.
+type Lister interface {
+	List(patterns ...string) ([]string, error)
+}
+
+// GoList lists packages with the go tool.
+type GoList struct {
+	// Tags are additional build tags, such as "integration", used to
+	// select files.
+	Tags []string
+	// GOOS and GOARCH, if set, select files for that platform instead
+	// of the host's.
+	GOOS, GOARCH string
+}
+
+// List passes the provided arguments into the 'go list' command returning a
+// list of packages.
+func (l GoList) List(args ...string) ([]string, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, errors.New("could not find the go tool in PATH")
+	}
+	args = append(append([]string{"list"}, tagsFlag(l.Tags)...), args...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Env = platformEnv(l.GOOS, l.GOARCH)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New(stderr.String())
+	}
+	return strings.Split(string(bytes.TrimSpace(stdout.Bytes())), "\n"), nil
+}
+
+// Deps lists the dependencies of the packages outside of the standard
+// library, such as vendored packages or those in the module cache, excluding
+// the packages themselves.
+func (l GoList) Deps(pkgs ...string) ([]string, error) {
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	deps, err := l.List(append([]string{"-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}"}, pkgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		listed[pkg] = true
+	}
+	var filtered []string
+	for _, dep := range deps {
+		if dep != "" && !listed[dep] {
+			filtered = append(filtered, dep)
+		}
+	}
+	return filtered, nil
+}
+
+// SplitPatterns separates package patterns prefixed by "-", which exclude
+// packages, from the others. The prefix is removed from the excluded patterns.
+func SplitPatterns(patterns []string) (include, exclude []string) {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "-") {
+			exclude = append(exclude, strings.TrimPrefix(p, "-"))
+			continue
+		}
+		include = append(include, p)
+	}
+	return include, exclude
+}
+
+// ListExcluding lists the packages matching the patterns, except for those
+// matching any pattern prefixed by "-", such as "./... -./vendor/...".
+// Excluded patterns are expanded by the lister, so they may match packages
+// the same way as any other pattern. Without any patterns, the package in the
+// current directory is listed, as go list does.
+func ListExcluding(l Lister, patterns ...string) ([]string, error) {
+	include, exclude := SplitPatterns(patterns)
+	if len(include) == 0 {
+		if len(exclude) != 0 {
+			return nil, errors.New("no packages to search besides excluded packages")
+		}
+		include = []string{"."}
+	}
+	pkgs, err := l.List(include...)
+	if err != nil || len(exclude) == 0 {
+		return pkgs, err
+	}
+	excluded, err := l.List(exclude...)
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, pkg := range excluded {
+		skip[pkg] = true
+	}
+	var filtered []string
+	for _, pkg := range pkgs {
+		if !skip[pkg] {
+			filtered = append(filtered, pkg)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.New("every package was excluded")
+	}
+	return filtered, nil
+}
+
+// Config controls how packages are loaded.
+type Config struct {
+	// AllowErrors loads packages even if they fail to type check.
+	AllowErrors bool
+	// Tests includes *_test.go files and external test packages.
+	Tests bool
+	// Driver, if non-nil, locates packages instead of the go tool. If nil
+	// and the go tool is in module mode, a driver listing the packages
+	// with 'go list' is used, as go/build can't find packages of other
+	// modules.
+	Driver *Driver
+	// Bodies, if non-nil, reports if the function bodies of a package
+	// should be type checked. Other packages only have their package level
+	// declarations checked, so their Uses and Types omit function bodies.
+	Bodies func(path string) bool
+	// Comments parses comments, such as doc comments and directives.
+	Comments bool
+	// Snapshot, if non-nil, records the contents of the files read, and
+	// the files are parsed from it.
+	Snapshot *Snapshot
+	// Tags are additional build tags, such as "integration", used to
+	// select files.
+	Tags []string
+	// GOOS and GOARCH, if set, select files for that platform instead
+	// of the host's.
+	GOOS, GOARCH string
+}
+
+// Load parses and type checks the packages and all of their dependencies.
+func (c *Config) Load(pkgs ...string) (*loader.Program, error) {
+	config := loader.Config{AllowErrors: c.AllowErrors, Build: BuildContext(), TypeCheckFuncBodies: c.Bodies}
+	if len(c.Tags) != 0 {
+		config.Build.BuildTags = append(append([]string(nil), config.Build.BuildTags...), c.Tags...)
+	}
+	if c.GOOS != "" || c.GOARCH != "" {
+		if err := setPlatform(config.Build, c.GOOS, c.GOARCH); err != nil {
+			return nil, err
+		}
+		config.TypeChecker.Sizes = types.SizesFor("gc", config.Build.GOARCH)
+	}
+	if c.Comments {
+		config.ParserMode = parser.ParseComments
+	}
+	if c.AllowErrors {
+		config.TypeChecker.Error = func(error) {}
+	}
+	driver := c.Driver
+	if driver == nil && len(pkgs) != 0 {
+		modules, err := moduleMode(c.GOOS, c.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+		if modules {
+			driver = NewGoListDriver(c.Tests, c.Tags, c.GOOS, c.GOARCH)
+			if _, err := driver.List(pkgs...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if driver != nil {
+		config.FindPackage = driver.FindPackage
+	}
+	if c.Snapshot != nil {
+		config.Build.OpenFile = func(path string) (io.ReadCloser, error) {
+			data, err := c.Snapshot.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	importPkg := config.Import
+	if c.Tests {
+		importPkg = config.ImportWithTests
+	}
+	for _, pkg := range pkgs {
+		importPkg(pkg)
+	}
+	return config.Load()
+}
+
+// moduleMode reports if the go tool resolves imports through modules, that is
+// if the current directory is within a module or a go.work workspace, whose
+// root may not be a module.
+func moduleMode(goos, goarch string) (bool, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return false, errors.New("could not find the go tool in PATH")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "env", "GOMOD", "GOWORK")
+	cmd.Env = platformEnv(goos, goarch)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return false, errors.New(stderr.String())
+	}
+	for _, file := range strings.Split(stdout.String(), "\n") {
+		if file = strings.TrimSpace(file); file != "" && file != os.DevNull && file != "off" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPlatform sets the platform of the build context, disabling cgo if it
+// isn't the host's as the go tool does. The tool tags, such as enabled
+// experiments, differ by architecture, so are read from the go tool.
+func setPlatform(ctxt *build.Context, goos, goarch string) error {
+	if goos != "" {
+		ctxt.GOOS = goos
+	}
+	if goarch != "" {
+		ctxt.GOARCH = goarch
+	}
+	if ctxt.GOOS != runtime.GOOS || ctxt.GOARCH != runtime.GOARCH {
+		ctxt.CgoEnabled = false
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-f", "{{join context.ToolTags \" \"}}", "unsafe")
+	cmd.Env = platformEnv(goos, goarch)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New(stderr.String())
+	}
+	ctxt.ToolTags = strings.Fields(stdout.String())
+	return nil
+}
+
+// platformEnv returns the environment of the go tool for the platform, or nil
+// to inherit the current environment if goos and goarch aren't set.
+func platformEnv(goos, goarch string) []string {
+	if goos == "" && goarch == "" {
+		return nil
+	}
+	env := os.Environ()
+	if goos != "" {
+		env = append(env, "GOOS="+goos)
+	}
+	if goarch != "" {
+		env = append(env, "GOARCH="+goarch)
+	}
+	return env
+}
+
+// tagsFlag returns the go tool's flag selecting files by the build tags, if
+// there are any.
+func tagsFlag(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return []string{"-tags", strings.Join(tags, ",")}
+}
+
+// Unvendor returns the import path of a vendored package.
+func Unvendor(path string) string {
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
+
+// IsStdlib reports if an import path belongs to the standard library.
+func IsStdlib(path string) bool {
+	p, err := BuildContext().Import(path, "", build.FindOnly)
+	return err == nil && p.Goroot
+}
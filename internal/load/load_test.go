@@ -0,0 +1,284 @@
+package load
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnvendor(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"github.com/acme/lib", "github.com/acme/lib"},
+		{"github.com/acme/app/vendor/golang.org/x/sys/unix", "golang.org/x/sys/unix"},
+		{"vendor/golang.org/x/net/http2/hpack", "golang.org/x/net/http2/hpack"},
+	}
+	for _, tt := range tests {
+		if got := Unvendor(tt.path); got != tt.want {
+			t.Errorf("Unvendor(%q): expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+// listFunc lists packages by calling itself.
+type listFunc func(patterns ...string) ([]string, error)
+
+func (f listFunc) List(patterns ...string) ([]string, error) { return f(patterns...) }
+
+func TestListExcluding(t *testing.T) {
+	pkgs := map[string][]string{
+		"./...":        {"a", "a/gen", "a/vendor/b", "a/c"},
+		"./vendor/...": {"a/vendor/b"},
+		"./gen/...":    {"a/gen"},
+	}
+	l := listFunc(func(patterns ...string) ([]string, error) {
+		var listed []string
+		for _, p := range patterns {
+			listed = append(listed, pkgs[p]...)
+		}
+		return listed, nil
+	})
+	got, err := ListExcluding(l, "./...", "-./vendor/...", "-./gen/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "a/c"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, err := ListExcluding(l, "-./gen/..."); err == nil {
+		t.Errorf("expected error listing only excluded packages")
+	}
+
+	// No patterns list the current directory, as go list does.
+	l = listFunc(func(patterns ...string) ([]string, error) {
+		if strings.Join(patterns, " ") != "." {
+			t.Errorf("expected the current directory, got %q", patterns)
+		}
+		return []string{"a"}, nil
+	})
+	got, err = ListExcluding(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != "a" {
+		t.Errorf("expected [a] without patterns, got %q", got)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(name, []byte("package p\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var s Snapshot
+	if _, err := s.ReadFile(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte("package q\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "package p\n" {
+		t.Errorf("expected the contents first read, got %q", data)
+	}
+}
+
+// writeFiles writes the files, named by slash separated paths relative to
+// dir, creating their directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// chdir changes the current directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+}
+
+// moduleEnv runs the go tool in module mode without network access.
+func moduleEnv(t *testing.T) {
+	t.Setenv("GO111MODULE", "on")
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "off")
+}
+
+func TestLoadModule(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not in PATH")
+	}
+	dir, err := ioutil.TempDir("", "load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":               "module example.com/app\n\ngo 1.21\n",
+		"main.go":              "package main\n\nimport \"example.com/app/internal/x\"\n\nfunc main() { x.F() }\n",
+		"internal/x/x.go":      "package x\n\nfunc F() {}\n",
+		"internal/x/x_test.go": "package x\n\nfunc g() { F() }\n",
+		"internal/x/y_test.go": "package x_test\n\nimport \"example.com/app/internal/x\"\n\nfunc h() { x.F() }\n",
+	})
+	moduleEnv(t)
+	chdir(t, dir)
+
+	pattern, err := ModulePattern(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := GoList{}.List(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{Tests: true}
+	prog, err := c.Load(pkgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range []string{"example.com/app", "example.com/app/internal/x"} {
+		info := prog.Imported[pkg]
+		if info == nil {
+			t.Fatalf("%s not loaded", pkg)
+		}
+		if len(info.Errors) != 0 {
+			t.Errorf("%s: %v", pkg, info.Errors)
+		}
+	}
+	if n := len(prog.Imported["example.com/app/internal/x"].Files); n != 2 {
+		t.Errorf("expected the package and its test file, got %d files", n)
+	}
+	if len(prog.Created) != 1 || prog.Created[0].Pkg.Path() != "example.com/app/internal/x_test" {
+		t.Errorf("expected the external test package to be created, got %v", prog.Created)
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not in PATH")
+	}
+	dir, err := ioutil.TempDir("", "load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"go.work":     "go 1.21\n\nuse (\n\t./lib\n\t./app\n)\n",
+		"lib/go.mod":  "module example.com/lib\n\ngo 1.21\n",
+		"lib/lib.go":  "package lib\n\nfunc F() {}\n",
+		"app/go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"app/main.go": "package main\n\nimport \"example.com/lib\"\n\nfunc main() { lib.F() }\n",
+	})
+	moduleEnv(t)
+	t.Setenv("GOWORK", "")
+
+	// The workspace root isn't a module, but both modules are loaded.
+	chdir(t, dir)
+	_, mods, err := FindWorkspace(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []string
+	for _, m := range mods {
+		pattern, err := ModulePattern(m.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	pkgs, err := GoList{}.List(patterns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{}
+	prog, err := c.Load(append([]string{"example.com/lib"}, pkgs...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := prog.Imported["example.com/app"]
+	if app == nil || len(app.Errors) != 0 {
+		t.Fatalf("example.com/app not loaded: %v", app)
+	}
+	f := prog.Imported["example.com/lib"].Pkg.Scope().Lookup("F")
+	used := false
+	for _, obj := range app.Uses {
+		used = used || obj == f
+	}
+	if !used {
+		t.Errorf("example.com/app doesn't use example.com/lib.F")
+	}
+
+	// Packages of other modules are found from within one of them.
+	chdir(t, filepath.Join(dir, "app"))
+	if _, err := c.Load("example.com/lib"); err != nil {
+		t.Errorf("loading example.com/lib from example.com/app: %v", err)
+	}
+}
+
+func TestFindWorkspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.work":      "go 1.22\n\nuse ./a // the library\n\nuse (\n\t./b\n\t\"./c\"\n)\n",
+		"a/go.mod":     "module example.com/a\n",
+		"b/go.mod":     "module example.com/b\n",
+		"c/go.mod":     "module example.com/c\n",
+		"b/sub/sub.go": "package sub\n",
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOWORK", "")
+	root, mods, err := FindWorkspace(filepath.Join(dir, "b", "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != dir {
+		t.Errorf("expected root %s, got %s", dir, root)
+	}
+	var got []string
+	for _, m := range mods {
+		got = append(got, m.Path+" "+strings.TrimPrefix(m.Dir, dir))
+	}
+	sep := string(filepath.Separator)
+	want := []string{"example.com/a " + sep + "a", "example.com/b " + sep + "b", "example.com/c " + sep + "c"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected modules %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,186 @@
+package load
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FindModule walks up from dir to the nearest directory containing a go.mod
+// file, returning that directory and the module's path.
+func FindModule(dir string) (root, modPath string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			modPath, err := parseModulePath(f)
+			if err != nil {
+				return "", "", errors.New(filepath.Join(dir, "go.mod") + ": " + err.Error())
+			}
+			return dir, modPath, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("no go.mod found in current directory or any parent directory")
+		}
+		dir = parent
+	}
+}
+
+// parseModulePath returns the path of the module directive of a go.mod file.
+func parseModulePath(f *os.File) (string, error) {
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no module directive")
+}
+
+// ModulePattern returns a package pattern matching every package of the
+// module rooted at root, relative to the current directory.
+func ModulePattern(root string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(cwd, root)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "./...", nil
+	}
+	if !strings.HasPrefix(rel, "..") {
+		rel = "." + string(filepath.Separator) + rel
+	}
+	return filepath.ToSlash(rel) + "/...", nil
+}
+
+// InModule reports if a package path belongs to the module with the provided
+// path.
+func InModule(pkg, modPath string) bool {
+	return pkg == modPath || strings.HasPrefix(pkg, modPath+"/")
+}
+
+// Module is a module of a workspace.
+type Module struct {
+	// Dir is the directory containing the module's go.mod file, and Path
+	// the module's path.
+	Dir, Path string
+}
+
+// FindWorkspace walks up from dir to the nearest directory containing a
+// go.work file, or uses the file named by GOWORK, returning the directory
+// containing it and the modules it uses. It returns no modules if there's no
+// workspace or GOWORK is off.
+func FindWorkspace(dir string) (root string, mods []Module, err error) {
+	name := os.Getenv("GOWORK")
+	switch name {
+	case "off":
+		return "", nil, nil
+	case "":
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		for {
+			if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+				name = filepath.Join(dir, "go.work")
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return "", nil, nil
+			}
+			dir = parent
+		}
+	}
+	if name, err = filepath.Abs(name); err != nil {
+		return "", nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	dirs, err := parseUses(f)
+	if err != nil {
+		return "", nil, errors.New(name + ": " + err.Error())
+	}
+	root = filepath.Dir(name)
+	for _, d := range dirs {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(root, d)
+		}
+		m, err := os.Open(filepath.Join(d, "go.mod"))
+		if err != nil {
+			return "", nil, err
+		}
+		modPath, err := parseModulePath(m)
+		m.Close()
+		if err != nil {
+			return "", nil, errors.New(filepath.Join(d, "go.mod") + ": " + err.Error())
+		}
+		mods = append(mods, Module{Dir: d, Path: modPath})
+	}
+	return root, mods, nil
+}
+
+// parseUses returns the directories of the use directives of a go.work file,
+// both single directives and blocks.
+func parseUses(f *os.File) ([]string, error) {
+	var dirs []string
+	block := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case block && line == ")":
+			block = false
+			continue
+		case block:
+		case strings.HasPrefix(line, "use"):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "use"))
+			if line == "(" {
+				block = true
+				continue
+			}
+		default:
+			continue
+		}
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		if line != "" {
+			dirs = append(dirs, filepath.FromSlash(line))
+		}
+	}
+	return dirs, s.Err()
+}
@@ -0,0 +1,140 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Writes limits matches to where variables and fields are written: assigned,
+// including by compound assignments, incremented, decremented, set by range
+// loops and keyed composite literals, or escaping by having their address
+// taken. Assignments to the fields and elements of struct and array values
+// write the variable holding them.
+var Writes Filter = identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	idents, _ := accesses(info, file)
+	return idents
+})
+
+// Reads limits matches to where variables and fields are read. Compound
+// assignments, increments, decrements and taking an address both read and
+// write, and are included.
+var Reads Filter = identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	_, stores := accesses(info, file)
+	idents := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && !stores[ident] {
+			idents[ident] = true
+		}
+		return true
+	})
+	return idents
+})
+
+// accesses returns the identifiers within a file which are written, and the
+// subset of those which are only written.
+func accesses(info *loader.PackageInfo, file *ast.File) (writes, stores map[*ast.Ident]bool) {
+	writes = make(map[*ast.Ident]bool)
+	stores = make(map[*ast.Ident]bool)
+	mark := func(e ast.Expr, store bool) {
+		for _, ident := range writtenIdents(info, e) {
+			writes[ident] = true
+			if store {
+				stores[ident] = true
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			store := n.Tok == token.ASSIGN || n.Tok == token.DEFINE
+			for _, lhs := range n.Lhs {
+				mark(lhs, store)
+			}
+		case *ast.IncDecStmt:
+			mark(n.X, false)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				mark(n.X, false)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					mark(n.Key, true)
+				}
+				if n.Value != nil {
+					mark(n.Value, true)
+				}
+			}
+		case *ast.CompositeLit:
+			t := info.TypeOf(n)
+			if t == nil {
+				return true
+			}
+			if p, ok := t.Underlying().(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			if _, ok := t.Underlying().(*types.Struct); !ok {
+				return true
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						writes[key] = true
+						stores[key] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	return writes, stores
+}
+
+// writtenIdents returns the identifiers naming the variables or fields an
+// expression being written modifies. Writing a field or element of a struct
+// or array value also modifies the value holding it, such as both a and b in
+// a.b.c, if b is a struct.
+func writtenIdents(info *loader.PackageInfo, e ast.Expr) []*ast.Ident {
+	var idents []*ast.Ident
+	for {
+		switch x := ast.Unparen(e).(type) {
+		case *ast.Ident:
+			return append(idents, x)
+		case *ast.SelectorExpr:
+			if s, ok := info.Selections[x]; ok && s.Kind() != types.FieldVal {
+				return idents
+			}
+			idents = append(idents, x.Sel)
+			if !isValue(info.TypeOf(x.X), false) {
+				return idents
+			}
+			e = x.X
+		case *ast.IndexExpr:
+			if !isValue(info.TypeOf(x.X), true) {
+				return idents
+			}
+			e = x.X
+		default:
+			return idents
+		}
+	}
+}
+
+// isValue reports whether t is a struct, or an array if arrays is true,
+// rather than a reference to one, so modifying part of it modifies the
+// variable holding it.
+func isValue(t types.Type, arrays bool) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct:
+		return !arrays
+	case *types.Array:
+		return arrays
+	}
+	return false
+}
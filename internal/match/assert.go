@@ -0,0 +1,38 @@
+package match
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Assert limits matches to the types of type assertions and the cases of
+// type switches.
+var Assert Filter = identFilter(assertedIdents)
+
+// assertedIdents returns the identifiers within a file naming the types of
+// type assertions and type switch cases.
+func assertedIdents(_ *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	idents := make(map[*ast.Ident]bool)
+	add := func(e ast.Expr) {
+		if ident := conversionIdent(e); ident != nil {
+			idents[ident] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TypeAssertExpr:
+			if n.Type != nil {
+				add(n.Type)
+			}
+		case *ast.TypeSwitchStmt:
+			for _, stmt := range n.Body.List {
+				for _, e := range stmt.(*ast.CaseClause).List {
+					add(e)
+				}
+			}
+		}
+		return true
+	})
+	return idents
+}
@@ -0,0 +1,94 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindAssignments returns the identifiers within the packages which are
+// assigned to one of the objects, which should be variables or fields,
+// along with the type of the value assigned at each. Assignments are
+// statements, variable declarations and composite literals. Since unkeyed
+// composite literal elements don't name the field, an empty identifier is
+// synthesized at the start of the element.
+func FindAssignments(pkgs []*loader.PackageInfo, objs map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]types.Type) {
+	var idents []*ast.Ident
+	assigned := make(map[*ast.Ident]types.Type)
+	add := func(ident *ast.Ident, t types.Type) {
+		if t == nil {
+			return
+		}
+		idents = append(idents, ident)
+		assigned[ident] = t
+	}
+	for _, info := range pkgs {
+		// valueType returns the type of the i-th of n values produced by
+		// the expressions on the right hand side of an assignment.
+		valueType := func(rhs []ast.Expr, i, n int) types.Type {
+			if len(rhs) == n {
+				return info.TypeOf(rhs[i])
+			}
+			if len(rhs) == 1 {
+				if tuple, ok := info.TypeOf(rhs[0]).(*types.Tuple); ok && i < tuple.Len() {
+					return tuple.At(i).Type()
+				}
+			}
+			return nil
+		}
+		for _, file := range info.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.AssignStmt:
+					for i, lhs := range n.Lhs {
+						if ident := assignedIdent(info, lhs); ident != nil && objs[info.ObjectOf(ident)] {
+							add(ident, valueType(n.Rhs, i, len(n.Lhs)))
+						}
+					}
+				case *ast.ValueSpec:
+					for i, name := range n.Names {
+						if objs[info.Defs[name]] {
+							add(name, valueType(n.Values, i, len(n.Names)))
+						}
+					}
+				case *ast.CompositeLit:
+					t := info.TypeOf(n)
+					if t == nil {
+						return true
+					}
+					s, ok := t.Underlying().(*types.Struct)
+					if !ok {
+						return true
+					}
+					for i, elt := range n.Elts {
+						if kv, ok := elt.(*ast.KeyValueExpr); ok {
+							if key, ok := kv.Key.(*ast.Ident); ok && objs[info.Uses[key]] {
+								add(key, info.TypeOf(kv.Value))
+							}
+						} else if i < s.NumFields() && objs[s.Field(i)] {
+							add(&ast.Ident{NamePos: elt.Pos()}, info.TypeOf(elt))
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, assigned
+}
+
+// assignedIdent returns the identifier naming the variable or field an
+// assignment's left hand side sets, or nil if it's another expression.
+func assignedIdent(info *loader.PackageInfo, lhs ast.Expr) *ast.Ident {
+	switch e := ast.Unparen(lhs).(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		if s, ok := info.Selections[e]; ok && s.Kind() != types.FieldVal {
+			return nil
+		}
+		return e.Sel
+	}
+	return nil
+}
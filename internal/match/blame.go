@@ -0,0 +1,101 @@
+package match
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Blame describes the commit which last modified a line.
+type Blame struct {
+	Author string
+	Commit string
+	Date   time.Time
+}
+
+// BlameCache holds the blame of every line of each file, indexed by line
+// number minus one. Files which aren't tracked by git map to nil.
+type BlameCache map[string][]*Blame
+
+// Lookup returns the blame for a line of a file, or nil if the file isn't
+// tracked by git.
+func (c BlameCache) Lookup(filename string, line int) (*Blame, error) {
+	lines, ok := c[filename]
+	if !ok {
+		var err error
+		if lines, err = gitBlame(filename); err != nil {
+			return nil, err
+		}
+		c[filename] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return nil, nil
+	}
+	return lines[line-1], nil
+}
+
+// gitBlame runs git blame on the file and parses its porcelain output.
+func gitBlame(filename string) ([]*Blame, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("-blame: could not find git in PATH")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "blame", "--line-porcelain", "--", filepath.Base(filename))
+	cmd.Dir = filepath.Dir(filename)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Files outside of a repository, or not yet committed, have
+		// no history.
+		return nil, nil
+	}
+	return parseBlame(&stdout)
+}
+
+// parseBlame parses the output of git blame --line-porcelain, where each line
+// of the file is preceded by a header naming its commit and followed by the
+// commit's details.
+func parseBlame(r io.Reader) ([]*Blame, error) {
+	var (
+		lines []*Blame
+		cur   *Blame
+		when  int64
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			// The contents of the line ends each entry.
+			if cur == nil {
+				return nil, fmt.Errorf("git blame: unexpected line %q", line)
+			}
+			lines = append(lines, cur)
+			cur = nil
+		case cur == nil:
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("git blame: malformed header %q", line)
+			}
+			cur = &Blame{Commit: fields[0]}
+		case strings.HasPrefix(line, "author "):
+			cur.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			when, _ = strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			cur.Date = time.Unix(when, 0).UTC()
+		case strings.HasPrefix(line, "author-tz "):
+			if t, err := time.Parse("-0700", strings.TrimPrefix(line, "author-tz ")); err == nil {
+				_, offset := t.Zone()
+				cur.Date = time.Unix(when, 0).In(time.FixedZone("", offset))
+			}
+		}
+	}
+	return lines, s.Err()
+}
@@ -0,0 +1,89 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindChanOps returns the channel operations within the packages on channels
+// whose element type is one of the named types or a pointer to one, along
+// with the kind of operation: "send", "receive", "close" or "range", followed
+// by "in select" for cases of select statements. Each operation is matched by
+// the channel's identifier, or an empty identifier synthesized at the start of
+// the channel expression if it isn't one.
+func FindChanOps(pkgs []*loader.PackageInfo, typeNames map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	matches := func(t types.Type) bool {
+		if t == nil {
+			return false
+		}
+		ch, ok := t.Underlying().(*types.Chan)
+		if !ok {
+			return false
+		}
+		elem := ch.Elem()
+		if p, ok := elem.(*types.Pointer); ok {
+			elem = p.Elem()
+		}
+		named, ok := types.Unalias(elem).(*types.Named)
+		return ok && typeNames[named.Origin().Obj()]
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			// comms holds the operations of select cases.
+			comms := make(map[ast.Node]bool)
+			add := func(ch ast.Expr, op ast.Node, kind string) {
+				if !matches(info.TypeOf(ch)) {
+					return
+				}
+				var ident *ast.Ident
+				switch x := ast.Unparen(ch).(type) {
+				case *ast.Ident:
+					ident = x
+				case *ast.SelectorExpr:
+					ident = x.Sel
+				default:
+					ident = &ast.Ident{NamePos: ch.Pos()}
+				}
+				if comms[op] {
+					kind += " in select"
+				}
+				idents = append(idents, ident)
+				details[ident] = kind
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CommClause:
+					switch comm := n.Comm.(type) {
+					case *ast.SendStmt:
+						comms[comm] = true
+					case *ast.ExprStmt:
+						comms[ast.Unparen(comm.X)] = true
+					case *ast.AssignStmt:
+						if len(comm.Rhs) == 1 {
+							comms[ast.Unparen(comm.Rhs[0])] = true
+						}
+					}
+				case *ast.SendStmt:
+					add(n.Chan, n, "send")
+				case *ast.UnaryExpr:
+					if n.Op == token.ARROW {
+						add(n.X, n, "receive")
+					}
+				case *ast.RangeStmt:
+					add(n.X, n, "range")
+				case *ast.CallExpr:
+					if b, ok := info.Uses[calledIdent(n.Fun)].(*types.Builtin); ok && b.Name() == "close" && len(n.Args) == 1 {
+						add(n.Args[0], n, "close")
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
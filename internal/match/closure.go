@@ -0,0 +1,125 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Closure limits matches to identifiers within function literals, such as
+// the closures of goroutines, recording the function enclosing each.
+type Closure struct {
+	// Details is set by Filter to describe each match kept, such as "in
+	// closure in Server.Serve", or "captured by closure in Server.Serve"
+	// for variables declared by the enclosing function outside of the
+	// literal, and their fields and methods.
+	Details map[*ast.Ident]string
+}
+
+// Filter returns the identifiers within function literals.
+func (c *Closure) Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident {
+	// closure is the outermost function literal of a function.
+	type closure struct {
+		lit  *ast.FuncLit
+		name string
+	}
+	infos := make(map[*token.File]*loader.PackageInfo)
+	files := make(map[*token.File]*ast.File)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			tf := prog.Fset.File(file.Pos())
+			infos[tf], files[tf] = info, file
+		}
+	}
+	found := make(map[*token.File][]closure)
+	c.Details = make(map[*ast.Ident]string)
+	var filtered []*ast.Ident
+	for _, ident := range idents {
+		tf := prog.Fset.File(ident.Pos())
+		file, ok := files[tf]
+		if !ok {
+			continue
+		}
+		closures, ok := found[tf]
+		if !ok {
+			for _, decl := range file.Decls {
+				name := declName(decl)
+				ast.Inspect(decl, func(n ast.Node) bool {
+					if lit, ok := n.(*ast.FuncLit); ok {
+						closures = append(closures, closure{lit, name})
+						return false
+					}
+					return true
+				})
+			}
+			found[tf] = closures
+		}
+		for _, cl := range closures {
+			if ident.Pos() < cl.lit.Pos() || ident.Pos() >= cl.lit.End() {
+				continue
+			}
+			filtered = append(filtered, ident)
+			c.Details[ident] = "in closure in " + cl.name
+			if captured(infos[tf], ident, cl.lit) {
+				c.Details[ident] = "captured by closure in " + cl.name
+			}
+			break
+		}
+	}
+	return filtered
+}
+
+// captured reports whether ident uses a variable of the function enclosing
+// lit, declared outside of it, or selects a field or method of one, such as
+// count in s.count.
+func captured(info *loader.PackageInfo, ident *ast.Ident, lit *ast.FuncLit) bool {
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if ok && sel.Sel == ident {
+			for {
+				switch x := ast.Unparen(sel.X).(type) {
+				case *ast.Ident:
+					ident = x
+				case *ast.SelectorExpr:
+					sel = x
+					continue
+				}
+				break
+			}
+			return false
+		}
+		return true
+	})
+	v, ok := info.Uses[ident].(*types.Var)
+	if !ok || v.IsField() || v.Parent() == nil || v.Parent() == info.Pkg.Scope() {
+		return false
+	}
+	return v.Pos() < lit.Pos() || v.Pos() >= lit.End()
+}
+
+// declName returns the name of a package level declaration, such as
+// "Server.Serve" for a method, or the first name it declares.
+func declName(decl ast.Decl) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		name := decl.Name.Name
+		if decl.Recv != nil && len(decl.Recv.List) > 0 {
+			if base := receiverBase(decl.Recv.List[0].Type); base != nil {
+				name = base.Name + "." + name
+			}
+		}
+		return name
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				return spec.Names[0].Name
+			case *ast.TypeSpec:
+				return spec.Name.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package match
+
+import (
+	"bufio"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Known values of GOOS and GOARCH, used to interpret filename suffixes such
+// as _linux_arm64.go. See go/build/syslist.go.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+		"js": true, "linux": true, "nacl": true, "netbsd": true,
+		"openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true,
+		"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+		"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+		"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+		"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// ConstraintCache memoizes the build constraints of each file.
+type ConstraintCache map[string]string
+
+// Lookup returns a human readable description of the conditions under which
+// the file is built, or an empty string if it's always built.
+func (c ConstraintCache) Lookup(filename string) string {
+	s, ok := c[filename]
+	if !ok {
+		s = fileConstraints(filename)
+		c[filename] = s
+	}
+	return s
+}
+
+// fileConstraints combines the constraints implied by a file's name with
+// those in its //go:build or // +build lines.
+func fileConstraints(filename string) string {
+	var parts []string
+	if goos, goarch := filenameConstraints(filepath.Base(filename)); goos != "" || goarch != "" {
+		if goos != "" {
+			parts = append(parts, goos)
+		}
+		if goarch != "" {
+			parts = append(parts, goarch)
+		}
+	}
+	if expr := headerConstraint(filename); expr != nil {
+		s := expr.String()
+		if _, ok := expr.(*constraint.OrExpr); ok {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " && ")
+}
+
+// filenameConstraints interprets the _GOOS, _GOARCH and _GOOS_GOARCH file
+// name suffixes.
+func filenameConstraints(name string) (goos, goarch string) {
+	name = strings.TrimSuffix(name, ".go")
+	name = strings.TrimSuffix(name, "_test")
+	l := strings.Split(name, "_")
+	if n := len(l); n >= 3 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return l[n-2], l[n-1]
+	}
+	if n := len(l); n >= 2 {
+		if knownOS[l[n-1]] {
+			return l[n-1], ""
+		}
+		if knownArch[l[n-1]] {
+			return "", l[n-1]
+		}
+	}
+	return "", ""
+}
+
+// headerConstraint parses the build constraints appearing before the package
+// clause of a file. //go:build lines take precedence over // +build lines.
+func headerConstraint(filename string) constraint.Expr {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(line) && !constraint.IsPlusBuild(line) {
+			continue
+		}
+		expr, err := constraint.Parse(line)
+		if err != nil {
+			continue
+		}
+		if constraint.IsGoBuild(line) {
+			goBuild = expr
+		} else {
+			plusBuild = append(plusBuild, expr)
+		}
+	}
+	if goBuild != nil || len(plusBuild) == 0 {
+		return goBuild
+	}
+	expr := plusBuild[0]
+	for _, e := range plusBuild[1:] {
+		expr = &constraint.AndExpr{X: expr, Y: e}
+	}
+	return expr
+}
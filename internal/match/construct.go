@@ -0,0 +1,75 @@
+package match
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Construct limits matches to where types are constructed: the types of
+// composite literals, including slice, array and map literals whose elements
+// elide their type, and the arguments of new.
+var Construct Filter = identFilter(constructedIdents)
+
+// constructedIdents returns the identifiers within a file naming the types
+// of composite literals and the arguments of new.
+func constructedIdents(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	idents := make(map[*ast.Ident]bool)
+	add := func(e ast.Expr) {
+		if ident := conversionIdent(e); ident != nil {
+			idents[ident] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			if n.Type == nil {
+				return true
+			}
+			switch t := ast.Unparen(n.Type).(type) {
+			case *ast.ArrayType:
+				if elidesType(n, false) {
+					add(t.Elt)
+				}
+			case *ast.MapType:
+				if elidesType(n, true) {
+					add(t.Key)
+				}
+				if elidesType(n, false) {
+					add(t.Value)
+				}
+			default:
+				add(t)
+			}
+		case *ast.CallExpr:
+			if isBuiltinCall("new")(info, n) && len(n.Args) == 1 {
+				if _, ok := ast.Unparen(n.Args[0]).(*ast.StarExpr); !ok {
+					add(n.Args[0])
+				}
+			}
+		}
+		return true
+	})
+	return idents
+}
+
+// elidesType reports whether any element of a slice, array or map literal,
+// or any key of a map literal if keys is true, is a composite literal
+// without a type.
+func elidesType(lit *ast.CompositeLit, keys bool) bool {
+	for _, elt := range lit.Elts {
+		e := elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			e = kv.Value
+			if keys {
+				e = kv.Key
+			}
+		} else if keys {
+			continue
+		}
+		if c, ok := e.(*ast.CompositeLit); ok && c.Type == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package match
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Convert limits matches to the types of conversions, such as T in T(x).
+// Conversions to pointers, such as (*T)(p), name a different type and
+// aren't included.
+var Convert Filter = identFilter(convertedIdents)
+
+// convertedIdents returns the identifiers within a file naming the type of a
+// conversion.
+func convertedIdents(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	idents := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() {
+			return true
+		}
+		fun := ast.Unparen(call.Fun)
+		switch x := fun.(type) {
+		case *ast.IndexExpr:
+			fun = x.X
+		case *ast.IndexListExpr:
+			fun = x.X
+		}
+		switch x := fun.(type) {
+		case *ast.Ident:
+			idents[x] = true
+		case *ast.SelectorExpr:
+			idents[x.Sel] = true
+		}
+		return true
+	})
+	return idents
+}
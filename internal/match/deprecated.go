@@ -0,0 +1,106 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Deprecations returns the objects declared by a package whose doc comments
+// include a deprecation notice, a paragraph beginning with "Deprecated: ",
+// such as functions, methods, types, variables, constants, struct fields and
+// interface methods. Each is mapped to a description naming it followed by
+// the first sentence of its notice, such as
+// "ioutil.ReadAll: As of Go 1.16, this function simply calls io.ReadAll.".
+// The package's files must be parsed with comments.
+func Deprecations(info *loader.PackageInfo) map[types.Object]string {
+	deprecated := make(map[types.Object]string)
+	add := func(ident *ast.Ident, name, notice string) {
+		if obj := info.Defs[ident]; obj != nil && notice != "" {
+			deprecated[obj] = info.Pkg.Name() + "." + name + ": " + notice
+		}
+	}
+	for _, file := range info.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				add(decl.Name, declName(decl), deprecation(decl.Doc))
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						notice := deprecation(spec.Doc)
+						if spec.Doc == nil {
+							notice = deprecation(decl.Doc)
+						}
+						for _, name := range spec.Names {
+							add(name, name.Name, notice)
+						}
+					case *ast.TypeSpec:
+						notice := deprecation(spec.Doc)
+						if spec.Doc == nil {
+							notice = deprecation(decl.Doc)
+						}
+						add(spec.Name, spec.Name.Name, notice)
+						addMembers(spec.Name.Name, spec.Type, add)
+					}
+				}
+			}
+		}
+	}
+	return deprecated
+}
+
+// addMembers adds the fields of struct types and methods of interface types
+// within a type declaration with deprecation notices, named by the path of
+// names leading to them.
+func addMembers(prefix string, typ ast.Expr, add func(ident *ast.Ident, name, notice string)) {
+	var fields *ast.FieldList
+	switch typ := typ.(type) {
+	case *ast.StructType:
+		fields = typ.Fields
+	case *ast.InterfaceType:
+		fields = typ.Methods
+	default:
+		return
+	}
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			add(name, prefix+"."+name.Name, deprecation(field.Doc))
+			addMembers(prefix+"."+name.Name, field.Type, add)
+		}
+	}
+}
+
+// PackageDeprecation returns the first sentence of the deprecation notice in
+// a package's doc comment, or an empty string if it isn't deprecated.
+func PackageDeprecation(info *loader.PackageInfo) string {
+	for _, file := range info.Files {
+		if notice := deprecation(file.Doc); notice != "" {
+			return notice
+		}
+	}
+	return ""
+}
+
+// deprecation returns the first sentence of the deprecation notice within a
+// doc comment, without the "Deprecated: " prefix, or an empty string if there
+// isn't one.
+func deprecation(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if !strings.HasPrefix(para, "Deprecated: ") {
+			continue
+		}
+		notice := strings.Join(strings.Fields(strings.TrimPrefix(para, "Deprecated: ")), " ")
+		if i := strings.Index(notice, ". "); i >= 0 {
+			notice = notice[:i+1]
+		}
+		return notice
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package match
+
+import (
+	"go/types"
+	"sort"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+// DepUses is the number of uses of the expression within a dependency which
+// wasn't searched.
+type DepUses struct {
+	Dependency string
+	Uses       int
+}
+
+// CountDeps counts the uses, or declarations if defs is true, of the objects
+// within loaded packages which weren't searched. Counts are grouped by
+// package, with vendored packages reported by their original import path.
+// Standard library packages are ignored.
+func CountDeps(prog *loader.Program, objs map[types.Object]bool, searched []string, defs bool) []DepUses {
+	skip := make(map[string]bool)
+	for _, pkg := range searched {
+		skip[pkg] = true
+	}
+	counts := make(map[string]int)
+	for pkg, info := range prog.AllPackages {
+		if skip[pkg.Path()] || len(info.Errors) != 0 {
+			continue
+		}
+		identsMap := info.Uses
+		if defs {
+			identsMap = info.Defs
+		}
+		n := 0
+		for _, o := range identsMap {
+			if objs[o] {
+				n++
+			}
+		}
+		if n != 0 && !load.IsStdlib(pkg.Path()) {
+			counts[load.Unvendor(pkg.Path())] += n
+		}
+	}
+
+	deps := make([]DepUses, 0, len(counts))
+	for dep, n := range counts {
+		deps = append(deps, DepUses{dep, n})
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Uses != deps[j].Uses {
+			return deps[i].Uses > deps[j].Uses
+		}
+		return deps[i].Dependency < deps[j].Dependency
+	})
+	return deps
+}
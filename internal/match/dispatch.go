@@ -0,0 +1,72 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindDispatch returns the identifiers within the packages of interface
+// method calls, method values and method expressions which may dispatch to
+// one of the concrete methods, along with the interface called through at
+// each. As with class hierarchy analysis, a call may dispatch to a method if
+// a type declared in all, whose method set includes the method, implements
+// the interface. Calls through type parameters are treated as calls through
+// their constraint.
+func FindDispatch(all []*loader.PackageInfo, pkgs []*loader.PackageInfo, methods map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	// receivers maps each method's name to the types whose method set
+	// includes one of the methods, such as types embedding the receiver.
+	receivers := make(map[string][]types.Type)
+	for _, info := range all {
+		for _, obj := range info.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			for m := range methods {
+				obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, m.Pkg(), m.Name())
+				if obj == m {
+					receivers[m.Name()] = append(receivers[m.Name()], named)
+				}
+			}
+		}
+	}
+
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for sel, s := range info.Selections {
+			if s.Kind() == types.FieldVal {
+				continue
+			}
+			recvs := receivers[sel.Sel.Name]
+			if len(recvs) == 0 {
+				continue
+			}
+			recv := s.Recv()
+			if p, ok := recv.(*types.Pointer); ok {
+				recv = p.Elem()
+			}
+			if t, ok := types.Unalias(recv).(*types.TypeParam); ok {
+				recv = t.Constraint()
+			}
+			iface, _ := recv.Underlying().(*types.Interface)
+			if iface == nil {
+				continue
+			}
+			for _, t := range recvs {
+				if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+					idents = append(idents, sel.Sel)
+					details[sel.Sel] = "via " + types.TypeString(recv, PackageName)
+					break
+				}
+			}
+		}
+	}
+	return idents, details
+}
@@ -0,0 +1,129 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// errorsPkgs are the packages whose Is and As functions check errors.
+var errorsPkgs = map[string]bool{
+	"errors":                true,
+	"golang.org/x/xerrors":  true,
+	"github.com/pkg/errors": true,
+}
+
+// FindErrorChecks returns the checks within the packages of errors against
+// the sentinel error variables or error types, along with the kind of check:
+// "errors.Is", "errors.As", "== comparison", "!= comparison", "switch case",
+// "type switch case" or "type assertion". Each check is matched by the
+// identifier naming the variable or type, or for errors.As, the variable
+// the error is stored in, or an empty identifier synthesized at the start of
+// the argument if it isn't one.
+func FindErrorChecks(pkgs []*loader.PackageInfo, objs map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		// named returns the identifier naming one of the objects in
+		// expr, an identifier or selector, or a pointer to one if ptr is
+		// true.
+		named := func(expr ast.Expr, ptr bool) *ast.Ident {
+			expr = ast.Unparen(expr)
+			if star, ok := expr.(*ast.StarExpr); ok && ptr {
+				expr = ast.Unparen(star.X)
+			}
+			var ident *ast.Ident
+			switch x := expr.(type) {
+			case *ast.Ident:
+				ident = x
+			case *ast.SelectorExpr:
+				ident = x.Sel
+			default:
+				return nil
+			}
+			if !objs[info.Uses[ident]] {
+				return nil
+			}
+			return ident
+		}
+		add := func(ident *ast.Ident, kind string) {
+			if ident != nil {
+				idents = append(idents, ident)
+				details[ident] = kind
+			}
+		}
+		for _, file := range info.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					fn, ok := info.Uses[calledIdent(n.Fun)].(*types.Func)
+					if !ok || fn.Pkg() == nil || !errorsPkgs[fn.Pkg().Path()] || len(n.Args) != 2 {
+						return true
+					}
+					switch fn.Name() {
+					case "Is":
+						add(named(n.Args[1], false), "errors.Is")
+					case "As":
+						if !asTarget(info, n.Args[1], objs) {
+							return true
+						}
+						arg := ast.Unparen(n.Args[1])
+						if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND {
+							arg = ast.Unparen(u.X)
+						}
+						ident, ok := arg.(*ast.Ident)
+						if !ok {
+							ident = &ast.Ident{NamePos: n.Args[1].Pos()}
+						}
+						add(ident, "errors.As")
+					}
+				case *ast.BinaryExpr:
+					if n.Op != token.EQL && n.Op != token.NEQ {
+						return true
+					}
+					kind := n.Op.String() + " comparison"
+					add(named(n.X, false), kind)
+					add(named(n.Y, false), kind)
+				case *ast.SwitchStmt:
+					if n.Tag == nil {
+						return true
+					}
+					for _, stmt := range n.Body.List {
+						for _, e := range stmt.(*ast.CaseClause).List {
+							add(named(e, false), "switch case")
+						}
+					}
+				case *ast.TypeSwitchStmt:
+					for _, stmt := range n.Body.List {
+						for _, e := range stmt.(*ast.CaseClause).List {
+							add(named(e, true), "type switch case")
+						}
+					}
+				case *ast.TypeAssertExpr:
+					if n.Type != nil {
+						add(named(n.Type, true), "type assertion")
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
+
+// asTarget reports whether the target argument of errors.As, a pointer, points
+// to one of the types or a pointer to one.
+func asTarget(info *loader.PackageInfo, arg ast.Expr, objs map[types.Object]bool) bool {
+	p, ok := info.TypeOf(arg).(*types.Pointer)
+	if !ok {
+		return false
+	}
+	t := p.Elem()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && objs[named.Origin().Obj()]
+}
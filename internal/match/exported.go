@@ -0,0 +1,43 @@
+package match
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Exported limits matches to the declarations of exported functions, exported
+// methods of exported types, and exported types, the API of the package.
+var Exported Filter = identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+	var decls []ast.Node
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				if base := receiverBase(decl.Recv.List[0].Type); base == nil || !base.IsExported() {
+					continue
+				}
+			}
+			decls = append(decls, decl)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.IsExported() {
+					decls = append(decls, ts)
+				}
+			}
+		}
+	}
+	idents := make(map[*ast.Ident]bool)
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				idents[ident] = true
+			}
+			return true
+		})
+	}
+	return idents
+})
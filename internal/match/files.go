@@ -0,0 +1,46 @@
+package match
+
+import (
+	"go/ast"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Files limits matches within the directories of the files, by absolute path,
+// to the files. Matches in other directories are kept.
+type Files map[string]bool
+
+// Filter returns the identifiers within the files or outside of their
+// directories.
+func (f Files) Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident {
+	dirs := make(map[string]bool)
+	for name := range f {
+		dirs[filepath.Dir(name)] = true
+	}
+	var filtered []*ast.Ident
+	for _, ident := range idents {
+		name := prog.Fset.Position(ident.Pos()).Filename
+		if f[name] || !dirs[filepath.Dir(name)] {
+			filtered = append(filtered, ident)
+		}
+	}
+	return filtered
+}
+
+// TestFiles limits matches to *_test.go files.
+var TestFiles Filter = testFiles{}
+
+type testFiles struct{}
+
+// Filter returns the identifiers within *_test.go files.
+func (testFiles) Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident {
+	var filtered []*ast.Ident
+	for _, ident := range idents {
+		if strings.HasSuffix(prog.Fset.Position(ident.Pos()).Filename, "_test.go") {
+			filtered = append(filtered, ident)
+		}
+	}
+	return filtered
+}
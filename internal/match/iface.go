@@ -0,0 +1,196 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindInterfaceConversions returns the values within the packages whose type
+// is one of the named types or a pointer to one, and which are converted to
+// an interface: assigned to a variable, field or element of interface type,
+// passed as such an argument, returned as such a result, sent on such a
+// channel, or converted explicitly. Each is returned with how it's converted,
+// such as "passed as io.Writer". Values which aren't identifiers are matched
+// by an empty identifier synthesized at their start.
+func FindInterfaceConversions(pkgs []*loader.PackageInfo, typeNames map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	matches := func(t types.Type) bool {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		named, ok := types.Unalias(t).(*types.Named)
+		return ok && typeNames[named.Origin().Obj()]
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		// convert records a value of type t converted to the type to,
+		// if to is an interface.
+		convert := func(value ast.Expr, t, to types.Type, how string) {
+			if t == nil || to == nil || !types.IsInterface(to) || !matches(t) {
+				return
+			}
+			ident, ok := ast.Unparen(value).(*ast.Ident)
+			if !ok {
+				ident = &ast.Ident{NamePos: value.Pos()}
+			}
+			idents = append(idents, ident)
+			details[ident] = how + " " + types.TypeString(to, PackageName)
+		}
+		// assign records the conversions of values assigned to lhs types.
+		assign := func(lhs []types.Type, rhs []ast.Expr, how string) {
+			if len(rhs) == 1 && len(lhs) > 1 {
+				if tuple, ok := info.TypeOf(rhs[0]).(*types.Tuple); ok {
+					for i := 0; i < tuple.Len() && i < len(lhs); i++ {
+						convert(rhs[0], tuple.At(i).Type(), lhs[i], how)
+					}
+				}
+				return
+			}
+			for i, value := range rhs {
+				if i < len(lhs) {
+					convert(value, info.TypeOf(value), lhs[i], how)
+				}
+			}
+		}
+		for _, file := range info.Files {
+			// results holds the results of the enclosing functions.
+			var results []*types.Tuple
+			var stack []ast.Node
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					switch stack[len(stack)-1].(type) {
+					case *ast.FuncDecl, *ast.FuncLit:
+						results = results[:len(results)-1]
+					}
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				stack = append(stack, n)
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					var res *types.Tuple
+					if obj, ok := info.Defs[n.Name].(*types.Func); ok {
+						res = obj.Type().(*types.Signature).Results()
+					}
+					results = append(results, res)
+				case *ast.FuncLit:
+					var res *types.Tuple
+					if sig, ok := info.TypeOf(n).(*types.Signature); ok {
+						res = sig.Results()
+					}
+					results = append(results, res)
+				case *ast.AssignStmt:
+					if n.Tok != token.ASSIGN {
+						return true
+					}
+					lhs := make([]types.Type, len(n.Lhs))
+					for i, e := range n.Lhs {
+						lhs[i] = info.TypeOf(e)
+					}
+					assign(lhs, n.Rhs, "assigned to")
+				case *ast.ValueSpec:
+					if n.Type == nil {
+						return true
+					}
+					lhs := make([]types.Type, len(n.Names))
+					for i := range lhs {
+						lhs[i] = info.TypeOf(n.Type)
+					}
+					assign(lhs, n.Values, "assigned to")
+				case *ast.ReturnStmt:
+					if len(results) == 0 || results[len(results)-1] == nil {
+						return true
+					}
+					res := results[len(results)-1]
+					lhs := make([]types.Type, res.Len())
+					for i := range lhs {
+						lhs[i] = res.At(i).Type()
+					}
+					assign(lhs, n.Results, "returned as")
+				case *ast.SendStmt:
+					if ch, ok := info.TypeOf(n.Chan).Underlying().(*types.Chan); ok {
+						convert(n.Value, info.TypeOf(n.Value), ch.Elem(), "sent as")
+					}
+				case *ast.CallExpr:
+					tv, ok := info.Types[n.Fun]
+					if !ok {
+						return true
+					}
+					if tv.IsType() {
+						if len(n.Args) == 1 {
+							convert(n.Args[0], info.TypeOf(n.Args[0]), tv.Type, "converted to")
+						}
+						return true
+					}
+					sig, ok := tv.Type.Underlying().(*types.Signature)
+					if !ok || n.Ellipsis.IsValid() {
+						return true
+					}
+					params := sig.Params()
+					lhs := make([]types.Type, len(n.Args))
+					for i := range lhs {
+						switch {
+						case sig.Variadic() && i >= params.Len()-1:
+							if s, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+								lhs[i] = s.Elem()
+							}
+						case i < params.Len():
+							lhs[i] = params.At(i).Type()
+						}
+					}
+					if len(n.Args) == 1 && params.Len() > 1 {
+						// f(g()) passing several results.
+						lhs = make([]types.Type, params.Len())
+						for i := range lhs {
+							lhs[i] = params.At(i).Type()
+						}
+					}
+					assign(lhs, n.Args, "passed as")
+				case *ast.CompositeLit:
+					t := info.TypeOf(n)
+					if t == nil {
+						return true
+					}
+					switch u := t.Underlying().(type) {
+					case *types.Struct:
+						for i, elt := range n.Elts {
+							if kv, ok := elt.(*ast.KeyValueExpr); ok {
+								if key, ok := kv.Key.(*ast.Ident); ok {
+									if f, ok := info.Uses[key].(*types.Var); ok {
+										convert(kv.Value, info.TypeOf(kv.Value), f.Type(), "assigned to")
+									}
+								}
+							} else if i < u.NumFields() {
+								convert(elt, info.TypeOf(elt), u.Field(i).Type(), "assigned to")
+							}
+						}
+					case *types.Slice, *types.Array, *types.Map:
+						var key, elem types.Type
+						switch u := u.(type) {
+						case *types.Slice:
+							elem = u.Elem()
+						case *types.Array:
+							elem = u.Elem()
+						case *types.Map:
+							key, elem = u.Key(), u.Elem()
+						}
+						for _, elt := range n.Elts {
+							if kv, ok := elt.(*ast.KeyValueExpr); ok {
+								if key != nil {
+									convert(kv.Key, info.TypeOf(kv.Key), key, "stored as")
+								}
+								elt = kv.Value
+							}
+							convert(elt, info.TypeOf(elt), elem, "stored as")
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
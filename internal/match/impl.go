@@ -0,0 +1,85 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// PackageName qualifies types by their package's name rather than its path,
+// such as *os.File.
+func PackageName(p *types.Package) string {
+	return p.Name()
+}
+
+// FindImplementers returns the identifiers declaring types within the
+// packages which implement one of the interfaces, along with a description
+// of the methods satisfying the interface, such as "*T: Close, Write via
+// Buffer". Methods promoted from embedded fields name the field's type. The
+// receiver is prefixed only if a pointer to the type is required. Generic
+// types aren't considered, since their method sets depend on their type
+// arguments.
+func FindImplementers(pkgs []*loader.PackageInfo, ifaces map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	var interfaces []*types.Interface
+	for obj := range ifaces {
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok && iface.NumMethods() > 0 {
+			interfaces = append(interfaces, iface)
+		}
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for ident, obj := range info.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			var descs []string
+			for _, iface := range interfaces {
+				if d := implementation(named, iface); d != "" {
+					descs = append(descs, d)
+				}
+			}
+			if len(descs) > 0 {
+				idents = append(idents, ident)
+				details[ident] = strings.Join(descs, "; ")
+			}
+		}
+	}
+	return idents, details
+}
+
+// implementation describes the methods by which t or *t implements iface,
+// or returns an empty string if neither does.
+func implementation(t *types.Named, iface *types.Interface) string {
+	var recv types.Type = t
+	prefix := ""
+	if !types.Implements(recv, iface) {
+		recv = types.NewPointer(t)
+		if !types.Implements(recv, iface) {
+			return ""
+		}
+		prefix = "*" + t.Obj().Name() + ": "
+	}
+	var methods []string
+	for i := 0; i < iface.NumMethods(); i++ {
+		name := iface.Method(i).Name()
+		obj, index, _ := types.LookupFieldOrMethod(recv, false, iface.Method(i).Pkg(), name)
+		m, ok := obj.(*types.Func)
+		if ok && len(index) > 1 {
+			if r := m.Type().(*types.Signature).Recv(); r != nil {
+				name += " via " + strings.TrimPrefix(types.TypeString(r.Type(), PackageName), "*")
+			}
+		}
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	return prefix + strings.Join(methods, ", ")
+}
@@ -0,0 +1,97 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindIndexOps returns the index and key operations within the packages on
+// values of the named map, slice or array types, or pointers to them, along
+// with the kind of operation: "read", "write" for assignments to an element,
+// "comma-ok read" for v, ok := m[k], "delete" and "slice" for slice
+// expressions. Each operation is matched by the identifier naming the indexed
+// value, such as users in s.users[id], or an empty identifier synthesized at
+// the start of the indexed expression.
+func FindIndexOps(pkgs []*loader.PackageInfo, typeNames map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	matches := func(t types.Type) bool {
+		if t == nil {
+			return false
+		}
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		named, ok := types.Unalias(t).(*types.Named)
+		return ok && typeNames[named.Origin().Obj()]
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			// kinds holds the kind of index expressions which aren't
+			// reads, by expression.
+			kinds := make(map[ast.Expr]string)
+			add := func(x ast.Expr, kind string) {
+				if !matches(info.TypeOf(x)) {
+					return
+				}
+				var ident *ast.Ident
+				switch x := ast.Unparen(x).(type) {
+				case *ast.Ident:
+					ident = x
+				case *ast.SelectorExpr:
+					ident = x.Sel
+				default:
+					ident = &ast.Ident{NamePos: x.Pos()}
+				}
+				idents = append(idents, ident)
+				details[ident] = kind
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.AssignStmt:
+					for _, lhs := range n.Lhs {
+						kinds[ast.Unparen(lhs)] = "write"
+					}
+					if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+						kinds[ast.Unparen(n.Rhs[0])] = "comma-ok read"
+					}
+				case *ast.ValueSpec:
+					if len(n.Names) == 2 && len(n.Values) == 1 {
+						kinds[ast.Unparen(n.Values[0])] = "comma-ok read"
+					}
+				case *ast.IncDecStmt:
+					kinds[ast.Unparen(n.X)] = "write"
+				case *ast.RangeStmt:
+					if n.Tok == token.ASSIGN {
+						for _, e := range []ast.Expr{n.Key, n.Value} {
+							if e != nil {
+								kinds[ast.Unparen(e)] = "write"
+							}
+						}
+					}
+				case *ast.IndexExpr:
+					if tv, ok := info.Types[n.X]; ok && tv.IsType() {
+						// An instantiation of a generic type.
+						return true
+					}
+					kind, ok := kinds[n]
+					if !ok {
+						kind = "read"
+					}
+					add(n.X, kind)
+				case *ast.SliceExpr:
+					add(n.X, "slice")
+				case *ast.CallExpr:
+					if b, ok := info.Uses[calledIdent(n.Fun)].(*types.Builtin); ok && b.Name() == "delete" && len(n.Args) == 2 {
+						add(n.Args[0], "delete")
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
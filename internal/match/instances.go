@@ -0,0 +1,272 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindInstances returns where generic functions and types are instantiated
+// within the packages, along with the type arguments at each, such as
+// "[[]pkg.T, pkg.T]". Type arguments are reported whether they're explicit
+// or inferred. If typeArg is set, only instantiations with a type argument
+// which is, or is composed of, the type it names, such as pkg.T, are
+// returned.
+func FindInstances(pkgs []*loader.PackageInfo, objs map[types.Object]bool, typeArg string) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, inst := range Instances(pkgs, objs) {
+		if typeArg != "" && !mentionsAny(inst.TypeArgs, typeArg) {
+			continue
+		}
+		idents = append(idents, inst.Ident)
+		details[inst.Ident] = inst.String()
+	}
+	return idents, details
+}
+
+// Instance is an instantiation of a generic function or type.
+type Instance struct {
+	Ident    *ast.Ident
+	Obj      types.Object
+	TypeArgs []types.Type
+}
+
+// String returns the type arguments, such as "[[]pkg.T, pkg.T]".
+func (inst Instance) String() string {
+	names := make([]string, len(inst.TypeArgs))
+	for i, arg := range inst.TypeArgs {
+		names[i] = types.TypeString(arg, PackageName)
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// Instances returns the instantiations of the generic functions and types
+// within the packages.
+func Instances(pkgs []*loader.PackageInfo, objs map[types.Object]bool) []Instance {
+	var insts []Instance
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			var stack []ast.Node
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				stack = append(stack, n)
+				ident, ok := n.(*ast.Ident)
+				if !ok || !objs[info.Uses[ident]] {
+					return true
+				}
+				if args := typeArgs(info, info.Uses[ident], stack); args != nil {
+					insts = append(insts, Instance{ident, info.Uses[ident], args})
+				}
+				return true
+			})
+		}
+	}
+	return insts
+}
+
+// typeArgs returns the type arguments of the generic function or type used by
+// the identifier at the top of the stack, or nil if it isn't instantiated.
+func typeArgs(info *loader.PackageInfo, obj types.Object, stack []ast.Node) []types.Type {
+	// The expression instantiated, such as pkg.F in pkg.F[T].
+	var expr ast.Expr = stack[len(stack)-1].(*ast.Ident)
+	i := len(stack) - 2
+	if i >= 0 {
+		if sel, ok := stack[i].(*ast.SelectorExpr); ok && sel.Sel == expr {
+			expr = sel
+			i--
+		}
+	}
+	if i >= 0 {
+		switch p := stack[i].(type) {
+		case *ast.IndexExpr:
+			if p.X == expr {
+				expr = p
+			}
+		case *ast.IndexListExpr:
+			if p.X == expr {
+				expr = p
+			}
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		named, ok := info.TypeOf(expr).(*types.Named)
+		if !ok || named.TypeArgs().Len() == 0 {
+			return nil
+		}
+		args := make([]types.Type, named.TypeArgs().Len())
+		for i := range args {
+			args[i] = named.TypeArgs().At(i)
+		}
+		return args
+	case *types.Func:
+		generic := obj.Type().(*types.Signature)
+		inst, ok := info.TypeOf(expr).(*types.Signature)
+		if !ok || generic.TypeParams().Len() == 0 || inst.TypeParams().Len() != 0 {
+			return nil
+		}
+		bound := make(map[*types.TypeParam]types.Type)
+		unify(generic, inst, bound)
+		// Type parameters only used in the constraints of others, such as
+		// V in [M ~map[K]V, K comparable, V any], are inferred from the
+		// core types of the constraints.
+		for n := -1; n != len(bound); {
+			n = len(bound)
+			for i := 0; i < generic.TypeParams().Len(); i++ {
+				tp := generic.TypeParams().At(i)
+				if arg, ok := bound[tp]; ok {
+					if core := coreType(tp.Constraint()); core != nil {
+						unify(core, arg.Underlying(), bound)
+					}
+				}
+			}
+		}
+		args := make([]types.Type, generic.TypeParams().Len())
+		for i := range args {
+			tp := generic.TypeParams().At(i)
+			if args[i] = bound[tp]; args[i] == nil {
+				// Type parameters which don't appear in the signature
+				// must be explicit.
+				if list := indices(expr); i < len(list) {
+					args[i] = info.TypeOf(list[i])
+				}
+			}
+			if args[i] == nil {
+				args[i] = tp
+			}
+		}
+		return args
+	}
+	return nil
+}
+
+func indices(e ast.Expr) []ast.Expr {
+	switch e := e.(type) {
+	case *ast.IndexExpr:
+		return []ast.Expr{e.Index}
+	case *ast.IndexListExpr:
+		return e.Indices
+	}
+	return nil
+}
+
+// unify binds the type parameters within a generic type to the corresponding
+// parts of its instantiation.
+func unify(generic, inst types.Type, bound map[*types.TypeParam]types.Type) {
+	switch g := generic.(type) {
+	case *types.TypeParam:
+		if _, ok := bound[g]; !ok {
+			bound[g] = inst
+		}
+	case *types.Pointer:
+		if i, ok := inst.(*types.Pointer); ok {
+			unify(g.Elem(), i.Elem(), bound)
+		}
+	case *types.Slice:
+		if i, ok := inst.(*types.Slice); ok {
+			unify(g.Elem(), i.Elem(), bound)
+		}
+	case *types.Array:
+		if i, ok := inst.(*types.Array); ok {
+			unify(g.Elem(), i.Elem(), bound)
+		}
+	case *types.Chan:
+		if i, ok := inst.(*types.Chan); ok {
+			unify(g.Elem(), i.Elem(), bound)
+		}
+	case *types.Map:
+		if i, ok := inst.(*types.Map); ok {
+			unify(g.Key(), i.Key(), bound)
+			unify(g.Elem(), i.Elem(), bound)
+		}
+	case *types.Signature:
+		if i, ok := inst.(*types.Signature); ok {
+			unifyTuples(g.Params(), i.Params(), bound)
+			unifyTuples(g.Results(), i.Results(), bound)
+		}
+	case *types.Struct:
+		if i, ok := inst.(*types.Struct); ok && g.NumFields() == i.NumFields() {
+			for j := 0; j < g.NumFields(); j++ {
+				unify(g.Field(j).Type(), i.Field(j).Type(), bound)
+			}
+		}
+	case *types.Named:
+		if i, ok := inst.(*types.Named); ok && g.TypeArgs().Len() == i.TypeArgs().Len() {
+			for j := 0; j < g.TypeArgs().Len(); j++ {
+				unify(g.TypeArgs().At(j), i.TypeArgs().At(j), bound)
+			}
+		}
+	}
+}
+
+// coreType returns the type a constraint such as ~map[K]V restricts the
+// underlying type of its type parameter to, or nil if there isn't one.
+func coreType(constraint types.Type) types.Type {
+	iface, ok := constraint.Underlying().(*types.Interface)
+	if !ok || iface.NumEmbeddeds() != 1 {
+		return nil
+	}
+	switch t := iface.EmbeddedType(0).(type) {
+	case *types.Union:
+		if t.Len() == 1 {
+			return t.Term(0).Type().Underlying()
+		}
+		return nil
+	case *types.Interface:
+		return nil
+	default:
+		return t.Underlying()
+	}
+}
+
+func unifyTuples(generic, inst *types.Tuple, bound map[*types.TypeParam]types.Type) {
+	if generic.Len() != inst.Len() {
+		return
+	}
+	for i := 0; i < generic.Len(); i++ {
+		unify(generic.At(i).Type(), inst.At(i).Type(), bound)
+	}
+}
+
+// mentionsAny reports whether any of the types is, or is composed of, the
+// named type, such as a slice or map of it, or an instantiation with it.
+func mentionsAny(ts []types.Type, name string) bool {
+	for _, t := range ts {
+		if mentions(t, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentions(t types.Type, name string) bool {
+	if types.TypeString(t, PackageName) == name {
+		return true
+	}
+	switch t := t.(type) {
+	case *types.Pointer:
+		return mentions(t.Elem(), name)
+	case *types.Slice:
+		return mentions(t.Elem(), name)
+	case *types.Array:
+		return mentions(t.Elem(), name)
+	case *types.Chan:
+		return mentions(t.Elem(), name)
+	case *types.Map:
+		return mentions(t.Key(), name) || mentions(t.Elem(), name)
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if mentions(t.TypeArgs().At(i), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package match
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Statements launching calls, as reported by Launched.
+const (
+	// InGo is a go statement.
+	InGo = 1 << iota
+	// InDefer is a defer statement.
+	InDefer
+)
+
+// Launched limits matches to functions called by any of the statements in
+// stmts, a combination of InGo and InDefer, such as cleanup in defer
+// cleanup(). Calls within a function literal launched by the statement, such
+// as go func() { serve() }(), are included, but not the evaluation of its
+// arguments, which happens immediately.
+func Launched(stmts int) Filter {
+	return identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+		idents := make(map[*ast.Ident]bool)
+		launch := func(call *ast.CallExpr) {
+			if lit, ok := ast.Unparen(call.Fun).(*ast.FuncLit); ok {
+				ast.Inspect(lit.Body, func(n ast.Node) bool {
+					if call, ok := n.(*ast.CallExpr); ok {
+						if ident := calledIdent(call.Fun); ident != nil {
+							idents[ident] = true
+						}
+					}
+					return true
+				})
+				return
+			}
+			if ident := calledIdent(call.Fun); ident != nil {
+				idents[ident] = true
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.GoStmt:
+				if stmts&InGo != 0 {
+					launch(n.Call)
+				}
+			case *ast.DeferStmt:
+				if stmts&InDefer != 0 {
+					launch(n.Call)
+				}
+			}
+			return true
+		})
+		return idents
+	})
+}
@@ -0,0 +1,202 @@
+// Package match finds the identifiers which refer to a set of objects,
+// filters them, and reads the source lines they appear on.
+package match
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+// Find returns the identifiers within the packages which use the objects,
+// or which declare them if defs is true.
+func Find(pkgs []*loader.PackageInfo, objs map[types.Object]bool, defs bool) []*ast.Ident {
+	var idents []*ast.Ident
+	for _, info := range pkgs {
+		identsMap := info.Uses
+		if defs {
+			identsMap = info.Defs
+		}
+		for ident, o := range identsMap {
+			if objs[o] {
+				idents = append(idents, ident)
+			}
+		}
+	}
+	return idents
+}
+
+// Filter removes identifiers from a set of matches.
+type Filter interface {
+	// Filter returns the identifiers to keep. pkgs are the packages which
+	// were searched.
+	Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident
+}
+
+// identFilter keeps matches within the identifiers it returns for each file.
+type identFilter func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool
+
+// Filter returns the identifiers in the sets returned for their files.
+func (f identFilter) Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident {
+	files := make(map[*token.File]*ast.File)
+	infos := make(map[*ast.File]*loader.PackageInfo)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			files[prog.Fset.File(file.Pos())] = file
+			infos[file] = info
+		}
+	}
+
+	found := make(map[*ast.File]map[*ast.Ident]bool)
+	var filtered []*ast.Ident
+	for _, ident := range idents {
+		file, ok := files[prog.Fset.File(ident.Pos())]
+		if !ok {
+			continue
+		}
+		keep, ok := found[file]
+		if !ok {
+			keep = f(infos[file], file)
+			found[file] = keep
+		}
+		if keep[ident] {
+			filtered = append(filtered, ident)
+		}
+	}
+	return filtered
+}
+
+// ByPos sorts identifiers by their position.
+type ByPos []*ast.Ident
+
+func (p ByPos) Len() int           { return len(p) }
+func (p ByPos) Less(i, j int) bool { return p[i].NamePos < p[j].NamePos }
+func (p ByPos) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+type fileErr struct {
+	pos token.Position
+	err error
+}
+
+func (f *fileErr) Error() string {
+	return fmt.Sprintf("%s:%d:%v", f.pos.Filename, f.pos.Line, f.err)
+}
+
+// Match is a single search result.
+type Match struct {
+	Filename string
+	Line     int
+	Column   int
+	// Text holds the contents of the matched line without a trailing newline.
+	Text        string
+	Constraints string `json:",omitempty"`
+	Blame       *Blame `json:",omitempty"`
+	// Detail describes the match further, such as the type of the value
+	// assigned when searching for assignments.
+	Detail string `json:",omitempty"`
+	// Target is the expression the match matched, when searching for
+	// several.
+	Target string `json:",omitempty"`
+	// Kind is "def" or "use" when searching for both declarations and
+	// uses.
+	Kind string `json:",omitempty"`
+
+	// End is the byte offset within Text where the identifier ends.
+	End int `json:"-"`
+}
+
+// Definition is an object an expression resolved to, reported before its
+// matches so the object can be checked.
+type Definition struct {
+	// Definition is the declaration of the object, such as
+	// "func net.Dial(network string, address string) (net.Conn, error)".
+	Definition string
+	// The position of the object, which is empty for objects without
+	// one, such as builtins.
+	Filename string `json:",omitempty"`
+	Line     int    `json:",omitempty"`
+	Column   int    `json:",omitempty"`
+}
+
+// Define returns the definition of an object.
+func Define(fset *token.FileSet, obj types.Object) Definition {
+	d := Definition{Definition: types.ObjectString(obj, PackageName)}
+	if obj.Pos().IsValid() {
+		p := fset.Position(obj.Pos())
+		d.Filename, d.Line, d.Column = p.Filename, p.Line, p.Column
+	}
+	return d
+}
+
+// Reader reads the lines of matches. Since matches are printed in file
+// order, only the most recently used file is kept in memory, unless Files is
+// set, and no file is held open between reads.
+type Reader struct {
+	// Files, if non-nil, holds the contents of the files as loaded, which
+	// are read instead of the files on disk.
+	Files *load.Snapshot
+
+	filename string
+	data     []byte
+}
+
+// Read reads the line containing the identifier.
+func (r *Reader) Read(fset *token.FileSet, ident *ast.Ident) (*Match, error) {
+	pos := fset.Position(ident.NamePos)
+	line, err := r.line(pos)
+	if err != nil {
+		return nil, err
+	}
+	end := fset.Position(ident.End()).Column - 1
+	if len(line) < end {
+		return nil, &fileErr{pos, errors.New("identifier extends past end of line")}
+	}
+	return &Match{
+		Filename: pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Text:     line,
+		End:      end,
+	}, nil
+}
+
+// line returns the line containing pos, without its trailing newline or
+// carriage return.
+func (r *Reader) line(pos token.Position) (string, error) {
+	if r.filename != pos.Filename {
+		var data []byte
+		if r.Files != nil {
+			var err error
+			if data, err = r.Files.ReadFile(pos.Filename); err != nil {
+				return "", &fileErr{pos, err}
+			}
+		} else {
+			f, err := load.OpenFile(pos.Filename)
+			if err != nil {
+				return "", err
+			}
+			data, err = ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return "", &fileErr{pos, err}
+			}
+		}
+		r.filename, r.data = pos.Filename, data
+	}
+	start := pos.Offset - (pos.Column - 1)
+	if start < 0 || start > len(r.data) {
+		return "", &fileErr{pos, errors.New("position is past the end of the file")}
+	}
+	line := r.data[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return string(bytes.TrimSuffix(line, []byte("\r"))), nil
+}
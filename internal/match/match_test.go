@@ -0,0 +1,210 @@
+package match
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilenameConstraints(t *testing.T) {
+	tests := []struct {
+		name   string
+		goos   string
+		goarch string
+	}{
+		{"conn.go", "", ""},
+		{"conn_linux.go", "linux", ""},
+		{"conn_arm64.go", "", "arm64"},
+		{"conn_windows_amd64_test.go", "windows", "amd64"},
+		{"linux.go", "", ""},
+		{"color_linux_test.go", "linux", ""},
+	}
+	for _, tt := range tests {
+		goos, goarch := filenameConstraints(tt.name)
+		if goos != tt.goos || goarch != tt.goarch {
+			t.Errorf("filenameConstraints(%q): expected (%q, %q), got (%q, %q)", tt.name, tt.goos, tt.goarch, goos, goarch)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		ident  string
+		text   string
+		column int
+		end    int
+	}{
+		{
+			name:   "last line without newline",
+			src:    "package p\n\nvar foo int",
+			ident:  "foo",
+			text:   "var foo int",
+			column: 5,
+			end:    7,
+		},
+		{
+			name:   "tabs",
+			src:    "package p\n\nfunc f() {\n\t\tfoo()\n}\n",
+			ident:  "foo",
+			text:   "\t\tfoo()",
+			column: 3,
+			end:    5,
+		},
+		{
+			name:   "long line",
+			src:    "package p\n\nvar x = " + strings.Repeat("1 + ", 20000) + "foo\n",
+			ident:  "foo",
+			text:   "var x = " + strings.Repeat("1 + ", 20000) + "foo",
+			column: 80009,
+			end:    80011,
+		},
+		{
+			name:   "crlf",
+			src:    "package p\r\n\r\nvar foo int\r\n",
+			ident:  "foo",
+			text:   "var foo int",
+			column: 5,
+			end:    7,
+		},
+		{
+			name:   "multi-byte identifier",
+			src:    "package p\n\nvar ñame, 名前 int\n",
+			ident:  "名前",
+			text:   "var ñame, 名前 int",
+			column: 12,
+			end:    17,
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		filename := filepath.Join(dir, strings.Replace(tt.name, " ", "_", -1)+".go")
+		if err := ioutil.WriteFile(filename, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var ident *ast.Ident
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == tt.ident {
+				ident = id
+			}
+			return true
+		})
+
+		m, err := new(Reader).Read(fset, ident)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if m.Text != tt.text {
+			t.Errorf("%s: expected text %q, got %q", tt.name, tt.text, m.Text)
+		}
+		if m.Column != tt.column || m.End != tt.end {
+			t.Errorf("%s: expected column %d and end %d, got %d and %d", tt.name, tt.column, tt.end, m.Column, m.End)
+		}
+		if m.Text[m.Column-1:m.End] != tt.ident {
+			t.Errorf("%s: expected identifier %q, got %q", tt.name, tt.ident, m.Text[m.Column-1:m.End])
+		}
+	}
+}
+
+func TestTagKeyOffset(t *testing.T) {
+	tests := []struct {
+		tag string
+		key string
+		off int
+		ok  bool
+	}{
+		{`json:"name"`, "json", 0, true},
+		{`json:"name,omitempty" yaml:"name"`, "yaml", 22, true},
+		{`json:"yaml:\"x\"" yaml:"-"`, "yaml", 18, true},
+		{`json:"name"`, "yaml", 0, false},
+		{`json`, "json", 0, false},
+	}
+	for _, tt := range tests {
+		off, ok := tagKeyOffset(tt.tag, tt.key)
+		if off != tt.off || ok != tt.ok {
+			t.Errorf("tagKeyOffset(%q, %q): expected (%d, %t), got (%d, %t)", tt.tag, tt.key, tt.off, tt.ok, off, ok)
+		}
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{"// F does things.\n//\n// Deprecated: Use G instead. F is slow.", "Use G instead."},
+		{"// Deprecated: use the\n// new API.", "use the new API."},
+		{"// F isn't Deprecated: it's fine.", ""},
+		{"// F does things.", ""},
+	}
+	for _, tt := range tests {
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\n\n"+tt.doc+"\nfunc F() {}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := deprecation(f.Decls[0].(*ast.FuncDecl).Doc); got != tt.want {
+			t.Errorf("deprecation(%q): expected %q, got %q", tt.doc, tt.want, got)
+		}
+	}
+}
+
+func TestInExportedDecl(t *testing.T) {
+	src := `package p
+
+func Exported() { _ = 1 }
+
+func unexported() { _ = 2 }
+
+func (*T) Method() { _ = 3 }
+
+func (u) Method() { _ = 4 }
+
+var (
+	V = 5
+	v = 6
+)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"1": true, "2": false, "3": true, "4": false, "5": true, "6": false}
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		if got := inExportedDecl(f, lit.Pos()); got != want[lit.Value] {
+			t.Errorf("inExportedDecl(%s): expected %t, got %t", lit.Value, want[lit.Value], got)
+		}
+		return true
+	})
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("production=3,exported=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Weights{Production: 3, Exported: 5}); w != want {
+		t.Errorf("expected %+v, got %+v", want, w)
+	}
+	for _, s := range []string{"", "production", "production=x", "tests=1"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("ParseWeights(%q): expected error", s)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindMethods returns the identifiers within the packages referring to any
+// method with the name, along with the receiver type declaring the method at
+// each, such as "*os.File". If pkgPath is set, only methods of types declared
+// in that package are matched. If defs is true, only declarations are
+// returned.
+func FindMethods(pkgs []*loader.PackageInfo, name, pkgPath string, defs bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	receiver := func(obj types.Object) (string, bool) {
+		f, ok := obj.(*types.Func)
+		if !ok || f.Name() != name {
+			return "", false
+		}
+		recv := f.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return "", false
+		}
+		t := recv.Type()
+		ptr := false
+		if p, ok := t.(*types.Pointer); ok {
+			t, ptr = p.Elem(), true
+		}
+		if named, ok := types.Unalias(t).(*types.Named); ok {
+			t = named.Origin()
+			if pkgPath != "" && (named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != pkgPath) {
+				return "", false
+			}
+		} else if pkgPath != "" {
+			return "", false
+		}
+		s := types.TypeString(t, PackageName)
+		if ptr {
+			s = "*" + s
+		}
+		return s, true
+	}
+	var idents []*ast.Ident
+	receivers := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		objs := info.Uses
+		if defs {
+			objs = info.Defs
+		}
+		for ident, obj := range objs {
+			if obj == nil {
+				continue
+			}
+			if recv, ok := receiver(obj); ok {
+				idents = append(idents, ident)
+				receivers[ident] = recv
+			}
+		}
+	}
+	return idents, receivers
+}
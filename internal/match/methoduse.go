@@ -0,0 +1,86 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Ways a function or method is used, as reported by MethodUse.
+const (
+	// UseCall is a call, such as t.Method() or F().
+	UseCall = 1 << iota
+	// UseMethodValue is a method value which isn't called, such as
+	// t.Method passed as a callback.
+	UseMethodValue
+	// UseMethodExpr is a method expression, such as T.Method or
+	// (*T).Method, whether or not it's called.
+	UseMethodExpr
+)
+
+// MethodUse limits matches to functions and methods used in any of the ways
+// in uses, a combination of UseCall, UseMethodValue and UseMethodExpr.
+func MethodUse(uses int) Filter {
+	return identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+		idents := make(map[*ast.Ident]bool)
+		called := make(map[*ast.Ident]bool)
+		// selections holds the selections of the identifiers which are
+		// selected, which are nil for qualified identifiers.
+		selections := make(map[*ast.Ident]*types.Selection)
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.CallExpr:
+				if ident := calledIdent(x.Fun); ident != nil {
+					called[ident] = true
+				}
+			case *ast.SelectorExpr:
+				selections[x.Sel] = info.Selections[x]
+			}
+			return true
+		})
+		ast.Inspect(file, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if _, ok := info.Uses[ident].(*types.Func); !ok {
+				return true
+			}
+			sel := selections[ident]
+			var use int
+			switch {
+			case sel != nil && sel.Kind() == types.MethodExpr:
+				use = UseMethodExpr
+			case called[ident]:
+				use = UseCall
+			case sel != nil && sel.Kind() == types.MethodVal:
+				use = UseMethodValue
+			}
+			if use&uses != 0 {
+				idents[ident] = true
+			}
+			return true
+		})
+		return idents
+	})
+}
+
+// calledIdent returns the identifier naming the function called by a call's
+// function expression, such as Method in t.Method or F in F[int].
+func calledIdent(fun ast.Expr) *ast.Ident {
+	fun = ast.Unparen(fun)
+	switch x := fun.(type) {
+	case *ast.IndexExpr:
+		fun = ast.Unparen(x.X)
+	case *ast.IndexListExpr:
+		fun = ast.Unparen(x.X)
+	}
+	switch x := fun.(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package match
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// constructs are the syntax constructs which may be passed to -near.
+var constructs = map[string]func(info *loader.PackageInfo, n ast.Node) bool{
+	"panic":       isBuiltinCall("panic"),
+	"recover":     isBuiltinCall("recover"),
+	"goto":        isBranch(token.GOTO),
+	"fallthrough": isBranch(token.FALLTHROUGH),
+	"label": func(_ *loader.PackageInfo, n ast.Node) bool {
+		_, ok := n.(*ast.LabeledStmt)
+		return ok
+	},
+	"defer": func(_ *loader.PackageInfo, n ast.Node) bool {
+		_, ok := n.(*ast.DeferStmt)
+		return ok
+	},
+	"go": func(_ *loader.PackageInfo, n ast.Node) bool {
+		_, ok := n.(*ast.GoStmt)
+		return ok
+	},
+	"select": func(_ *loader.PackageInfo, n ast.Node) bool {
+		_, ok := n.(*ast.SelectStmt)
+		return ok
+	},
+}
+
+func isBuiltinCall(name string) func(*loader.PackageInfo, ast.Node) bool {
+	return func(info *loader.PackageInfo, n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+		if !ok {
+			return false
+		}
+		b, ok := info.Uses[ident].(*types.Builtin)
+		return ok && b.Name() == name
+	}
+}
+
+func isBranch(tok token.Token) func(*loader.PackageInfo, ast.Node) bool {
+	return func(_ *loader.PackageInfo, n ast.Node) bool {
+		b, ok := n.(*ast.BranchStmt)
+		return ok && b.Tok == tok
+	}
+}
+
+// Near limits matches to those close to a syntax construct.
+type Near struct {
+	construct string
+	// lines is the maximum distance in lines between a match and the
+	// construct. If negative, the match must be within the same top level
+	// declaration as the construct.
+	lines int
+}
+
+// ParseNear parses a -near value of the form "construct" or
+// "construct:lines". A trailing "()" on the construct is ignored.
+func ParseNear(s string) (*Near, error) {
+	n := &Near{construct: s, lines: -1}
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		lines, err := strconv.Atoi(s[i+1:])
+		if err != nil || lines < 0 {
+			return nil, fmt.Errorf("-near: invalid distance %q", s[i+1:])
+		}
+		n.construct, n.lines = s[:i], lines
+	}
+	n.construct = strings.TrimSuffix(n.construct, "()")
+	if _, ok := constructs[n.construct]; !ok {
+		var names []string
+		for name := range constructs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("-near: unknown construct %q, expected one of %s", n.construct, strings.Join(names, ", "))
+	}
+	return n, nil
+}
+
+// Filter returns the identifiers which are near the construct.
+func (n *Near) Filter(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident) []*ast.Ident {
+	type fileInfo struct {
+		file *ast.File
+		info *loader.PackageInfo
+	}
+	files := make(map[*token.File]fileInfo)
+	for _, info := range pkgs {
+		for _, f := range info.Files {
+			files[prog.Fset.File(f.Pos())] = fileInfo{f, info}
+		}
+	}
+
+	// Positions of the construct within each file.
+	found := make(map[*ast.File][]token.Pos)
+	matches := constructs[n.construct]
+	var filtered []*ast.Ident
+	for _, ident := range idents {
+		fi, ok := files[prog.Fset.File(ident.Pos())]
+		if !ok {
+			continue
+		}
+		positions, ok := found[fi.file]
+		if !ok {
+			ast.Inspect(fi.file, func(node ast.Node) bool {
+				if node != nil && matches(fi.info, node) {
+					positions = append(positions, node.Pos())
+				}
+				return true
+			})
+			found[fi.file] = positions
+		}
+		for _, pos := range positions {
+			if n.near(prog.Fset, fi.file, ident.Pos(), pos) {
+				filtered = append(filtered, ident)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func (n *Near) near(fset *token.FileSet, file *ast.File, a, b token.Pos) bool {
+	if n.lines >= 0 {
+		d := fset.Position(a).Line - fset.Position(b).Line
+		return -n.lines <= d && d <= n.lines
+	}
+	for _, decl := range file.Decls {
+		if decl.Pos() <= a && a < decl.End() {
+			return decl.Pos() <= b && b < decl.End()
+		}
+	}
+	return false
+}
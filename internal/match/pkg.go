@@ -0,0 +1,116 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+// FindPackage returns the imports of a package within the packages, and every
+// reference to it: the package name qualifying its members, and members used
+// through dot imports. Each is returned with a detail: "import" or "dot
+// import" for imports, the member referred to, and for members of dot
+// imports, the member followed by "via dot import", since they aren't
+// qualified where they're used. Since import paths aren't
+// identifiers, the returned identifiers for imports are synthesized to span
+// the path. If copies is true, other copies of the package with the same
+// path once any vendor directory is removed, such as vendored copies, are
+// included.
+func FindPackage(pkgs []*loader.PackageInfo, path string, copies bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	matches := func(p *types.Package) bool {
+		return p != nil && (p.Path() == path || (copies && load.Unvendor(p.Path()) == load.Unvendor(path)))
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			for _, spec := range file.Imports {
+				if pkgName := importedName(info, spec); pkgName != nil && matches(pkgName.Imported()) {
+					ident := &ast.Ident{NamePos: spec.Path.Pos(), Name: spec.Path.Value}
+					idents = append(idents, ident)
+					details[ident] = "import"
+					if isDotImport(spec) {
+						details[ident] = "dot import"
+					}
+				}
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					x, ok := n.X.(*ast.Ident)
+					if !ok {
+						return true
+					}
+					if pkgName, ok := info.Uses[x].(*types.PkgName); ok {
+						if matches(pkgName.Imported()) {
+							idents = append(idents, x)
+							details[x] = n.Sel.Name
+						}
+						// Don't visit the qualified member.
+						return false
+					}
+				case *ast.Ident:
+					// Members of dot imports, which are the only
+					// unqualified references to package level
+					// objects of other packages.
+					obj := info.Uses[n]
+					if obj != nil && obj.Pkg() != info.Pkg && matches(obj.Pkg()) && obj.Parent() == obj.Pkg().Scope() {
+						idents = append(idents, n)
+						details[n] = n.Name + " via dot import"
+					}
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
+
+// FindDotImports returns the dot imports of a package within the packages,
+// such as import . "example.com/pkg", which make its members usable without
+// qualifying them. As with FindPackage, the returned identifiers are
+// synthesized to span the import path, and each is returned with the detail
+// "dot import".
+func FindDotImports(pkgs []*loader.PackageInfo, path string, copies bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			for _, spec := range file.Imports {
+				if !isDotImport(spec) {
+					continue
+				}
+				pkgName := importedName(info, spec)
+				if pkgName == nil {
+					continue
+				}
+				if p := pkgName.Imported().Path(); p != path && (!copies || load.Unvendor(p) != load.Unvendor(path)) {
+					continue
+				}
+				ident := &ast.Ident{NamePos: spec.Path.Pos(), Name: spec.Path.Value}
+				idents = append(idents, ident)
+				details[ident] = "dot import"
+			}
+		}
+	}
+	return idents, details
+}
+
+// importedName returns the package name an import declares, which is implicit
+// for imports without a name.
+func importedName(info *loader.PackageInfo, spec *ast.ImportSpec) *types.PkgName {
+	var obj types.Object
+	if spec.Name != nil {
+		obj = info.Defs[spec.Name]
+	} else {
+		obj = info.Implicits[spec]
+	}
+	pkgName, _ := obj.(*types.PkgName)
+	return pkgName
+}
+
+func isDotImport(spec *ast.ImportSpec) bool {
+	return spec.Name != nil && spec.Name.Name == "."
+}
@@ -0,0 +1,69 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindPromoted returns the selections within the packages of fields and
+// methods promoted through embedded fields, such as d.Close where Derived
+// embeds Base, along with the embedded fields each is promoted through, such
+// as "promoted through Derived.Base". Fields and methods promoted from
+// instantiations of generic types are resolved to their generic declaration.
+func FindPromoted(pkgs []*loader.PackageInfo, objs map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for sel, s := range info.Selections {
+			index := s.Index()
+			if len(index) < 2 || !objs[origin(s.Obj())] {
+				continue
+			}
+			idents = append(idents, sel.Sel)
+			details[sel.Sel] = "promoted through " + embeddedPath(s.Recv(), index[:len(index)-1])
+		}
+	}
+	return idents, details
+}
+
+// origin returns the generic declaration of a field or method of an
+// instantiated type, or the object itself.
+func origin(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// embeddedPath returns the type and the embedded fields selected by the
+// indices, such as "Derived.Base".
+func embeddedPath(t types.Type, index []int) string {
+	deref := func(t types.Type) types.Type {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			return p.Elem()
+		}
+		return t
+	}
+	t = deref(t)
+	name := types.TypeString(t, PackageName)
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		name = named.Obj().Name()
+	}
+	names := []string{name}
+	for _, i := range index {
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := st.Field(i)
+		names = append(names, f.Name())
+		t = deref(f.Type())
+	}
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,188 @@
+package match
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Weights are the scores given to properties of matches when ranking them.
+type Weights struct {
+	// Production is given to matches outside of *_test.go files.
+	Production int
+	// Handwritten is given to matches outside of generated files.
+	Handwritten int
+	// Exported is given to matches within exported declarations, such as
+	// exported functions and methods of exported types.
+	Exported int
+}
+
+// DefaultWeights rank production code first, then handwritten code, then
+// exported declarations.
+var DefaultWeights = Weights{Production: 4, Handwritten: 2, Exported: 1}
+
+// ParseWeights parses comma separated weights of the form "name=N", such as
+// "production=4,exported=1". Weights which aren't provided are zero.
+func ParseWeights(s string) (Weights, error) {
+	var w Weights
+	for _, field := range strings.Split(s, ",") {
+		i := strings.Index(field, "=")
+		if i < 0 {
+			return w, fmt.Errorf("invalid weight %q, expected name=N", field)
+		}
+		n, err := strconv.Atoi(field[i+1:])
+		if err != nil {
+			return w, fmt.Errorf("invalid weight %q, expected name=N", field)
+		}
+		switch field[:i] {
+		case "production":
+			w.Production = n
+		case "handwritten":
+			w.Handwritten = n
+		case "exported":
+			w.Exported = n
+		default:
+			return w, fmt.Errorf("unknown weight %q, expected production, handwritten or exported", field[:i])
+		}
+	}
+	return w, nil
+}
+
+// Rank sorts identifiers by descending score, and by position within the
+// same score.
+func Rank(prog *loader.Program, pkgs []*loader.PackageInfo, idents []*ast.Ident, w Weights) {
+	files := make(map[*token.File]*ast.File)
+	for _, info := range pkgs {
+		for _, f := range info.Files {
+			files[prog.Fset.File(f.Pos())] = f
+		}
+	}
+	scores := make(map[*ast.Ident]int, len(idents))
+	generated := make(map[string]bool)
+	for _, ident := range idents {
+		tf := prog.Fset.File(ident.Pos())
+		score := 0
+		if !strings.HasSuffix(tf.Name(), "_test.go") {
+			score += w.Production
+		}
+		gen, ok := generated[tf.Name()]
+		if !ok {
+			gen = isGenerated(tf.Name())
+			generated[tf.Name()] = gen
+		}
+		if !gen {
+			score += w.Handwritten
+		}
+		f := files[tf]
+		if f != nil && inExportedDecl(f, ident.Pos()) {
+			score += w.Exported
+		}
+		scores[ident] = score
+	}
+	sort.Sort(ByPos(idents))
+	sort.SliceStable(idents, func(i, j int) bool {
+		return scores[idents[i]] > scores[idents[j]]
+	})
+}
+
+// inExportedDecl reports whether a position is within an exported top level
+// declaration: an exported function, a method whose receiver type and name
+// are exported, or the spec of an exported type, variable or constant.
+func inExportedDecl(f *ast.File, pos token.Pos) bool {
+	within := func(n ast.Node) bool { return n.Pos() <= pos && pos < n.End() }
+	for _, decl := range f.Decls {
+		if !within(decl) {
+			continue
+		}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				return false
+			}
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				return true
+			}
+			return receiverExported(decl.Recv.List[0].Type)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if !within(spec) {
+					continue
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					return spec.Name.IsExported()
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							return true
+						}
+					}
+				}
+				return false
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// receiverExported reports whether the base type of a receiver is exported.
+func receiverExported(e ast.Expr) bool {
+	base := receiverBase(e)
+	return base != nil && base.IsExported()
+}
+
+// receiverBase returns the name of the base type of a receiver, such as T in
+// *T or T[K], or nil if it isn't named.
+func receiverBase(e ast.Expr) *ast.Ident {
+	for {
+		switch t := e.(type) {
+		case *ast.StarExpr:
+			e = t.X
+		case *ast.ParenExpr:
+			e = t.X
+		case *ast.IndexExpr:
+			e = t.X
+		case *ast.IndexListExpr:
+			e = t.X
+		case *ast.Ident:
+			return t
+		default:
+			return nil
+		}
+	}
+}
+
+// generatedRegexp matches the comment which marks a file as generated.
+// See https://golang.org/s/generatedcode.
+var generatedRegexp = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports if the file contains a generated code comment before
+// its package clause. Files are read since comments aren't parsed when
+// loading packages.
+func isGenerated(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if generatedRegexp.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Reachable returns the functions and methods declared in the packages which
+// are reachable from the roots: those they call or reference, such as a
+// function passed as a callback, and so on. As with FindDispatch, a call
+// through an interface or type parameter reaches each method of the types
+// declared in the packages which implement it. Functions declared outside of
+// the packages aren't followed, so callbacks through them are only reached
+// if they're referenced from a reachable function.
+func Reachable(pkgs []*loader.PackageInfo, roots map[types.Object]bool) map[types.Object]bool {
+	type body struct {
+		info *loader.PackageInfo
+		decl *ast.FuncDecl
+	}
+	bodies := make(map[types.Object]body)
+	var named []*types.Named
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+					if obj := info.Defs[fd.Name]; obj != nil {
+						bodies[obj] = body{info, fd}
+					}
+				}
+			}
+		}
+		for _, obj := range info.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+				continue
+			}
+			if t, ok := tn.Type().(*types.Named); ok && t.TypeParams().Len() == 0 {
+				named = append(named, t)
+			}
+		}
+	}
+
+	reached := make(map[types.Object]bool)
+	var queue []types.Object
+	reach := func(obj types.Object) {
+		if !reached[obj] {
+			reached[obj] = true
+			queue = append(queue, obj)
+		}
+	}
+	for obj := range roots {
+		reach(obj)
+	}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		b, ok := bodies[obj]
+		if !ok {
+			continue
+		}
+		ast.Inspect(b.decl.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				s, ok := b.info.Selections[n]
+				if !ok || s.Kind() == types.FieldVal {
+					return true
+				}
+				recv := s.Recv()
+				if p, ok := recv.(*types.Pointer); ok {
+					recv = p.Elem()
+				}
+				if t, ok := types.Unalias(recv).(*types.TypeParam); ok {
+					recv = t.Constraint()
+				}
+				iface, _ := recv.Underlying().(*types.Interface)
+				if iface == nil {
+					return true
+				}
+				for _, t := range named {
+					if !types.Implements(t, iface) && !types.Implements(types.NewPointer(t), iface) {
+						continue
+					}
+					m, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, s.Obj().Pkg(), s.Obj().Name())
+					if m != nil {
+						reach(m)
+					}
+				}
+			case *ast.Ident:
+				if f, ok := b.info.Uses[n].(*types.Func); ok {
+					reach(f.Origin())
+				}
+			}
+			return true
+		})
+	}
+	return reached
+}
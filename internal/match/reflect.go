@@ -0,0 +1,156 @@
+package match
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// marshalers are the names of functions and methods which marshal values
+// using their struct tags, such as json.Marshal or (*gob.Encoder).Encode.
+var marshalers = map[string]bool{
+	"Marshal":       true,
+	"MarshalIndent": true,
+	"Unmarshal":     true,
+	"Encode":        true,
+	"EncodeElement": true,
+	"Decode":        true,
+	"DecodeElement": true,
+}
+
+// FindReflect returns the references to the named types within the packages
+// made through reflection, which a search for uses misses. These are values
+// of the types, or pointers, slices or arrays of them, passed to
+// reflect.TypeOf or reflect.ValueOf, to functions and methods marshaling
+// them by their struct tags, such as json.Marshal, to functions registering
+// them, such as gob.Register, or to calls which also pass a string naming
+// the type, such as a registry of plugins. Each reference is returned with
+// how it's made, such as "reflect.TypeOf", "marshaled by json.Marshal" or
+// "registered as "config"", and is matched by the identifier of the value
+// or type passed, or an empty identifier synthesized at the start of the
+// argument if there isn't one.
+func FindReflect(pkgs []*loader.PackageInfo, typeNames map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	// named returns the type name of t, a named type or a pointer, slice
+	// or array of one, if it's one of the types.
+	named := func(t types.Type) *types.TypeName {
+		for t != nil {
+			switch u := types.Unalias(t).(type) {
+			case *types.Pointer:
+				t = u.Elem()
+			case *types.Slice:
+				t = u.Elem()
+			case *types.Array:
+				t = u.Elem()
+			case *types.Named:
+				if obj := u.Origin().Obj(); typeNames[obj] {
+					return obj
+				}
+				return nil
+			default:
+				return nil
+			}
+		}
+		return nil
+	}
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fn, ok := info.Uses[calledIdent(call.Fun)].(*types.Func)
+				if !ok || fn.Pkg() == nil {
+					return true
+				}
+				for _, arg := range call.Args {
+					obj := named(info.TypeOf(arg))
+					if obj == nil {
+						continue
+					}
+					var how string
+					switch {
+					case fn.Pkg().Path() == "reflect" && (fn.Name() == "TypeOf" || fn.Name() == "ValueOf"):
+						how = "reflect." + fn.Name()
+					case nameArg(info, call, obj) != "":
+						how = fmt.Sprintf("registered as %q", nameArg(info, call, obj))
+					case strings.HasPrefix(fn.Name(), "Register"):
+						how = "registered by " + funcLabel(fn)
+					case marshalers[fn.Name()]:
+						how = "marshaled by " + funcLabel(fn)
+					default:
+						continue
+					}
+					ident := argIdent(arg)
+					if ident == nil {
+						ident = &ast.Ident{NamePos: arg.Pos()}
+					}
+					idents = append(idents, ident)
+					details[ident] = how
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
+
+// nameArg returns the value of a constant string argument of the call naming
+// the type, such as "config", "Config" or "pkg.Config" for pkg.Config.
+func nameArg(info *loader.PackageInfo, call *ast.CallExpr, obj *types.TypeName) string {
+	for _, arg := range call.Args {
+		tv, ok := info.Types[arg]
+		if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+			continue
+		}
+		s := constant.StringVal(tv.Value)
+		name := s[strings.LastIndexAny(s, "./")+1:]
+		if strings.EqualFold(name, obj.Name()) {
+			return s
+		}
+	}
+	return ""
+}
+
+// argIdent returns the identifier naming the value or type of an argument,
+// such as v in &v, or T in T{}, []T{} or (*T)(nil), or nil if there isn't
+// one.
+func argIdent(arg ast.Expr) *ast.Ident {
+	switch x := ast.Unparen(arg).(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	case *ast.UnaryExpr:
+		return argIdent(x.X)
+	case *ast.StarExpr:
+		return argIdent(x.X)
+	case *ast.ArrayType:
+		return argIdent(x.Elt)
+	case *ast.CompositeLit:
+		if x.Type != nil {
+			return argIdent(x.Type)
+		}
+	case *ast.CallExpr:
+		// A conversion such as (*T)(nil).
+		if star, ok := ast.Unparen(x.Fun).(*ast.StarExpr); ok {
+			return argIdent(star.X)
+		}
+	}
+	return nil
+}
+
+// funcLabel names a function by its package name, such as json.Marshal, or a
+// method by its receiver type, such as (*gob.Encoder).Encode.
+func funcLabel(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), PackageName) + ")." + fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
@@ -0,0 +1,109 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+)
+
+// FindShadows returns the local declarations within the packages which
+// shadow the package level objects where they would otherwise be visible,
+// such as a variable named after a package level function, along with the
+// kind of declaration and the function declaring it, such as "parameter of
+// Parse" or "var in Parse".
+func FindShadows(pkgs []*loader.PackageInfo, objs map[types.Object]bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	return findShadows(pkgs, func(obj types.Object) bool { return objs[obj] })
+}
+
+// FindPackageShadows is like FindShadows, but returns the declarations which
+// shadow the name of an import of the package, such as a variable named url
+// in a file importing net/url. If copies is true, vendored copies of the
+// package are also matched.
+func FindPackageShadows(pkgs []*loader.PackageInfo, path string, copies bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	return findShadows(pkgs, func(obj types.Object) bool {
+		pkgName, ok := obj.(*types.PkgName)
+		if !ok {
+			return false
+		}
+		p := pkgName.Imported().Path()
+		return p == path || (copies && load.Unvendor(p) == load.Unvendor(path))
+	})
+}
+
+func findShadows(pkgs []*loader.PackageInfo, shadowed func(types.Object) bool) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				name := declName(fd)
+				kinds := fieldKinds(fd.Recv, "receiver")
+				ast.Inspect(fd, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.FuncType:
+						for ident, kind := range fieldKinds(n.Params, "parameter") {
+							kinds[ident] = kind
+						}
+						for ident, kind := range fieldKinds(n.Results, "result") {
+							kinds[ident] = kind
+						}
+					case *ast.Ident:
+						obj := info.Defs[n]
+						if obj == nil || n == fd.Name {
+							return true
+						}
+						if _, ok := obj.(*types.Label); ok {
+							return true
+						}
+						scope := obj.Parent()
+						if scope == nil || scope == info.Pkg.Scope() || scope.Parent() == nil {
+							return true
+						}
+						if _, outer := scope.Parent().LookupParent(n.Name, n.Pos()); outer == nil || !shadowed(outer) {
+							return true
+						}
+						idents = append(idents, n)
+						if kind, ok := kinds[n]; ok {
+							details[n] = kind + " of " + name
+						} else {
+							details[n] = localKind(obj) + " in " + name
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+	return idents, details
+}
+
+// fieldKinds maps the names in a field list to the kind of declaration.
+func fieldKinds(fields *ast.FieldList, kind string) map[*ast.Ident]string {
+	kinds := make(map[*ast.Ident]string)
+	if fields == nil {
+		return kinds
+	}
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			kinds[name] = kind
+		}
+	}
+	return kinds
+}
+
+// localKind returns the keyword declaring a local object.
+func localKind(obj types.Object) string {
+	switch obj.(type) {
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	}
+	return "var"
+}
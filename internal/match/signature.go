@@ -0,0 +1,30 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindSignature returns the declarations of functions and methods within the
+// packages whose signatures are identical to sig, ignoring receivers. Methods
+// of interfaces aren't included.
+func FindSignature(pkgs []*loader.PackageInfo, sig *types.Signature) []*ast.Ident {
+	var idents []*ast.Ident
+	for _, info := range pkgs {
+		for ident, obj := range info.Defs {
+			f, ok := obj.(*types.Func)
+			if !ok {
+				continue
+			}
+			if recv := f.Type().(*types.Signature).Recv(); recv != nil && types.IsInterface(recv.Type()) {
+				continue
+			}
+			if types.Identical(f.Type(), sig) {
+				idents = append(idents, ident)
+			}
+		}
+	}
+	return idents
+}
@@ -0,0 +1,127 @@
+package match
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/loader"
+)
+
+// FindSyntax returns the identifiers within the packages for a target which
+// names syntax instead of an object:
+//
+//	label		Uses of labels by goto, break and continue statements,
+//			or the labeled statements if defs is true.
+//	tagkey		The keys of struct field tags.
+//	importalias	The names of renamed imports.
+//
+// Since tag keys aren't identifiers, the returned identifiers for them are
+// synthesized to span the key within the tag. If the tag is an interpreted
+// string literal, the identifier spans the entire tag.
+func FindSyntax(pkgs []*loader.PackageInfo, target *resolve.Target, defs bool) []*ast.Ident {
+	var idents []*ast.Ident
+	for _, info := range pkgs {
+		switch target.Kind {
+		case resolve.KindLabel:
+			identsMap := info.Uses
+			if defs {
+				identsMap = info.Defs
+			}
+			for ident, o := range identsMap {
+				if _, ok := o.(*types.Label); ok && ident.Name == target.Name {
+					idents = append(idents, ident)
+				}
+			}
+		case resolve.KindTagKey:
+			for _, file := range info.Files {
+				ast.Inspect(file, func(n ast.Node) bool {
+					if f, ok := n.(*ast.Field); ok && f.Tag != nil {
+						if ident := tagKey(f.Tag, target.Name); ident != nil {
+							idents = append(idents, ident)
+						}
+					}
+					return true
+				})
+			}
+		case resolve.KindImportAlias:
+			for _, file := range info.Files {
+				for _, spec := range file.Imports {
+					if spec.Name != nil && spec.Name.Name == target.Name {
+						idents = append(idents, spec.Name)
+					}
+				}
+			}
+		}
+	}
+	return idents
+}
+
+// tagKey returns an identifier spanning the key within a struct tag, or nil
+// if the tag doesn't contain the key.
+func tagKey(tag *ast.BasicLit, key string) *ast.Ident {
+	if len(tag.Value) < 2 {
+		return nil
+	}
+	if tag.Value[0] != '`' {
+		s, err := strconv.Unquote(tag.Value)
+		if err != nil {
+			return nil
+		}
+		if _, ok := tagKeyOffset(s, key); !ok {
+			return nil
+		}
+		return &ast.Ident{NamePos: tag.Pos(), Name: tag.Value}
+	}
+	// Raw strings are the same in source and value, so the key can be
+	// located exactly.
+	off, ok := tagKeyOffset(tag.Value[1:len(tag.Value)-1], key)
+	if !ok {
+		return nil
+	}
+	return &ast.Ident{NamePos: tag.Pos() + 1 + token.Pos(off), Name: key}
+}
+
+// tagKeyOffset returns the offset of a key within a struct tag, parsing the
+// tag using the conventions of reflect.StructTag.
+func tagKeyOffset(tag, key string) (int, bool) {
+	off := 0
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag, off = tag[i:], off+i
+		if tag == "" {
+			break
+		}
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := tag[:i]
+		if name == key {
+			return off, true
+		}
+		tag, off = tag[i+1:], off+i+1
+
+		// Skip the quoted value.
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		tag, off = tag[i+1:], off+i+1
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package match
+
+import (
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindTags returns the names of struct fields within the packages whose tags
+// contain the key, along with the key and value of the tag at each, such as
+// `json:"id,omitempty"`. If value is set, only fields whose value for the key
+// is the value, or whose value's first comma separated element is, such as the
+// name in `json:"id,omitempty"`, are returned. Embedded fields are named by
+// their type.
+func FindTags(pkgs []*loader.PackageInfo, key, value string) ([]*ast.Ident, map[*ast.Ident]string) {
+	var idents []*ast.Ident
+	details := make(map[*ast.Ident]string)
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				f, ok := n.(*ast.Field)
+				if !ok || f.Tag == nil {
+					return true
+				}
+				tag, err := strconv.Unquote(f.Tag.Value)
+				if err != nil {
+					return true
+				}
+				v, ok := reflect.StructTag(tag).Lookup(key)
+				if !ok {
+					return true
+				}
+				if value != "" && v != value && strings.Split(v, ",")[0] != value {
+					return true
+				}
+				names := f.Names
+				if len(names) == 0 {
+					if ident := embeddedName(f.Type); ident != nil {
+						names = []*ast.Ident{ident}
+					}
+				}
+				for _, name := range names {
+					idents = append(idents, name)
+					details[name] = key + ":" + strconv.Quote(v)
+				}
+				return true
+			})
+		}
+	}
+	return idents, details
+}
+
+// embeddedName returns the identifier naming the type of an embedded field.
+func embeddedName(e ast.Expr) *ast.Ident {
+	for {
+		switch t := e.(type) {
+		case *ast.StarExpr:
+			e = t.X
+		case *ast.ParenExpr:
+			e = t.X
+		case *ast.SelectorExpr:
+			return t.Sel
+		case *ast.IndexExpr:
+			e = t.X
+		case *ast.IndexListExpr:
+			e = t.X
+		case *ast.Ident:
+			return t
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// FindTyped returns the identifiers within the packages of variables,
+// parameters, results and fields whose type is one of the named types or a
+// pointer to one, and of conversions to those types. Instantiations of a
+// generic type match the generic type. Blank identifiers are skipped. If defs
+// is true, only declarations are returned.
+func FindTyped(pkgs []*loader.PackageInfo, typeNames map[types.Object]bool, defs bool) []*ast.Ident {
+	matches := func(t types.Type) bool {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		named, ok := types.Unalias(t).(*types.Named)
+		return ok && typeNames[named.Origin().Obj()]
+	}
+	var idents []*ast.Ident
+	for _, info := range pkgs {
+		for ident, obj := range info.Defs {
+			// Such as in _, ok := x.(T).
+			if ident.Name == "_" {
+				continue
+			}
+			if v, ok := obj.(*types.Var); ok && matches(v.Type()) {
+				idents = append(idents, ident)
+			}
+		}
+		if defs {
+			continue
+		}
+		for ident, obj := range info.Uses {
+			if v, ok := obj.(*types.Var); ok && matches(v.Type()) {
+				idents = append(idents, ident)
+			}
+		}
+		for _, file := range info.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				tv, ok := info.Types[call.Fun]
+				if !ok || !tv.IsType() || !matches(tv.Type) {
+					return true
+				}
+				if ident := conversionIdent(call.Fun); ident != nil {
+					idents = append(idents, ident)
+				}
+				return true
+			})
+		}
+	}
+	return idents
+}
+
+// conversionIdent returns the identifier naming the type of a conversion,
+// such as Buffer in (*bytes.Buffer)(p).
+func conversionIdent(e ast.Expr) *ast.Ident {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.IndexListExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			return x.Sel
+		case *ast.Ident:
+			return x
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package match
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Within limits matches to the declarations of functions, methods and types.
+// The declaration of a type includes the methods declared on it.
+func Within(objs map[types.Object]bool) Filter {
+	return identFilter(func(info *loader.PackageInfo, file *ast.File) map[*ast.Ident]bool {
+		var decls []ast.Node
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if objs[info.Defs[decl.Name]] {
+					decls = append(decls, decl)
+				} else if decl.Recv != nil && len(decl.Recv.List) > 0 {
+					if base := receiverBase(decl.Recv.List[0].Type); base != nil && objs[info.Uses[base]] {
+						decls = append(decls, decl)
+					}
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok && objs[info.Defs[ts.Name]] {
+						decls = append(decls, ts)
+					}
+				}
+			}
+		}
+		idents := make(map[*ast.Ident]bool)
+		for _, decl := range decls {
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok {
+					idents[ident] = true
+				}
+				return true
+			})
+		}
+		return idents
+	})
+}
@@ -1,7 +1,6 @@
-// +build linux
-
-package main
+package render
 
+// color highlights s using ANSI escape codes.
 func color(s string) string {
 	return "\033[0;31m" + s + "\033[0m"
 }
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package render
+
+// EnableColors reports if colors can be used. Terminals on other platforms
+// process ANSI escape codes.
+func EnableColors(fd uintptr) bool {
+	return true
+}
@@ -0,0 +1,24 @@
+package render
+
+import "syscall"
+
+const enableVirtualTerminalProcessing = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// EnableColors enables ANSI escape code processing for the console, which
+// is supported by Windows 10 and later. It reports if colors can be used.
+func EnableColors(fd uintptr) bool {
+	var mode uint32
+	if err := syscall.GetConsoleMode(syscall.Handle(fd), &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	if setConsoleMode.Find() != nil {
+		return false
+	}
+	r, _, _ := setConsoleMode.Call(fd, uintptr(mode|enableVirtualTerminalProcessing))
+	return r != 0
+}
@@ -0,0 +1,292 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"github.com/ericchiang/gotools/internal/match"
+)
+
+// contextLines is the number of lines printed around each match in HTML
+// reports.
+const contextLines = 3
+
+// HTML collects results and writes them as a self-contained HTML page when
+// closed, grouping matches by directory and file. Each match is shown with
+// the surrounding lines, syntax highlighted, and has an anchor of the form
+// file:line.
+type HTML struct {
+	w     io.Writer
+	title string
+	paths Paths
+
+	defs    []match.Definition
+	matches []*match.Match
+	deps    []match.DepUses
+	summary []DirCount
+	footer  *Footer
+	// readFile reads the files of matches.
+	readFile func(name string) ([]byte, error)
+	// files caches the contents of matched files by filename.
+	files map[string][]byte
+}
+
+// NewHTML returns a renderer writing a report with the title to w when
+// closed, printing filenames as controlled by paths.
+func NewHTML(w io.Writer, title string, paths Paths) *HTML {
+	return &HTML{w: w, title: title, paths: paths, files: make(map[string][]byte), readFile: ioutil.ReadFile}
+}
+
+// ReadFrom shows the surrounding lines of matches from the contents of the
+// files as loaded, instead of the files on disk.
+func (h *HTML) ReadFrom(files *load.Snapshot) {
+	h.readFile = files.ReadFile
+}
+
+func (h *HTML) Definition(d match.Definition) error {
+	if d.Filename != "" {
+		d.Filename = filepath.ToSlash(h.paths.Display(d.Filename))
+	}
+	h.defs = append(h.defs, d)
+	return nil
+}
+
+func (h *HTML) Match(m *match.Match) error {
+	if _, ok := h.files[m.Filename]; !ok {
+		data, err := h.readFile(m.Filename)
+		if err != nil {
+			return err
+		}
+		h.files[m.Filename] = data
+	}
+	h.matches = append(h.matches, m)
+	return nil
+}
+
+func (h *HTML) Deps(d match.DepUses) error {
+	h.deps = append(h.deps, d)
+	return nil
+}
+
+func (h *HTML) Summary(s []DirCount) error {
+	h.summary = s
+	return nil
+}
+
+func (h *HTML) Footer(f Footer) error {
+	h.footer = &f
+	return nil
+}
+
+type htmlDir struct {
+	Dir   string
+	Files []*htmlFile
+}
+
+type htmlFile struct {
+	Filename string
+	Matches  []*htmlMatch
+}
+
+type htmlMatch struct {
+	Anchor  string
+	Detail  string
+	Snippet template.HTML
+}
+
+// Close writes the report.
+func (h *HTML) Close() error {
+	var dirs []*htmlDir
+	byDir := make(map[string]*htmlDir)
+	byFile := make(map[string]*htmlFile)
+	for _, m := range h.matches {
+		name := filepath.ToSlash(h.paths.Display(m.Filename))
+		f, ok := byFile[name]
+		if !ok {
+			dir := strings.TrimPrefix(filepath.ToSlash(filepath.Dir(name)), "./")
+			d, ok := byDir[dir]
+			if !ok {
+				d = &htmlDir{Dir: dir}
+				byDir[dir] = d
+				dirs = append(dirs, d)
+			}
+			f = &htmlFile{Filename: name}
+			byFile[name] = f
+			d.Files = append(d.Files, f)
+		}
+		detail := m.Detail
+		if m.Constraints != "" {
+			detail = strings.TrimSpace("[" + m.Constraints + "] " + detail)
+		}
+		if m.Target != "" {
+			detail = strings.TrimSpace(m.Target + ": " + detail)
+		}
+		if m.Kind != "" {
+			detail = strings.TrimSpace(m.Kind + ": " + detail)
+		}
+		f.Matches = append(f.Matches, &htmlMatch{
+			Anchor:  fmt.Sprintf("%s:%d", name, m.Line),
+			Detail:  detail,
+			Snippet: snippet(h.files[m.Filename], m),
+		})
+	}
+	sort.SliceStable(dirs, func(i, j int) bool { return dirs[i].Dir < dirs[j].Dir })
+	return htmlTemplate.Execute(h.w, struct {
+		Title   string
+		Defs    []match.Definition
+		Total   int
+		Dirs    []*htmlDir
+		Summary []DirCount
+		Deps    []match.DepUses
+		Footer  *Footer
+	}{h.title, h.defs, len(h.matches), dirs, h.summary, h.deps, h.footer})
+}
+
+// snippet returns the lines of src around a match as highlighted HTML,
+// marking the matched identifier.
+func snippet(src []byte, m *match.Match) template.HTML {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	first := m.Line - 1 - contextLines
+	if first < 0 {
+		first = 0
+	}
+	last := m.Line - 1 + contextLines
+	if last >= len(lines) {
+		last = len(lines) - 1
+	}
+	var buf bytes.Buffer
+	for i := first; i <= last; i++ {
+		line := strings.TrimRight(string(lines[i]), "\r\n")
+		class := "line"
+		if i == m.Line-1 {
+			class = "line match"
+		}
+		fmt.Fprintf(&buf, `<span class="%s"><span class="num">%d</span>`, class, i+1)
+		mark := -1
+		if i == m.Line-1 {
+			mark = m.Column - 1
+		}
+		highlight(&buf, line, mark)
+		buf.WriteString("</span>\n")
+	}
+	return template.HTML(buf.String())
+}
+
+// highlight writes a line of Go source as HTML, wrapping keywords, literals
+// and comments in spans, and the token at the byte offset mark in a mark
+// element. Since lines are highlighted individually, lines within multi-line
+// comments and raw strings may be highlighted incorrectly.
+func highlight(buf *bytes.Buffer, line string, mark int) {
+	fset := token.NewFileSet()
+	src := []byte(line)
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+	offset := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit != ";" {
+			// Automatically inserted semicolons.
+			continue
+		}
+		start := file.Offset(pos)
+		if start < offset || start >= len(src) {
+			continue
+		}
+		end := start + len(lit)
+		if lit == "" {
+			end = start + len(tok.String())
+		}
+		if end > len(src) {
+			end = len(src)
+		}
+		buf.WriteString(html.EscapeString(line[offset:start]))
+		text := html.EscapeString(line[start:end])
+		class := ""
+		switch {
+		case tok.IsKeyword():
+			class = "kw"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "str"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "num-lit"
+		case tok == token.COMMENT:
+			class = "com"
+		}
+		switch {
+		case start == mark:
+			fmt.Fprintf(buf, "<mark>%s</mark>", text)
+		case class != "":
+			fmt.Fprintf(buf, `<span class="%s">%s</span>`, class, text)
+		default:
+			buf.WriteString(text)
+		}
+		offset = end
+	}
+	buf.WriteString(html.EscapeString(line[offset:]))
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"count": count}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { border-bottom: 1px solid #ccc; }
+a { color: #0366d6; text-decoration: none; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+.line { display: block; }
+.match { background: #fff5b1; }
+.num { display: inline-block; width: 4em; color: #999; user-select: none; }
+.kw { color: #d73a49; }
+.str { color: #032f62; }
+.num-lit { color: #005cc5; }
+.com { color: #6a737d; }
+.detail { color: #555; }
+mark { background: #ffd33d; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 1em 0.2em 0; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Defs}}<p>{{if .Filename}}<a href="#{{.Filename}}:{{.Line}}">{{.Filename}}:{{.Line}}</a>: {{end}}<code>{{.Definition}}</code></p>
+{{end}}<p>{{.Total}} matches</p>
+{{if .Summary}}<table>
+<tr><th>Directory</th><th>Matches</th></tr>
+{{range .Summary}}<tr><td>{{.Dir}}</td><td>{{.Matches}}</td></tr>
+{{end}}</table>
+{{end}}<ul>
+{{range .Dirs}}<li><a href="#{{.Dir}}">{{.Dir}}</a>
+<ul>
+{{range .Files}}<li><a href="#{{.Filename}}">{{.Filename}}</a> ({{len .Matches}})</li>
+{{end}}</ul>
+</li>
+{{end}}</ul>
+{{range .Dirs}}<h2 id="{{.Dir}}">{{.Dir}}</h2>
+{{range .Files}}<h3 id="{{.Filename}}">{{.Filename}}</h3>
+{{range .Matches}}<p id="{{.Anchor}}"><a href="#{{.Anchor}}">{{.Anchor}}</a>{{if .Detail}} <span class="detail">({{.Detail}})</span>{{end}}</p>
+<pre>{{.Snippet}}</pre>
+{{end}}{{end}}{{end}}{{if .Deps}}<h2 id="dependencies">Uses in dependencies</h2>
+<table>
+<tr><th>Package</th><th>Uses</th></tr>
+{{range .Deps}}<tr><td>{{.Dependency}}</td><td>{{.Uses}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Footer}}<p class="detail">{{count .Files "file" "files"}} searched: {{count .Requested "package" "packages"}} requested, {{.Loaded}} loaded, {{.Skipped}} skipped due to errors</p>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Paths controls how filenames are printed, so output can be compared across
+// machines and checkouts.
+type Paths struct {
+	// Dir, if set, is the directory filenames within it are printed
+	// relative to, such as "./conn/conn.go".
+	Dir string
+	// TrimPrefix, if set, is a directory removed from filenames within
+	// it, taking precedence over Dir.
+	TrimPrefix string
+	// Roots are directories outside of Dir, such as GOROOT, whose
+	// filenames are printed relative to a name for the directory, such
+	// as "$GOROOT/src/io/io.go". The most specific root is used.
+	Roots []Root
+}
+
+// Root is a directory printed as a name.
+type Root struct {
+	Name string
+	Dir  string
+}
+
+// Display returns the filename as it should be printed, or unchanged if no
+// directory contains it.
+func (p Paths) Display(filename string) string {
+	if rest, ok := trimDir(filename, p.TrimPrefix); ok {
+		return rest
+	}
+	if rel, ok := within(p.Dir, filename); ok {
+		return "." + string(filepath.Separator) + rel
+	}
+	display := filename
+	longest := 0
+	for _, r := range p.Roots {
+		if rel, ok := within(r.Dir, filename); ok && len(r.Dir) > longest {
+			display = r.Name + string(filepath.Separator) + rel
+			longest = len(r.Dir)
+		}
+	}
+	return display
+}
+
+// trimDir removes dir from the start of filename if it's followed by a path
+// separator, so /home/a isn't removed from /home/ab/x.go.
+func trimDir(filename, dir string) (string, bool) {
+	if dir == "" || !strings.HasPrefix(filename, dir) {
+		return "", false
+	}
+	rest := filename[len(dir):]
+	sep := string(filepath.Separator)
+	if !strings.HasSuffix(dir, sep) && !strings.HasPrefix(rest, sep) {
+		return "", false
+	}
+	return strings.TrimLeft(rest, sep), true
+}
+
+// within returns filename relative to dir if it's within it.
+func within(dir, filename string) (string, bool) {
+	if dir == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,20 @@
+package render
+
+import "testing"
+
+func TestDisplayPathWindows(t *testing.T) {
+	tests := []struct {
+		filename string
+		dir      string
+		want     string
+	}{
+		{`C:\Users\gopher\src\conn.go`, `C:\Users\gopher\src`, `.\conn.go`},
+		{`c:\users\gopher\src\net\conn.go`, `C:\Users\gopher\src`, `.\net\conn.go`},
+		{`D:\src\conn.go`, `C:\Users\gopher\src`, `D:\src\conn.go`},
+	}
+	for _, tt := range tests {
+		if got := (Paths{Dir: tt.dir}).Display(tt.filename); got != tt.want {
+			t.Errorf("Paths{Dir: %q}.Display(%q): expected %q, got %q", tt.dir, tt.filename, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Problem is a diagnostic at a source position. Tools embed it in their own
+// result types to add fields to the JSON output.
+type Problem struct {
+	Filename string
+	Line     int
+	Column   int
+	Message  string
+}
+
+// Base returns the problem, so types embedding a Problem implement
+// Diagnostic.
+func (p *Problem) Base() *Problem { return p }
+
+// Diagnostic is a result which can be printed by PrintProblems.
+type Diagnostic interface {
+	Base() *Problem
+}
+
+// SortProblems sorts the diagnostics by position.
+func SortProblems(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Base(), diags[j].Base()
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// PrintProblems sorts the diagnostics by position and prints each as
+// "file:line:col: message", or as a JSON object if asJSON is true.
+func PrintProblems(w io.Writer, asJSON bool, diags []Diagnostic) error {
+	SortProblems(diags)
+	enc := json.NewEncoder(w)
+	for _, d := range diags {
+		if asJSON {
+			if err := enc.Encode(d); err != nil {
+				return err
+			}
+			continue
+		}
+		p := d.Base()
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", p.Filename, p.Line, p.Column, p.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package render prints search results.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ericchiang/gotools/internal/match"
+)
+
+// Renderer prints matches and the summaries of them.
+type Renderer interface {
+	Definition(d match.Definition) error
+	Match(m *match.Match) error
+	Deps(d match.DepUses) error
+	Summary(s []DirCount) error
+	Footer(f Footer) error
+}
+
+// Text prints each match as its filename, line number and line.
+type Text struct {
+	W io.Writer
+	// Color highlights the matched identifier.
+	Color bool
+	// Paths controls how filenames are printed.
+	Paths Paths
+}
+
+// Definition prints the position and declaration of an object, followed by
+// an empty line to separate it from the matches.
+func (t *Text) Definition(d match.Definition) error {
+	var err error
+	if d.Filename == "" {
+		_, err = fmt.Fprintf(t.W, "%s\n\n", d.Definition)
+	} else {
+		_, err = fmt.Fprintf(t.W, "%s:%d: %s\n\n", t.Paths.Display(d.Filename), d.Line, d.Definition)
+	}
+	return err
+}
+
+// Match prints the line of the match, highlighting the identifier if colors
+// are enabled. The target matched, when searching for several, and build
+// constraints in brackets are printed before the line, and any detail in
+// parentheses after it.
+func (t *Text) Match(m *match.Match) error {
+	line := m.Text
+	if t.Color {
+		start := m.Column - 1
+		line = line[:start] + color(line[start:m.End]) + line[m.End:]
+	}
+	if m.Constraints != "" {
+		line = "[" + m.Constraints + "]" + line
+	}
+	if m.Target != "" {
+		line = m.Target + ": " + line
+	}
+	if m.Kind != "" {
+		line = m.Kind + ": " + line
+	}
+	if m.Detail != "" {
+		line += "\t(" + m.Detail + ")"
+	}
+	_, err := fmt.Fprintf(t.W, "%s:%d:%s\n", t.Paths.Display(m.Filename), m.Line, line)
+	return err
+}
+
+func (t *Text) Deps(d match.DepUses) error {
+	_, err := fmt.Fprintf(t.W, "%d uses in dependency %s\n", d.Uses, d.Dependency)
+	return err
+}
+
+// Summary prints a table of the matches in each directory, followed by an
+// empty line to separate it from the matches.
+func (t *Text) Summary(s []DirCount) error {
+	tw := tabwriter.NewWriter(t.W, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIRECTORY\tMATCHES")
+	for _, c := range s {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Dir, c.Matches)
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+// Footer prints the summary of a search after an empty line.
+func (t *Text) Footer(f Footer) error {
+	_, err := fmt.Fprintf(t.W, "\n%s in %s: %s requested, %d loaded, %d skipped due to errors (%s)\n",
+		count(f.Matches, "match", "matches"), count(f.Files, "file", "files"), count(f.Requested, "package", "packages"),
+		f.Loaded, f.Skipped, f.Elapsed.Round(time.Millisecond))
+	return err
+}
+
+// JSON prints each result as a JSON object.
+type JSON struct {
+	enc   *json.Encoder
+	paths Paths
+}
+
+// NewJSON returns a renderer writing to w, printing filenames as controlled
+// by paths.
+func NewJSON(w io.Writer, paths Paths) *JSON {
+	return &JSON{json.NewEncoder(w), paths}
+}
+
+func (j *JSON) Definition(d match.Definition) error {
+	d.Filename = j.paths.Display(d.Filename)
+	return j.enc.Encode(d)
+}
+
+func (j *JSON) Match(m *match.Match) error {
+	c := *m
+	c.Filename = j.paths.Display(m.Filename)
+	return j.enc.Encode(&c)
+}
+
+func (j *JSON) Deps(d match.DepUses) error { return j.enc.Encode(d) }
+
+// Footer prints the summary of a search, with Elapsed in seconds.
+func (j *JSON) Footer(f Footer) error {
+	return j.enc.Encode(struct {
+		Requested, Loaded, Skipped, Files, Matches int
+		Elapsed                                    float64
+	}{f.Requested, f.Loaded, f.Skipped, f.Files, f.Matches, f.Elapsed.Seconds()})
+}
+
+func (j *JSON) Summary(s []DirCount) error {
+	for _, c := range s {
+		if err := j.enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ericchiang/gotools/internal/match"
+)
+
+func TestDisplayPath(t *testing.T) {
+	sep := string(filepath.Separator)
+	dir := filepath.Join(sep+"home", "gopher", "src")
+	goroot := filepath.Join(sep+"usr", "local", "go")
+	gopath := filepath.Join(sep+"home", "gopher", "go")
+	roots := []Root{
+		{"$GOROOT", goroot},
+		{"$GOPATH", gopath},
+		{"$GOMODCACHE", filepath.Join(gopath, "pkg", "mod")},
+	}
+	tests := []struct {
+		filename string
+		paths    Paths
+		want     string
+	}{
+		{filepath.Join(dir, "conn.go"), Paths{Dir: dir}, "." + sep + "conn.go"},
+		{filepath.Join(dir, "net", "conn.go"), Paths{Dir: dir}, "." + sep + filepath.Join("net", "conn.go")},
+		{filepath.Join(dir+"2", "conn.go"), Paths{Dir: dir}, filepath.Join(dir+"2", "conn.go")},
+		{filepath.Join(sep+"usr", "conn.go"), Paths{Dir: dir}, filepath.Join(sep+"usr", "conn.go")},
+		{filepath.Join(dir, "conn.go"), Paths{}, filepath.Join(dir, "conn.go")},
+		{filepath.Join(dir, "net", "conn.go"), Paths{Dir: dir, TrimPrefix: dir}, filepath.Join("net", "conn.go")},
+		{filepath.Join(dir, "net", "conn.go"), Paths{TrimPrefix: dir + sep}, filepath.Join("net", "conn.go")},
+		{filepath.Join(dir+"2", "conn.go"), Paths{TrimPrefix: dir}, filepath.Join(dir+"2", "conn.go")},
+		{filepath.Join(goroot, "src", "io", "io.go"), Paths{Dir: dir, Roots: roots}, filepath.Join("$GOROOT", "src", "io", "io.go")},
+		{filepath.Join(gopath, "pkg", "mod", "m@v1.0.0", "m.go"), Paths{Dir: dir, Roots: roots}, filepath.Join("$GOMODCACHE", "m@v1.0.0", "m.go")},
+	}
+	for _, tt := range tests {
+		if got := tt.paths.Display(tt.filename); got != tt.want {
+			t.Errorf("%+v.Display(%q): expected %q, got %q", tt.paths, tt.filename, tt.want, got)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	sep := string(filepath.Separator)
+	dir := filepath.Join(sep+"src", "p")
+	filename := filepath.Join(dir, "p.go")
+	tests := []struct {
+		name  string
+		m     match.Match
+		color bool
+		want  string
+	}{
+		{
+			name: "plain",
+			m:    match.Match{Filename: filename, Line: 3, Column: 5, Text: "var foo int", End: 7},
+			want: "." + sep + "p.go:3:var foo int\n",
+		},
+		{
+			name:  "color",
+			m:     match.Match{Filename: filename, Line: 3, Column: 5, Text: "var foo int", End: 7},
+			color: true,
+			want:  "." + sep + "p.go:3:var \033[0;31mfoo\033[0m int\n",
+		},
+		{
+			name:  "tabs",
+			m:     match.Match{Filename: filename, Line: 4, Column: 3, Text: "\t\tfoo()", End: 5},
+			color: true,
+			want:  "." + sep + "p.go:4:\t\t\033[0;31mfoo\033[0m()\n",
+		},
+		{
+			name:  "identifier ends the line",
+			m:     match.Match{Filename: filename, Line: 3, Column: 9, Text: "var x = foo", End: 11},
+			color: true,
+			want:  "." + sep + "p.go:3:var x = \033[0;31mfoo\033[0m\n",
+		},
+		{
+			name:  "multi-byte identifier",
+			m:     match.Match{Filename: filename, Line: 3, Column: 12, Text: "var ñame, 名前 int", End: 17},
+			color: true,
+			want:  "." + sep + "p.go:3:var ñame, \033[0;31m名前\033[0m int\n",
+		},
+		{
+			name: "constraints",
+			m:    match.Match{Filename: filename, Line: 3, Column: 5, Text: "var foo int", End: 7, Constraints: "linux"},
+			want: "." + sep + "p.go:3:[linux]var foo int\n",
+		},
+		{
+			name: "detail",
+			m:    match.Match{Filename: filename, Line: 3, Column: 1, Text: "w = os.Stdout", End: 1, Detail: "*os.File"},
+			want: "." + sep + "p.go:3:w = os.Stdout\t(*os.File)\n",
+		},
+		{
+			name: "target",
+			m:    match.Match{Filename: filename, Line: 3, Column: 5, Text: "net.Dial()", End: 8, Target: "net.Dial"},
+			want: "." + sep + "p.go:3:net.Dial: net.Dial()\n",
+		},
+		{
+			name: "kind",
+			m:    match.Match{Filename: filename, Line: 3, Column: 6, Text: "func Dial() {", End: 10, Kind: "def"},
+			want: "." + sep + "p.go:3:def: func Dial() {\n",
+		},
+		{
+			name: "outside directory",
+			m:    match.Match{Filename: filepath.Join(sep+"src", "q", "q.go"), Line: 1, Column: 9, Text: "package q", End: 9},
+			want: filepath.Join(sep+"src", "q", "q.go") + ":1:package q\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		r := &Text{W: &buf, Color: tt.color, Paths: Paths{Dir: dir}}
+		if err := r.Match(&tt.m); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestFooter(t *testing.T) {
+	tests := []struct {
+		f    Footer
+		want string
+	}{
+		{
+			Footer{Requested: 1, Loaded: 3, Files: 1, Matches: 1, Elapsed: 1500 * time.Microsecond},
+			"\n1 match in 1 file: 1 package requested, 3 loaded, 0 skipped due to errors (2ms)\n",
+		},
+		{
+			Footer{Requested: 2, Loaded: 5, Skipped: 1, Files: 4, Matches: 0, Elapsed: time.Second},
+			"\n0 matches in 4 files: 2 packages requested, 5 loaded, 1 skipped due to errors (1s)\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := (&Text{W: &buf}).Footer(tt.f); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestSummarizeByDir(t *testing.T) {
+	sep := string(filepath.Separator)
+	dir := filepath.Join(sep+"src", "repo")
+	filenames := []string{
+		filepath.Join(dir, "services", "auth", "server.go"),
+		filepath.Join(dir, "services", "auth", "api", "api.go"),
+		filepath.Join(dir, "services", "billing", "billing.go"),
+		filepath.Join(dir, "main.go"),
+	}
+	got := SummarizeByDir(filenames, Paths{Dir: dir}, 2)
+	want := []DirCount{
+		{"services/auth", 2},
+		{".", 1},
+		{"services/billing", 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeByDir: expected %v, got %v", want, got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		line string
+		mark int
+		want string
+	}{
+		{
+			line: `	return "a<b", 1 // done`,
+			mark: -1,
+			want: `	<span class="kw">return</span> <span class="str">&#34;a&lt;b&#34;</span>, <span class="num-lit">1</span> <span class="com">// done</span>`,
+		},
+		{
+			line: `x := foo.Bar(y);`,
+			mark: 9,
+			want: `x := foo.<mark>Bar</mark>(y);`,
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		highlight(&buf, tt.line, tt.mark)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("highlight(%q, %d): expected %q, got %q", tt.line, tt.mark, tt.want, got)
+		}
+	}
+}
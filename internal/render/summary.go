@@ -0,0 +1,71 @@
+package render
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirCount is the number of matches within a directory.
+type DirCount struct {
+	Dir     string
+	Matches int
+}
+
+// Footer summarizes a search after its matches, to check the packages
+// expected were searched.
+type Footer struct {
+	// Requested is the number of packages the patterns matched, Loaded
+	// the number of packages loaded including dependencies, and Skipped
+	// the number of requested packages which weren't searched because
+	// they had errors.
+	Requested int
+	Loaded    int
+	Skipped   int
+	// Files is the number of files searched.
+	Files   int
+	Matches int
+	Elapsed time.Duration
+}
+
+// count returns n followed by the singular or plural form of a noun.
+func count(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// SummarizeByDir counts the matches in each file by directory, truncating
+// directories to depth path elements. Directories are printed as filenames
+// are. Counts are sorted with the most matches first.
+func SummarizeByDir(filenames []string, paths Paths, depth int) []DirCount {
+	counts := make(map[string]int)
+	for _, filename := range filenames {
+		d := path.Dir(filepath.ToSlash(paths.Display(filename)))
+		d = strings.TrimPrefix(d, "./")
+		elems := strings.Split(d, "/")
+		if strings.HasPrefix(d, "/") {
+			// Keep the leading slash of absolute paths.
+			elems = append([]string{"/" + elems[1]}, elems[2:]...)
+		}
+		if len(elems) > depth {
+			elems = elems[:depth]
+		}
+		counts[path.Join(elems...)]++
+	}
+	summary := make([]DirCount, 0, len(counts))
+	for d, n := range counts {
+		summary = append(summary, DirCount{d, n})
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Matches != summary[j].Matches {
+			return summary[i].Matches > summary[j].Matches
+		}
+		return summary[i].Dir < summary[j].Dir
+	})
+	return summary
+}
@@ -0,0 +1,95 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Pos is a position in a source file, as reported by the compiler, naming
+// the object declared or used by the identifier there. Unlike a Target, it
+// can name objects without a package level name, such as local variables,
+// function literals assigned to them, and labels.
+type Pos struct {
+	// Filename is absolute.
+	Filename string
+	Line     int
+	// Column is the byte offset within the line, starting at 1.
+	Column int
+}
+
+// ParsePos parses a position of the form file:line:column.
+func ParsePos(s string) (*Pos, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 {
+		return nil, errors.New("expected file:line:column")
+	}
+	n := len(parts)
+	line, err := strconv.Atoi(parts[n-2])
+	if err != nil || line < 1 {
+		return nil, fmt.Errorf("invalid line %q", parts[n-2])
+	}
+	col, err := strconv.Atoi(parts[n-1])
+	if err != nil || col < 1 {
+		return nil, fmt.Errorf("invalid column %q", parts[n-1])
+	}
+	filename, err := filepath.Abs(strings.Join(parts[:n-2], ":"))
+	if err != nil {
+		return nil, err
+	}
+	return &Pos{filename, line, col}, nil
+}
+
+func (p *Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Object returns the object declared or used by the identifier at the
+// position, which may fall anywhere within the identifier. The file must
+// belong to one of the packages.
+func (p *Pos) Object(fset *token.FileSet, pkgs []*loader.PackageInfo) (types.Object, error) {
+	for _, info := range pkgs {
+		for _, file := range info.Files {
+			tf := fset.File(file.Pos())
+			if tf == nil || tf.Name() != p.Filename {
+				continue
+			}
+			if p.Line > tf.LineCount() {
+				return nil, fmt.Errorf("%s has %d lines", p.Filename, tf.LineCount())
+			}
+			offset := tf.Offset(tf.LineStart(p.Line)) + p.Column - 1
+			end := tf.Size()
+			if p.Line < tf.LineCount() {
+				end = tf.Offset(tf.LineStart(p.Line + 1))
+			}
+			if offset >= end {
+				return nil, fmt.Errorf("%s: column is past the end of the line", p)
+			}
+			pos := tf.Pos(offset)
+			var ident *ast.Ident
+			ast.Inspect(file, func(n ast.Node) bool {
+				if ident != nil || n == nil || pos < n.Pos() || pos >= n.End() {
+					return false
+				}
+				ident, _ = n.(*ast.Ident)
+				return true
+			})
+			if ident == nil {
+				return nil, fmt.Errorf("%s: no identifier at position", p)
+			}
+			obj := info.ObjectOf(ident)
+			if obj == nil {
+				return nil, fmt.Errorf("%s: %s doesn't declare or use an object", p, ident.Name)
+			}
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("%s isn't a file of the searched packages", p.Filename)
+}
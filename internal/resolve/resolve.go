@@ -0,0 +1,417 @@
+// Package resolve parses search expressions and resolves them to the objects
+// they name within a loaded program.
+package resolve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/load"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// Target is a parsed expression: a package followed by a top level name and
+// any fields or methods selected from it. If the expression is only a package,
+// Name is empty.
+//
+// Expressions prefixed by a kind, such as "label:retry", name syntax instead
+// of an object. Kind is set and only Name is used.
+//
+// If the name is a regular expression between slashes, such as
+// "mypkg./^Handle/", Regexp is set, Name is its source, and the target must
+// be expanded to the top level names it matches before being resolved.
+type Target struct {
+	Kind   string
+	Pkg    string
+	Name   string
+	Fields []string
+	Regexp *regexp.Regexp
+}
+
+// Kinds of targets which name syntax rather than an object.
+const (
+	// KindLabel targets labeled statements and goto, break and continue
+	// statements referring to a label.
+	KindLabel = "label"
+	// KindTagKey targets struct field tags containing a key, such as json.
+	KindTagKey = "tagkey"
+	// KindImportAlias targets imports renamed to a name.
+	KindImportAlias = "importalias"
+)
+
+var kinds = []string{KindLabel, KindTagKey, KindImportAlias}
+
+// Parse parses an expression. Unless the expression begins with a kind
+// prefix, it performs a quote aware split by periods. Periods within double
+// quotes are ignored, and quotes are not part of the returned strings.
+//
+//	t, _ := Parse(`"github.com/ericchiang/gosearch".Foo.Bar`)
+//	// &Target{"", "github.com/ericchiang/gosearch", "Foo", [Bar]}
+//	t, _ = Parse("net/http./^Handle/")
+//	// &Target{"", "net/http", "^Handle", [], regexp.MustCompile("^Handle")}
+//	t, _ = Parse("tagkey:json")
+//	// &Target{"tagkey", "", "json", []}
+func Parse(s string) (*Target, error) {
+	for _, kind := range kinds {
+		if !strings.HasPrefix(s, kind+":") {
+			continue
+		}
+		name := strings.TrimPrefix(s, kind+":")
+		if name == "" {
+			return nil, fmt.Errorf("no name provided after %q", kind+":")
+		}
+		return &Target{Kind: kind, Name: name}, nil
+	}
+	expr := s
+	pkg, s, err := readNext(s)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == "" {
+		return nil, errors.New("no target provided")
+	}
+	if strings.HasPrefix(s, "/") {
+		end := strings.LastIndex(s, "/")
+		if end == 0 {
+			return nil, errors.New("unmatched '/'")
+		}
+		if end != len(s)-1 {
+			return nil, errors.New("a regular expression must be the last part of an expression")
+		}
+		re, err := regexp.Compile(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		return &Target{Pkg: pkg, Name: s[1:end], Regexp: re}, nil
+	}
+	if s == "" && !strings.HasSuffix(expr, ".") {
+		// The package itself.
+		return &Target{Pkg: pkg}, nil
+	}
+	name, s, err := readNext(s)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("no package field provided")
+	}
+	t := &Target{Pkg: pkg, Name: name}
+	for {
+		var field string
+		field, s, err = readNext(s)
+		if err != nil {
+			return nil, err
+		}
+		if field == "" {
+			return t, nil
+		}
+		t.Fields = append(t.Fields, field)
+	}
+}
+
+func readNext(s string) (string, string, error) {
+	var field, rest bytes.Buffer
+	r := strings.NewReader(s)
+	inQuote := false
+Loop:
+	for {
+		r, _, err := r.ReadRune()
+		if err != nil {
+			if inQuote {
+				return "", "", errors.New(`unmatched '"'`)
+			}
+			break // only error we can get is io.EOF
+		}
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == '.' && !inQuote:
+			break Loop
+		default:
+			field.WriteRune(r)
+		}
+	}
+	io.Copy(&rest, r)
+	return field.String(), rest.String(), nil
+}
+
+// String returns the target as an expression, quoting the package path if it
+// contains periods.
+func (t *Target) String() string {
+	if t.Kind != "" {
+		return t.Kind + ":" + t.Name
+	}
+	s := t.Pkg
+	if strings.Contains(s, ".") {
+		s = strconv.Quote(s)
+	}
+	switch {
+	case t.Regexp != nil:
+		return s + "./" + t.Name + "/"
+	case t.Name == "":
+		return s
+	}
+	return strings.Join(append([]string{s, t.Name}, t.Fields...), ".")
+}
+
+// Expand returns a target for each top level name within a package matching
+// the target's regular expression, in sorted order.
+func (t *Target) Expand(pkgInfo *loader.PackageInfo) ([]*Target, error) {
+	if len(pkgInfo.Errors) != 0 {
+		return nil, fmt.Errorf("Package '%s' had compilation errors", pkgInfo.Pkg.Path())
+	}
+	var targets []*Target
+	for _, name := range pkgInfo.Pkg.Scope().Names() {
+		if t.Regexp.MatchString(name) {
+			targets = append(targets, &Target{Pkg: t.Pkg, Name: name})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("No names in package '%s' match /%s/", pkgInfo.Pkg.Path(), t.Name)
+	}
+	return targets, nil
+}
+
+// Lookup attempts to find the object the target names within a package.
+func (t *Target) Lookup(pkgInfo *loader.PackageInfo) (types.Object, error) {
+	obj, _, err := t.lookup(pkgInfo)
+	return obj, err
+}
+
+// lookup returns the object the target names and the path of names leading
+// to it, including any embedded fields traversed to reach a promoted field
+// or method.
+func (t *Target) lookup(pkgInfo *loader.PackageInfo) (types.Object, []string, error) {
+	if len(pkgInfo.Errors) != 0 {
+		return nil, nil, fmt.Errorf("Package '%s' had compilation errors", pkgInfo.Pkg.Path())
+	}
+	pkg := pkgInfo.Pkg
+	obj := pkg.Scope().Lookup(t.Name)
+	if obj == nil {
+		err := &LookupError{msg: fmt.Sprintf("Failed to find type '%s' in package '%s'", t.Name, pkg.Path())}
+		for _, name := range closest(t.Name, pkg.Scope().Names()) {
+			s := &Target{Pkg: t.Pkg, Name: name, Fields: t.Fields}
+			err.Suggestions = append(err.Suggestions, s.complete(pkgInfo))
+		}
+		return nil, nil, err
+	}
+	path := []string{t.Name}
+	for i, field := range t.Fields {
+		typ := obj.Type()
+		var index []int
+		obj, index, _ = types.LookupFieldOrMethod(typ, true, pkg, field)
+		if obj == nil {
+			err := &LookupError{msg: fmt.Sprintf("Failed to lookup field or method '%s' on type '%s'", strings.Join(t.Fields[:i+1], "."), t.Name)}
+			for _, name := range closest(field, memberNames(typ, pkg)) {
+				fields := append(append(t.Fields[:i:i], name), t.Fields[i+1:]...)
+				s := &Target{Pkg: t.Pkg, Name: t.Name, Fields: fields}
+				err.Suggestions = append(err.Suggestions, s.complete(pkgInfo))
+			}
+			return nil, nil, err
+		}
+		path = append(path, embeddedPath(typ, index[:len(index)-1])...)
+		path = append(path, field)
+	}
+	return obj, path, nil
+}
+
+// embeddedPath returns the names of the embedded fields selected by an index
+// sequence, as returned by types.LookupFieldOrMethod.
+func embeddedPath(t types.Type, index []int) []string {
+	var names []string
+	for _, i := range index {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := s.Field(i)
+		names = append(names, f.Name())
+		t = f.Type()
+	}
+	return names
+}
+
+// Fingerprint returns a stable description of the object the target names
+// within a package: the package path, the path of names leading to the
+// object, the kind of object and its type. Unlike the target, the path names
+// every embedded field traversed, so the fingerprint changes if the target
+// later resolves to a different object, or if the object's signature or, for
+// types, underlying type changes.
+//
+//	github.com/org/repo/api Server.Config.Timeout field time.Duration
+func (t *Target) Fingerprint(pkgInfo *loader.PackageInfo) (string, error) {
+	obj, path, err := t.lookup(pkgInfo)
+	if err != nil {
+		return "", err
+	}
+	typ := obj.Type()
+	if _, ok := obj.(*types.TypeName); ok {
+		typ = typ.Underlying()
+	}
+	s := types.TypeString(typ, types.RelativeTo(pkgInfo.Pkg))
+	return pkgInfo.Pkg.Path() + " " + strings.Join(path, ".") + " " + objectKind(obj) + " " + s, nil
+}
+
+func objectKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	}
+	return "object"
+}
+
+// Resolve returns the objects the target names within a program, which must
+// have imported the target's package. If copies is true, the target is also
+// looked up in every other loaded copy of the package with the same import
+// path once any vendor directory is removed.
+func (t *Target) Resolve(prog *loader.Program, copies bool) (map[types.Object]bool, error) {
+	obj, err := t.Lookup(prog.Imported[t.Pkg])
+	if err != nil {
+		return nil, err
+	}
+	objs := map[types.Object]bool{obj: true}
+	if !copies {
+		return objs, nil
+	}
+	for pkg, info := range prog.AllPackages {
+		if pkg == obj.Pkg() || len(info.Errors) != 0 || load.Unvendor(pkg.Path()) != load.Unvendor(t.Pkg) {
+			continue
+		}
+		if o, err := t.Lookup(info); err == nil {
+			objs[o] = true
+		}
+	}
+	return objs, nil
+}
+
+// ID returns a stable identifier for an object reachable from the scope of
+// its package, such as a package level object, a method or a struct field:
+// the package path and the object's path within the package, as encoded by
+// golang.org/x/tools/go/types/objectpath, separated by a space.
+//
+//	net/http Server.M8
+//
+// Unlike display names such as (*net/http.Server).Close, IDs don't depend on
+// receiver types or formatting, so results can be joined across runs and
+// tools, and Object decodes them back to objects. An empty string is returned
+// for other objects, such as local variables.
+func ID(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return ""
+	}
+	path, err := objectpath.For(obj)
+	if err != nil {
+		return ""
+	}
+	return obj.Pkg().Path() + " " + string(path)
+}
+
+// Object returns the object an ID returned by ID names within pkg, which must
+// have the ID's package path.
+func Object(pkg *types.Package, id string) (types.Object, error) {
+	i := strings.Index(id, " ")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid ID %q: no object path", id)
+	}
+	pkgPath, path := id[:i], id[i+1:]
+	if pkgPath != pkg.Path() {
+		return nil, fmt.Errorf("ID %q names an object in package %s, not %s", id, pkgPath, pkg.Path())
+	}
+	return objectpath.Object(pkg, objectpath.Path(path))
+}
+
+// Signature type checks a function type, such as
+// "func(context.Context) error", returning its signature. Packages are
+// referred to by name and must be loaded by the program. If several loaded
+// packages have the name, the one whose path is the name, such as a standard
+// library package, is used.
+func Signature(prog *loader.Program, s string) (*types.Signature, error) {
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signature: %v", err)
+	}
+	if _, ok := expr.(*ast.FuncType); !ok {
+		return nil, fmt.Errorf("signature %q isn't a function type", s)
+	}
+
+	// Import the package named by each qualified identifier.
+	imports := make(map[string]*types.Package)
+	var importErr error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || imports[id.Name] != nil {
+			return true
+		}
+		var found []*types.Package
+		for pkg := range prog.AllPackages {
+			if pkg.Name() == id.Name {
+				found = append(found, pkg)
+			}
+		}
+		switch {
+		case len(found) == 0:
+			importErr = fmt.Errorf("no loaded package is named %s", id.Name)
+		case len(found) == 1:
+			imports[id.Name] = found[0]
+		default:
+			for _, pkg := range found {
+				if pkg.Path() == id.Name {
+					imports[id.Name] = pkg
+				}
+			}
+			if imports[id.Name] == nil {
+				var paths []string
+				for _, pkg := range found {
+					paths = append(paths, pkg.Path())
+				}
+				sort.Strings(paths)
+				importErr = fmt.Errorf("several loaded packages are named %s: %s", id.Name, strings.Join(paths, ", "))
+			}
+		}
+		return true
+	})
+	if importErr != nil {
+		return nil, importErr
+	}
+
+	// Check the signature within an empty package importing them.
+	pkg := types.NewPackage("signature", "signature")
+	for name, imported := range imports {
+		pkg.Scope().Insert(types.NewPkgName(token.NoPos, pkg, name, imported))
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	if err := types.CheckExpr(token.NewFileSet(), pkg, token.NoPos, expr, info); err != nil {
+		return nil, fmt.Errorf("checking signature: %v", err)
+	}
+	return info.Types[expr].Type.(*types.Signature), nil
+}
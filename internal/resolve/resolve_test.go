@@ -0,0 +1,252 @@
+package resolve
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s       string
+		kind    string
+		pkg     string
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{
+			s:    `hello.world`,
+			pkg:  "hello",
+			name: "world",
+		},
+		{
+			s:      `"github.com/ericchiang/gosearch".Foo.Bar`,
+			pkg:    "github.com/ericchiang/gosearch",
+			name:   "Foo",
+			fields: []string{"Bar"},
+		},
+		{
+			s:    `tagkey:json`,
+			kind: KindTagKey,
+			name: "json",
+		},
+		{
+			s:    `label:retry`,
+			kind: KindLabel,
+			name: "retry",
+		},
+		{
+			s:       `importalias:`,
+			wantErr: true,
+		},
+		{
+			s:   `encoding/json`,
+			pkg: "encoding/json",
+		},
+		{
+			s:       `encoding/json.`,
+			wantErr: true,
+		},
+		{
+			s:    `mypkg./^Handle.*/`,
+			pkg:  "mypkg",
+			name: "^Handle.*",
+		},
+		{
+			s:    `"example.com/a/b"./Get|Put/`,
+			pkg:  "example.com/a/b",
+			name: "Get|Put",
+		},
+		{
+			s:       `mypkg./^Handle/.Method`,
+			wantErr: true,
+		},
+		{
+			s:       `mypkg./(/`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		errorf := func(format string, a ...interface{}) {
+			prefix := fmt.Sprintf("Parse(%q): ", tt.s)
+			t.Errorf(prefix+format, a...)
+		}
+
+		target, err := Parse(tt.s)
+		if err != nil {
+			if !tt.wantErr {
+				errorf("%v", err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			errorf("expected error")
+		}
+
+		if target.Kind != tt.kind {
+			errorf("expected kind=%q, got=%q", tt.kind, target.Kind)
+		}
+		if target.Pkg != tt.pkg {
+			errorf("expected pkg=%q, got=%q", tt.pkg, target.Pkg)
+		}
+		if target.Name != tt.name {
+			errorf("expected name=%q, got=%q", tt.name, target.Name)
+		}
+		if !reflect.DeepEqual(tt.fields, target.Fields) {
+			errorf("expected fields=%q, got=%q", tt.fields, target.Fields)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	pkg := types.NewPackage("net/http", "http")
+	tn := types.NewTypeName(token.NoPos, pkg, "Server", nil)
+	addr := types.NewField(token.NoPos, pkg, "Addr", types.Typ[types.String], false)
+	named := types.NewNamed(tn, types.NewStruct([]*types.Var{addr}, nil), nil)
+	pkg.Scope().Insert(tn)
+	recv := types.NewVar(token.NoPos, pkg, "s", types.NewPointer(named))
+	method := types.NewFunc(token.NoPos, pkg, "Close", types.NewSignatureType(recv, nil, nil, nil, nil, false))
+	named.AddMethod(method)
+	fn := types.NewFunc(token.NoPos, pkg, "Get", types.NewSignatureType(nil, nil, nil, nil, nil, false))
+	pkg.Scope().Insert(fn)
+	local := types.NewVar(token.NoPos, pkg, "x", types.Typ[types.Int])
+
+	tests := []struct {
+		obj  types.Object
+		want string
+	}{
+		{fn, "net/http Get"},
+		{tn, "net/http Server"},
+		{method, "net/http Server.M0"},
+		{addr, "net/http Server.UF0"},
+		{local, ""},
+	}
+	for _, tt := range tests {
+		id := ID(tt.obj)
+		if id != tt.want {
+			t.Errorf("ID(%s): expected %q, got %q", tt.obj, tt.want, id)
+		}
+		if id == "" {
+			continue
+		}
+		obj, err := Object(pkg, id)
+		if err != nil {
+			t.Errorf("Object(%q): %v", id, err)
+		} else if obj != tt.obj {
+			t.Errorf("Object(%q): expected %s, got %s", id, tt.obj, obj)
+		}
+	}
+
+	for _, id := range []string{"net/http", "net/url Get", "net/http Client"} {
+		if _, err := Object(pkg, id); err == nil {
+			t.Errorf("Object(%q): expected error", id)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(src, target string) string {
+		conf := loader.Config{}
+		f, err := conf.ParseFile("p.go", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles("p", f)
+		prog, err := conf.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tgt, err := Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := tgt.Fingerprint(prog.Created[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		target   string
+		old, new string
+		want     string
+	}{
+		{
+			target: "p.F",
+			old:    "package p\n\nfunc F(s string) error { return nil }\n",
+			new:    "package p\n\nfunc F(s string, n int) error { return nil }\n",
+			want:   "p F func func(s string) error",
+		},
+		{
+			target: "p.T",
+			old:    "package p\n\ntype T struct{ A int }\n",
+			new:    "package p\n\ntype T struct{ A int64 }\n",
+			want:   "p T type struct{A int}",
+		},
+		{
+			target: "p.T.B",
+			old:    "package p\n\ntype E struct{ B []E }\n\ntype T struct{ E }\n",
+			new:    "package p\n\ntype E struct{ B []*E }\n\ntype T struct{ E }\n",
+			want:   "p T.E.B field []E",
+		},
+	}
+	for _, tt := range tests {
+		old := fingerprint(tt.old, tt.target)
+		if old != tt.want {
+			t.Errorf("%s: expected fingerprint %q, got %q", tt.target, tt.want, old)
+		}
+		if new := fingerprint(tt.new, tt.target); new == old {
+			t.Errorf("%s: fingerprint %q didn't change with the object's type", tt.target, old)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	ctx := types.NewPackage("context", "context")
+	name := types.NewTypeName(token.NoPos, ctx, "Context", nil)
+	types.NewNamed(name, types.NewInterfaceType(nil, nil), nil)
+	ctx.Scope().Insert(name)
+	prog := &loader.Program{AllPackages: map[*types.Package]*loader.PackageInfo{ctx: nil}}
+
+	sig, err := Signature(prog, "func(ctx context.Context, args ...string) (int, error)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func(ctx context.Context, args ...string) (int, error)"
+	if got := types.TypeString(sig, func(p *types.Package) string { return p.Name() }); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	for _, s := range []string{"int", "func(http.Handler)", "func(context.Missing)", "func("} {
+		if _, err := Signature(prog, s); err == nil {
+			t.Errorf("Signature(%q): expected error", s)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	names := []string{"Buffer", "NewBuffer", "NewBufferString", "Reader", "ReadAll"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Bufer", []string{"Buffer"}},
+		{"buffer", []string{"Buffer"}},
+		{"Raeder", []string{"Reader"}},
+		{"NewBufferStrin", []string{"NewBufferString"}},
+		{"ReadAl", []string{"ReadAll"}},
+		{"Xyzzy", nil},
+	}
+	for _, tt := range tests {
+		if got := closest(tt.name, names); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("closest(%q): expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
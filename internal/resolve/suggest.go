@@ -0,0 +1,147 @@
+package resolve
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// maxSuggestions limits the targets suggested for a name which isn't found.
+const maxSuggestions = 3
+
+// LookupError is returned when the name of a target, or one of its fields or
+// methods, isn't found. Suggestions holds the targets naming the closest
+// existing names, if any are close enough to be typos of it.
+type LookupError struct {
+	Suggestions []*Target
+
+	msg string
+}
+
+func (e *LookupError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return e.msg
+	}
+	var names []string
+	for _, t := range e.Suggestions {
+		names = append(names, t.String())
+	}
+	return e.msg + ": did you mean " + strings.Join(names, " or ") + "?"
+}
+
+// Unambiguous returns the suggested target if there's only one.
+func (e *LookupError) Unambiguous() *Target {
+	if len(e.Suggestions) != 1 {
+		return nil
+	}
+	return e.Suggestions[0]
+}
+
+// complete returns a suggested target with the names following the one it
+// replaced also replaced, if they aren't found and have a single close match.
+func (t *Target) complete(pkgInfo *loader.PackageInfo) *Target {
+	_, _, err := t.lookup(pkgInfo)
+	if err, ok := err.(*LookupError); ok && err.Unambiguous() != nil {
+		return err.Unambiguous()
+	}
+	return t
+}
+
+// closest returns the candidates closest to name, ignoring case, which are
+// within a few edits of it. Several are returned only if they're equally
+// close.
+func closest(name string, candidates []string) []string {
+	best := len(name)/3 + 1
+	var found []string
+	for _, c := range candidates {
+		d := distance(strings.ToLower(name), strings.ToLower(c))
+		switch {
+		case d < best:
+			best, found = d, []string{c}
+		case d == best:
+			found = append(found, c)
+		}
+	}
+	sort.Strings(found)
+	if len(found) > maxSuggestions {
+		found = found[:maxSuggestions]
+	}
+	return found
+}
+
+// distance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent bytes needed to turn a into b.
+func distance(a, b string) int {
+	// rows holds the distances between the prefixes of a and b, for the
+	// last three prefixes of a.
+	var rows [3][]int
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev2, prev, cur := rows[(i+1)%3], rows[(i+2)%3], rows[i%3]
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)%3][len(b)]
+}
+
+// memberNames returns the names of the fields and methods, including promoted
+// ones, which can be selected from a value of type t within pkg.
+func memberNames(t types.Type, pkg *types.Package) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		if obj, _, _ := types.LookupFieldOrMethod(t, true, pkg, name); obj != nil {
+			names = append(names, name)
+		}
+	}
+
+	mset := types.NewMethodSet(t)
+	if _, ok := t.Underlying().(*types.Pointer); !ok && !types.IsInterface(t) {
+		mset = types.NewMethodSet(types.NewPointer(t))
+	}
+	for i := 0; i < mset.Len(); i++ {
+		add(mset.At(i).Obj().Name())
+	}
+
+	visited := make(map[types.Type]bool)
+	var fields func(t types.Type)
+	fields = func(t types.Type) {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok || visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < s.NumFields(); i++ {
+			f := s.Field(i)
+			add(f.Name())
+			if f.Embedded() {
+				fields(f.Type())
+			}
+		}
+	}
+	fields(t)
+	return names
+}
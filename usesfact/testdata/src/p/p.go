@@ -0,0 +1,14 @@
+package p
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+func f() {
+	r := strings.NewReader("") // want "use of strings NewReader"
+	ioutil.ReadAll(r)          // want "use of io/ioutil ReadAll"
+	var b strings.Builder
+	b.WriteString("")
+	b.Reset()
+}
@@ -0,0 +1,149 @@
+// Package usesfact provides a go/analysis analyzer reporting uses of
+// configured functions, types, variables and constants, which exports them
+// as package facts so other analyzers in a multichecker can learn which
+// packages use an API, such as a deprecated one.
+//
+// Symbols are named by the IDs printed by gosearch, giveupthefunc and the
+// repo's other tools: the package path and the object's objectpath, separated
+// by a space, such as "io/ioutil ReadAll" or "net/http Server.M8" for
+// (*net/http.Server).Close.
+package usesfact
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports uses of the symbols named by the IDs in its -symbols flag,
+// separated by commas, and exports a Fact listing them for each package using
+// any.
+var Analyzer = &analysis.Analyzer{
+	Name: "usesfact",
+	Doc: `report uses of symbols named by ID
+
+The symbols are listed by the -symbols flag, separated by commas, such as
+-symbols 'io/ioutil ReadAll,net/http Server.M8'. Packages using any of
+them export a fact listing their uses.`,
+	FactTypes: []analysis.Fact{new(Fact)},
+	Run:       run,
+}
+
+// symbols is the value of the analyzer's -symbols flag.
+var symbols string
+
+func init() {
+	Analyzer.Flags.StringVar(&symbols, "symbols", "", "comma separated IDs of the symbols to report, such as 'io/ioutil ReadAll'")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	var ids []string
+	for _, id := range strings.Split(symbols, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	found := find(pass.Fset, pass.Files, pass.TypesInfo, ids)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	fact := &Fact{}
+	for _, f := range found {
+		pass.Reportf(f.pos, "use of %s", f.Symbol)
+		fact.Uses = append(fact.Uses, f.Use)
+	}
+	pass.ExportPackageFact(fact)
+	return nil, nil
+}
+
+// Use is a use of a symbol.
+type Use struct {
+	// Symbol is the ID of the object used.
+	Symbol string
+	// Position is the file:line:column of the use. Unlike a token.Pos,
+	// it's meaningful in the packages importing the fact.
+	Position string
+}
+
+// Fact lists the uses of symbols within a package.
+type Fact struct {
+	Uses []Use
+}
+
+// AFact marks Fact as a go/analysis fact.
+func (*Fact) AFact() {}
+
+func (f *Fact) String() string {
+	symbols := make(map[string]bool)
+	for _, u := range f.Uses {
+		symbols[u.Symbol] = true
+	}
+	names := make([]string, 0, len(symbols))
+	for s := range symbols {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("uses(%s)", strings.Join(names, ", "))
+}
+
+// Has reports whether the package uses the symbol.
+func (f *Fact) Has(symbol string) bool {
+	for _, u := range f.Uses {
+		if u.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the uses of the symbols, named by their IDs, within the files,
+// ordered by position. Uses of instantiations of generic functions and types
+// are uses of the generic declaration.
+func Find(fset *token.FileSet, files []*ast.File, info *types.Info, symbols []string) []Use {
+	var uses []Use
+	for _, f := range find(fset, files, info, symbols) {
+		uses = append(uses, f.Use)
+	}
+	return uses
+}
+
+// found is a use along with its position in the file set it was found in.
+type found struct {
+	Use
+	pos token.Pos
+}
+
+func find(fset *token.FileSet, files []*ast.File, info *types.Info, symbols []string) []found {
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[s] = true
+	}
+	var uses []found
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := info.Uses[ident]
+			switch o := obj.(type) {
+			case nil:
+				return true
+			case *types.Func:
+				obj = o.Origin()
+			}
+			if id := resolve.ID(obj); id != "" && want[id] {
+				uses = append(uses, found{Use{Symbol: id, Position: fset.Position(ident.Pos()).String()}, ident.Pos()})
+			}
+			return true
+		})
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].pos < uses[j].pos })
+	return uses
+}
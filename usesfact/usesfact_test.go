@@ -0,0 +1,140 @@
+package usesfact
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ericchiang/gotools/internal/resolve"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestFind(t *testing.T) {
+	src := `package p
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+func f() {
+	ioutil.ReadAll(strings.NewReader(""))
+	var b strings.Builder
+	b.WriteString("")
+	g := ioutil.ReadAll
+	_ = g
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("p", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Method IDs depend on the order of the methods, so look it up.
+	var writeString string
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == "strings" {
+			obj, _, _ := types.LookupFieldOrMethod(imp.Scope().Lookup("Builder").Type(), true, imp, "WriteString")
+			writeString = resolve.ID(obj)
+		}
+	}
+	if !strings.HasPrefix(writeString, "strings Builder.M") {
+		t.Fatalf("unexpected ID %q of strings.Builder.WriteString", writeString)
+	}
+	uses := Find(fset, []*ast.File{file}, info, []string{"io/ioutil ReadAll", writeString})
+	var got []string
+	for _, u := range uses {
+		got = append(got, u.Symbol+" "+u.Position)
+	}
+	want := []string{
+		"io/ioutil ReadAll p.go:9:9",
+		writeString + " p.go:11:4",
+		"io/ioutil ReadAll p.go:12:14",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	f := &Fact{Uses: uses}
+	if !f.Has("io/ioutil ReadAll") || f.Has("strings NewReader") {
+		t.Errorf("unexpected symbols in %s", f)
+	}
+}
+
+// wantRE matches the diagnostics expected on a line of testdata, in the form
+// used by analysistest.
+var wantRE = regexp.MustCompile(`// want "([^"]*)"`)
+
+func TestAnalyzer(t *testing.T) {
+	if err := analysis.Validate([]*analysis.Analyzer{Analyzer}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Analyzer.Flags.Set("symbols", "io/ioutil ReadAll, strings NewReader"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("symbols", "")
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "testdata/src/p/p.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("p", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var fact *Fact
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     []*ast.File{file},
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(d analysis.Diagnostic) {
+			got = append(got, fmt.Sprintf("%d: %s", fset.Position(d.Pos).Line, d.Message))
+		},
+		ExportPackageFact: func(f analysis.Fact) { fact = f.(*Fact) },
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if m := wantRE.FindStringSubmatch(c.Text); m != nil {
+				want = append(want, fmt.Sprintf("%d: %s", fset.Position(c.Pos()).Line, m[1]))
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected diagnostics %q, got %q", want, got)
+	}
+	if fact == nil {
+		t.Fatal("no fact exported")
+	}
+	if got, want := fact.String(), "uses(io/ioutil ReadAll, strings NewReader)"; got != want {
+		t.Errorf("expected fact %s, got %s", want, got)
+	}
+	for _, u := range fact.Uses {
+		if !strings.HasPrefix(u.Position, "testdata/src/p/p.go:") {
+			t.Errorf("unexpected position %s of %s", u.Position, u.Symbol)
+		}
+	}
+}